@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Run the motion sensor calibration subcommand, e.g. sensor calibrate level. Returns the exit code.
+func runCalibrate(method string) int {
+	motionData := motion.Motion{}
+
+	if err := motionData.LoadSettings(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load motion settings: %s\n", err)
+
+		return 6
+	}
+
+	if err := motionData.Source.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read motion sensor: %s\n", err)
+
+		return 6
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	switch method {
+	case motion.CalibrationLevel:
+		fmt.Printf("Place the TCG4 stationary on a level surface and press Enter... ")
+		if _, err := reader.ReadString('\n'); err != nil {
+			fmt.Printf("Fail\n")
+			fmt.Fprintf(os.Stderr, "Cannot read input: %s\n", err)
+
+			return 6
+		}
+
+		fmt.Printf("Calibrating... ")
+
+		if err := motionData.CalibrateLevel(); err != nil {
+			fmt.Printf("Fail\n")
+			fmt.Fprintf(os.Stderr, "Cannot calibrate motion sensor: %s\n", err)
+
+			return 6
+		}
+
+		fmt.Printf("OK\n")
+	case motion.CalibrationSixOrientation:
+		for {
+			_, pending := motionData.GetCalibration()
+
+			if len(pending) == 0 {
+				pending = motion.CalibrationOrientations
+			}
+
+			fmt.Printf("Place the TCG4 stationary with one of the axes %v pointing up and press Enter... ", pending)
+			// Stop on a closed input, otherwise a failed orientation is asked again forever.
+			if _, err := reader.ReadString('\n'); err != nil {
+				fmt.Printf("Fail\n")
+				fmt.Fprintf(os.Stderr, "Cannot read input: %s\n", err)
+
+				return 6
+			}
+
+			fmt.Printf("Sampling... ")
+
+			orientation, err := motionData.CalibrateOrientation()
+
+			if err != nil {
+				fmt.Printf("Fail\n")
+				fmt.Fprintf(os.Stderr, "Cannot calibrate motion sensor: %s\n", err)
+
+				continue
+			}
+
+			fmt.Printf("OK (%s)\n", orientation)
+
+			if _, pending := motionData.GetCalibration(); len(pending) == 0 {
+				break
+			}
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Usage: sensor [flags] calibrate {%s|%s}\n", motion.CalibrationLevel, motion.CalibrationSixOrientation)
+
+		return 6
+	}
+
+	calibration, _ := motionData.GetCalibration()
+	fmt.Printf("Offset [mg]: %.1f, %.1f, %.1f\n", calibration.Offset[0], calibration.Offset[1], calibration.Offset[2])
+	fmt.Printf("Scale: %.4f, %.4f, %.4f\n", calibration.Scale[0], calibration.Scale[1], calibration.Scale[2])
+
+	return 0
+}
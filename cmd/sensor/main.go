@@ -11,10 +11,19 @@ import (
 	"time"
 
 	"github.com/vuhuy/tcg4-sensor/internal/can"
+	"github.com/vuhuy/tcg4-sensor/internal/events"
+	"github.com/vuhuy/tcg4-sensor/internal/filter"
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
+	"github.com/vuhuy/tcg4-sensor/internal/geofence"
 	"github.com/vuhuy/tcg4-sensor/internal/global"
 	"github.com/vuhuy/tcg4-sensor/internal/gps"
 	"github.com/vuhuy/tcg4-sensor/internal/helper"
+	"github.com/vuhuy/tcg4-sensor/internal/interference"
+	"github.com/vuhuy/tcg4-sensor/internal/leverarm"
 	"github.com/vuhuy/tcg4-sensor/internal/motion"
+	"github.com/vuhuy/tcg4-sensor/internal/movement"
+	"github.com/vuhuy/tcg4-sensor/internal/overspeed"
+	"github.com/vuhuy/tcg4-sensor/internal/reckoning"
 	"github.com/vuhuy/tcg4-sensor/internal/rest"
 )
 
@@ -25,6 +34,23 @@ func main() {
 	flag.Parse()
 	helper.PrintVersion(AppVersion)
 
+	// Run the calibration subcommand instead of the daemon.
+	if flag.Arg(0) == "calibrate" {
+		os.Exit(runCalibrate(flag.Arg(1)))
+	}
+
+	// Parse the ENU origin before any subsystem is started, so an invalid origin does not leave them running.
+	var origin *geo.Origin
+
+	if *global.EnuOrigin != "" {
+		var err error
+
+		if origin, err = geo.ParseOrigin(*global.EnuOrigin); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid ENU origin %s: %s\n", *global.EnuOrigin, err)
+			os.Exit(15)
+		}
+	}
+
 	// Handle graceful shutdown using SIGINT or SIGTERM.
 	done := make(chan struct{})
 	sigs := make(chan os.Signal, 1)
@@ -61,29 +87,157 @@ func main() {
 		}
 
 		// Initialize motion.
-		motion := motion.Motion{
+		motionData := motion.Motion{
 			LastUpdate: time.Time{},
 		}
 
-		motionErr := motion.Start(done)
+		motionErr := motionData.Start(done)
 
 		if motionErr != nil {
 			os.Exit(3)
 		}
 
+		// Initialize the lever arm correction.
+		var leverArm *leverarm.LeverArm
+
+		if *global.LeverArm != "" {
+			leverArm = &leverarm.LeverArm{}
+
+			if err := leverArm.Start(&gpsData, &motionData, done); err != nil {
+				os.Exit(16)
+			}
+		}
+
+		// Initialize event detection.
+		var eventDetector *events.Detector
+
+		if *global.Events {
+			eventDetector = &events.Detector{}
+
+			if err := eventDetector.Start(&gpsData, &motionData, done); err != nil {
+				os.Exit(7)
+			}
+		}
+
+		// Initialize vibration analysis.
+		var vibration *motion.Vibration
+
+		if *global.Vibration {
+			vibration = &motion.Vibration{}
+
+			if err := vibration.Start(&motionData, done); err != nil {
+				os.Exit(8)
+			}
+		}
+
+		// Initialize movement detection.
+		var movementData *movement.Movement
+
+		if *global.Movement {
+			movementData = &movement.Movement{}
+
+			if err := movementData.Start(&gpsData, &motionData, done); err != nil {
+				os.Exit(9)
+			}
+		}
+
+		// Initialize dead reckoning.
+		if *global.DeadReckoning {
+			if err := reckoning.Start(&gpsData, &motionData, done); err != nil {
+				os.Exit(10)
+			}
+		}
+
+		// Initialize the Kalman filter.
+		var filterData *filter.Filter
+
+		if *global.Filter {
+			filterData = &filter.Filter{}
+
+			if err := filterData.Start(&gpsData, &motionData, done); err != nil {
+				os.Exit(11)
+			}
+		}
+
+		// Initialize the odometer.
+		var odometer *gps.Odometer
+
+		if *global.Odometer {
+			odometer = &gps.Odometer{}
+
+			if err := odometer.Start(&gpsData, done); err != nil {
+				os.Exit(12)
+			}
+		}
+
+		// Initialize geofencing.
+		var geofences *geofence.Geofences
+
+		if *global.GeofenceFile != "" {
+			geofences = &geofence.Geofences{}
+
+			if err := geofences.Start(&gpsData, done); err != nil {
+				os.Exit(13)
+			}
+		}
+
+		// Initialize overspeed detection.
+		var overspeedDetector *overspeed.Detector
+
+		if *global.Overspeed {
+			overspeedDetector = &overspeed.Detector{}
+
+			if err := overspeedDetector.Start(&gpsData, geofences, done); err != nil {
+				os.Exit(14)
+			}
+		}
+
+		// Initialize interference detection.
+		var interferenceDetector *interference.Detector
+
+		if *global.Interference {
+			interferenceDetector = &interference.Detector{}
+
+			if err := interferenceDetector.Start(&gpsData, done); err != nil {
+				os.Exit(17)
+			}
+		}
+
 		// Initialize CAN.
-		canData := can.Can{}
+		canData := can.Can{
+			Events:       eventDetector,
+			Vibration:    vibration,
+			Movement:     movementData,
+			Filter:       filterData,
+			Odometer:     odometer,
+			Geofences:    geofences,
+			Overspeed:    overspeedDetector,
+			Origin:       origin,
+			Interference: interferenceDetector,
+		}
 
-		canErr := canData.Start(&gpsData, &motion, done)
+		canErr := canData.Start(&gpsData, &motionData, done)
 
 		if canErr != nil {
 			os.Exit(4)
 		}
 
 		// Initialize HTTP REST server.
-		restData := rest.Rest{}
+		restData := rest.Rest{
+			Version:      AppVersion,
+			Events:       eventDetector,
+			Vibration:    vibration,
+			Movement:     movementData,
+			Filter:       filterData,
+			Odometer:     odometer,
+			Geofences:    geofences,
+			Overspeed:    overspeedDetector,
+			Origin:       origin,
+			Interference: interferenceDetector,
+			LeverArm:     leverArm,
+		}
 
-		restErr := restData.Start(&gpsData, &motion, done)
+		restErr := restData.Start(&gpsData, &motionData, &canData, done)
 
 		if restErr != nil {
 			os.Exit(5)
@@ -91,7 +245,11 @@ func main() {
 
 		// Ready.
 		fmt.Printf("CAN sensor data is currently being send on %s at a frequency of %f Hz\n", *global.CanInterface, *global.CanFrequency)
-		fmt.Printf("An HTTP REST server running on %s is available for requesting sensor data\n", ":"+strconv.FormatUint(*global.RestPort, 10))
+		if *global.RestTls {
+			fmt.Printf("An HTTPS REST server running on %s is available for requesting sensor data\n", ":"+strconv.FormatUint(*global.RestPort, 10))
+		} else {
+			fmt.Printf("An HTTP REST server running on %s is available for requesting sensor data\n", ":"+strconv.FormatUint(*global.RestPort, 10))
+		}
 
 		global.Wg.Done()
 	}()
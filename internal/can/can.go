@@ -5,19 +5,60 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"net"
 	"os"
+	"slices"
 	"time"
 
+	"github.com/vuhuy/tcg4-sensor/internal/events"
+	"github.com/vuhuy/tcg4-sensor/internal/filter"
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
+	"github.com/vuhuy/tcg4-sensor/internal/geofence"
 	"github.com/vuhuy/tcg4-sensor/internal/global"
 	"github.com/vuhuy/tcg4-sensor/internal/gps"
 	"github.com/vuhuy/tcg4-sensor/internal/helper"
+	"github.com/vuhuy/tcg4-sensor/internal/interference"
+	"github.com/vuhuy/tcg4-sensor/internal/metrics"
 	"github.com/vuhuy/tcg4-sensor/internal/motion"
+	"github.com/vuhuy/tcg4-sensor/internal/movement"
+	"github.com/vuhuy/tcg4-sensor/internal/overspeed"
 	"go.einride.tech/can"
 	"go.einride.tech/can/pkg/socketcan"
 )
 
-// Struct to store CAN frame data.
-type Can struct{}
+// Struct to store CAN frame data. Optional subsystems are nil when disabled.
+type Can struct {
+	Events       *events.Detector
+	Vibration    *motion.Vibration
+	Movement     *movement.Movement
+	Filter       *filter.Filter
+	Odometer     *gps.Odometer
+	Geofences    *geofence.Geofences
+	Overspeed    *overspeed.Detector
+	Origin       *geo.Origin
+	Interference *interference.Detector
+}
+
+// Transmit a single CAN frame and keep track of the result.
+func transmitFrame(frame can.Frame, tx *socketcan.Transmitter) {
+	err := tx.TransmitFrame(context.Background(), frame)
+
+	if err != nil {
+		metrics.CanFramesFailed.Inc()
+
+		if *global.Verbose {
+			fmt.Printf("[%v] CAN frame failed: %#v %s\n", time.Now().UTC(), frame, err)
+		}
+
+		return
+	}
+
+	metrics.CanFramesSent.Inc()
+
+	if *global.Verbose {
+		fmt.Printf("[%v] CAN frame sended: %#v\n", time.Now().UTC(), frame)
+	}
+}
 
 // Send a GPS float64 value in a single CAN frame.
 func sendFloatFrame(id uint32, value float64, tx *socketcan.Transmitter) {
@@ -31,11 +72,38 @@ func sendFloatFrame(id uint32, value float64, tx *socketcan.Transmitter) {
 	frame.IsExtended = *global.CanExtended
 
 	binary.LittleEndian.PutUint64(frame.Data[:], math.Float64bits(value))
-	_ = tx.TransmitFrame(context.Background(), frame)
+	transmitFrame(frame, tx)
+}
 
-	if *global.Verbose {
-		fmt.Printf("[%v] CAN frame sended: %#v\n", time.Now().UTC(), frame)
+// Send a uint64 value in a single CAN frame.
+func sendUintFrame(id uint32, value uint64, tx *socketcan.Transmitter) {
+	if id == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = id
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	binary.LittleEndian.PutUint64(frame.Data[:], value)
+	transmitFrame(frame, tx)
+}
+
+// Send two values in 0.01 units as int32 in a single CAN frame.
+func sendCentiFrame(id uint32, first, second float64, tx *socketcan.Transmitter) {
+	if id == 0 {
+		return
 	}
+
+	frame := can.Frame{}
+	frame.ID = id
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	binary.LittleEndian.PutUint32(frame.Data[0:4], uint32(int32(math.Max(math.MinInt32, math.Min(math.MaxInt32, math.Round(first*100))))))
+	binary.LittleEndian.PutUint32(frame.Data[4:8], uint32(int32(math.Max(math.MinInt32, math.Min(math.MaxInt32, math.Round(second*100))))))
+	transmitFrame(frame, tx)
 }
 
 // Send additional GPS mode, status, nsat, usat, and qual values in a single CAN frame
@@ -64,11 +132,7 @@ func sendGpsFrame(mode uint8, status uint8, nsat uint16, usat uint16, qual uint8
 	frame.Data[6] = qual
 	frame.Data[7] = 0x0
 
-	_ = tx.TransmitFrame(context.Background(), frame)
-
-	if *global.Verbose {
-		fmt.Printf("[%v] CAN frame sended: %#v\n", time.Now().UTC(), frame)
-	}
+	transmitFrame(frame, tx)
 }
 
 // Send the measured motion sensor X, Y, Z values and the used scale in a single CAN frame.
@@ -99,11 +163,242 @@ func sendMotionFrame(x, y, z int16, scale uint8, tx *socketcan.Transmitter) {
 	frame.Data[6] = scale
 	frame.Data[7] = 0x0
 
-	_ = tx.TransmitFrame(context.Background(), frame)
+	transmitFrame(frame, tx)
+}
+
+// Send the motion pitch and roll in hundredths of a degree in a single CAN frame.
+func sendOrientationFrame(pitch, roll float64, tx *socketcan.Transmitter) {
+	if *global.OrientationFrameId == 0 {
+		return
+	}
 
-	if *global.Verbose {
-		fmt.Printf("[%v] CAN frame sended: %#v\n", time.Now().UTC(), frame)
+	frame := can.Frame{}
+	frame.ID = uint32(*global.OrientationFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	binary.LittleEndian.PutUint16(frame.Data[0:2], uint16(int16(math.Round(pitch*100))))
+	binary.LittleEndian.PutUint16(frame.Data[2:4], uint16(int16(math.Round(roll*100))))
+
+	transmitFrame(frame, tx)
+}
+
+// Send the motion sensor range and the output data rate in tenths of a hertz in a single CAN frame.
+func sendMotionSettingsFrame(scale uint8, dataRate float64, tx *socketcan.Transmitter) {
+	if *global.MotionSettingsFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.MotionSettingsFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	frame.Data[0] = scale
+	binary.LittleEndian.PutUint16(frame.Data[1:3], uint16(math.Min(math.MaxUint16, math.Round(dataRate*10))))
+
+	transmitFrame(frame, tx)
+}
+
+// Send a detected event type, peak, and counter in a single CAN frame.
+func sendEventFrame(event events.Event, tx *socketcan.Transmitter) {
+	if *global.EventFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.EventFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	switch event.Type {
+	case events.TypeImpact:
+		frame.Data[0] = 1
+	case events.TypeHarshBraking:
+		frame.Data[0] = 2
+	case events.TypeHarshAcceleration:
+		frame.Data[0] = 3
+	case events.TypeHarshCornering:
+		frame.Data[0] = 4
+	}
+
+	binary.LittleEndian.PutUint16(frame.Data[1:3], uint16(math.Min(math.MaxUint16, math.Round(event.Peak))))
+	frame.Data[3] = uint8(event.Id)
+
+	transmitFrame(frame, tx)
+}
+
+// Send the vibration RMS per axis and the dominant frequency of the axis with the highest RMS in a single CAN frame.
+func sendVibrationFrame(x, y, z motion.AxisVibration, tx *socketcan.Transmitter) {
+	if *global.VibrationFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.VibrationFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	dominant := x
+
+	for _, axis := range []motion.AxisVibration{y, z} {
+		if axis.Rms > dominant.Rms {
+			dominant = axis
+		}
+	}
+
+	binary.LittleEndian.PutUint16(frame.Data[0:2], uint16(math.Min(math.MaxUint16, math.Round(x.Rms))))
+	binary.LittleEndian.PutUint16(frame.Data[2:4], uint16(math.Min(math.MaxUint16, math.Round(y.Rms))))
+	binary.LittleEndian.PutUint16(frame.Data[4:6], uint16(math.Min(math.MaxUint16, math.Round(z.Rms))))
+	binary.LittleEndian.PutUint16(frame.Data[6:8], uint16(math.Min(math.MaxUint16, math.Round(dominant.DominantFrequency*10))))
+
+	transmitFrame(frame, tx)
+}
+
+// Send the movement status and the duration in seconds since the last state change in a single CAN frame.
+func sendMovementFrame(state string, since time.Time, frozen bool, tx *socketcan.Transmitter) {
+	if *global.MovementFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.MovementFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	if state == movement.StateMoving {
+		frame.Data[0] |= 1
+	}
+
+	if frozen {
+		frame.Data[0] |= 2
+	}
+
+	binary.LittleEndian.PutUint32(frame.Data[1:5], uint32(math.Min(math.MaxUint32, time.Since(since).Seconds())))
+
+	transmitFrame(frame, tx)
+}
+
+// Send the filtered speed, heading, and vertical velocity in a single CAN frame.
+func sendFilteredVelocityFrame(speed, heading, climb float64, tx *socketcan.Transmitter) {
+	if *global.FilteredVelFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.FilteredVelFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	binary.LittleEndian.PutUint16(frame.Data[0:2], uint16(math.Min(math.MaxUint16, math.Round(speed*100))))
+	binary.LittleEndian.PutUint16(frame.Data[2:4], uint16(math.Round(heading*100))%36000)
+	binary.LittleEndian.PutUint16(frame.Data[4:6], uint16(int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(climb*100))))))
+
+	transmitFrame(frame, tx)
+}
+
+// Send the total odometer, trip distance, and trip status in a single CAN frame.
+func sendOdometerFrame(counters gps.Counters, tx *socketcan.Transmitter) {
+	if *global.OdometerFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.OdometerFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	binary.LittleEndian.PutUint32(frame.Data[0:4], uint32(math.Min(math.MaxUint32, counters.Total/100)))
+	binary.LittleEndian.PutUint16(frame.Data[4:6], uint16(math.Min(math.MaxUint16, counters.Trip/100)))
+
+	if counters.TripActive {
+		frame.Data[6] = 1
+	}
+
+	transmitFrame(frame, tx)
+}
+
+// Send the total and trip moving time in a single CAN frame.
+func sendOdometerHoursFrame(counters gps.Counters, tx *socketcan.Transmitter) {
+	if *global.OdometerHoursFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.OdometerHoursFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	binary.LittleEndian.PutUint32(frame.Data[0:4], uint32(math.Min(math.MaxUint32, counters.MovingTime/360)))
+	binary.LittleEndian.PutUint32(frame.Data[4:8], uint32(math.Min(math.MaxUint32, counters.TripMovingTime)))
+
+	transmitFrame(frame, tx)
+}
+
+// Send the overspeed alarm, speed, active speed limit, and overspeed counter in a single CAN frame. The counter wraps
+// around, so a PLC can detect short overspeeds between frames.
+func sendOverspeedFrame(status overspeed.Status, tx *socketcan.Transmitter) {
+	if *global.OverspeedFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.OverspeedFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	if status.Active {
+		frame.Data[0] = 1
+	}
+
+	binary.LittleEndian.PutUint16(frame.Data[1:3], uint16(math.Min(math.MaxUint16, math.Round(status.Speed*100))))
+	binary.LittleEndian.PutUint16(frame.Data[3:5], uint16(math.Min(math.MaxUint16, math.Round(status.Limit*100))))
+	frame.Data[5] = uint8(status.Count)
+
+	transmitFrame(frame, tx)
+}
+
+// Send the interference status, active indicators, mean C/N0, number of satellites with a signal, and event counter
+// in a single CAN frame.
+func sendInterferenceFrame(status interference.Status, tx *socketcan.Transmitter) {
+	if *global.InterferenceFrameId == 0 {
+		return
+	}
+
+	frame := can.Frame{}
+	frame.ID = uint32(*global.InterferenceFrameId)
+	frame.Length = 8
+	frame.IsExtended = *global.CanExtended
+
+	switch status.Status {
+	case interference.StatusSuspected:
+		frame.Data[0] = 1
+	case interference.StatusLikely:
+		frame.Data[0] = 2
+	}
+
+	for bit, indicator := range interference.Indicators {
+		if slices.Contains(status.Indicators, indicator) {
+			frame.Data[1] |= 1 << bit
+		}
+	}
+
+	binary.LittleEndian.PutUint16(frame.Data[2:4], uint16(math.Min(math.MaxUint16, math.Round(status.Cn0*10))))
+	frame.Data[4] = uint8(math.Min(math.MaxUint8, float64(status.Satellites)))
+	frame.Data[5] = uint8(status.Count)
+
+	transmitFrame(frame, tx)
+}
+
+// Check whether the CAN interface is up.
+func (data *Can) IsUp() bool {
+	iface, err := net.InterfaceByName(*global.CanInterface)
+
+	if err != nil {
+		return false
 	}
+
+	return iface.Flags&net.FlagUp != 0
 }
 
 // Start sending CAN messages periodically.
@@ -124,11 +419,14 @@ func (data *Can) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan st
 	fmt.Printf("OK\n")
 
 	tx := socketcan.NewTransmitter(conn)
-	ticker := time.NewTicker(time.Duration(helper.ConvertHzToMilliseconds(*global.CanFrequency)) * time.Millisecond)
+	frequency := *global.CanFrequency
+	ticker := time.NewTicker(time.Duration(helper.ConvertHzToMilliseconds(frequency)) * time.Millisecond)
 
 	global.Wg.Add(1)
 
 	go func() {
+		lastEventId := uint64(0)
+
 		for {
 			select {
 			case <-done:
@@ -137,8 +435,53 @@ func (data *Can) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan st
 				global.Wg.Done()
 				return
 			case <-ticker.C:
+				global.Mutex.RLock()
+
+				// The frequency can be changed at runtime.
+				if *global.CanFrequency != frequency {
+					frequency = *global.CanFrequency
+					ticker.Reset(time.Duration(helper.ConvertHzToMilliseconds(frequency)) * time.Millisecond)
+				}
+
 				sendGpsFrames(gpsData, tx)
 				sendMotionFrames(motionData, tx)
+				sendCoordinateFrames(gpsData, data.Origin, tx)
+
+				if data.Vibration != nil {
+					sendVibrationFrames(data.Vibration, tx)
+				}
+
+				if data.Filter != nil {
+					sendFilterFrames(data.Filter, tx)
+				}
+
+				if data.Geofences != nil {
+					sendUintFrame(uint32(*global.GeofenceFrameId), data.Geofences.GetMembership(), tx)
+				}
+
+				if data.Interference != nil {
+					_, status := data.Interference.Get()
+					sendInterferenceFrame(status, tx)
+				}
+
+				if data.Overspeed != nil {
+					_, status := data.Overspeed.Get()
+					sendOverspeedFrame(status, tx)
+				}
+
+				if data.Odometer != nil {
+					sendOdometerFrames(data.Odometer, tx)
+				}
+
+				if data.Movement != nil {
+					sendMovementFrames(data.Movement, gpsData, tx)
+				}
+
+				if data.Events != nil {
+					lastEventId = sendEventFrames(data.Events, lastEventId, tx)
+				}
+
+				global.Mutex.RUnlock()
 			}
 		}
 	}()
@@ -176,6 +519,43 @@ func sendGpsFrames(gpsData *gps.Gps, tx *socketcan.Transmitter) {
 	sendFloatFrame(uint32(*global.HdopFrameId), hdop, tx)
 	sendFloatFrame(uint32(*global.PdopFrameId), pdop, tx)
 	sendFloatFrame(uint32(*global.GdopFrameId), gdop, tx)
+
+	// The antenna position is the position from GPSd, before the lever arm correction.
+	fix := gpsData.GetFix()
+	sendFloatFrame(uint32(*global.AntennaLatFrameId), fix.Lat, tx)
+	sendFloatFrame(uint32(*global.AntennaLonFrameId), fix.Lon, tx)
+	sendFloatFrame(uint32(*global.AntennaAltFrameId), fix.Alt, tx)
+}
+
+// Send the UTM and ENU coordinate CAN frames. ENU is only sent if an origin is configured.
+func sendCoordinateFrames(gpsData *gps.Gps, origin *geo.Origin, tx *socketcan.Transmitter) {
+	lastUpdate, lat, lon, alt, _, mode, _, _, _, _, _, _, _, _, _, _ := gpsData.GetTpv()
+
+	if lastUpdate.IsZero() || mode < 2 {
+		return
+	}
+
+	if utm, err := geo.ToUtm(lat, lon); err == nil {
+		sendCentiFrame(uint32(*global.UtmFrameId), utm.Easting, utm.Northing, tx)
+
+		if *global.UtmZoneFrameId != 0 {
+			frame := can.Frame{}
+			frame.ID = uint32(*global.UtmZoneFrameId)
+			frame.Length = 8
+			frame.IsExtended = *global.CanExtended
+			frame.Data[0] = utm.Zone
+			frame.Data[1] = utm.Band
+
+			transmitFrame(frame, tx)
+		}
+	}
+
+	if origin != nil {
+		// ENU uses the height above the ellipsoid, the GPS altitude is above mean sea level.
+		enu := origin.ToEnu(lat, lon, alt+gpsData.GetGeoidSeparation())
+		sendCentiFrame(uint32(*global.EnuFrameId), enu.East, enu.North, tx)
+		sendCentiFrame(uint32(*global.EnuUpFrameId), enu.Up, 0, tx)
+	}
 }
 
 // Send motion related CAN frames.
@@ -187,4 +567,66 @@ func sendMotionFrames(motionData *motion.Motion, tx *socketcan.Transmitter) {
 	}
 
 	sendMotionFrame(x, y, z, scale, tx)
+	sendMotionSettingsFrame(scale, motionData.GetDataRate(), tx)
+
+	_, pitch, roll := motionData.GetOrientation()
+	sendOrientationFrame(pitch, roll, tx)
+}
+
+// Send a CAN frame for each event detected since the last sent event. Returns the ID of the last sent event.
+func sendEventFrames(detector *events.Detector, lastEventId uint64, tx *socketcan.Transmitter) uint64 {
+	_, detected := detector.GetSince(lastEventId)
+
+	for _, event := range detected {
+		sendEventFrame(event, tx)
+		lastEventId = event.Id
+	}
+
+	return lastEventId
+}
+
+// Send vibration related CAN frames.
+func sendVibrationFrames(vibration *motion.Vibration, tx *socketcan.Transmitter) {
+	lastUpdate, _, _, x, y, z := vibration.Get()
+
+	if lastUpdate.IsZero() {
+		return
+	}
+
+	sendVibrationFrame(x, y, z, tx)
+}
+
+// Send movement related CAN frames.
+func sendMovementFrames(movementData *movement.Movement, gpsData *gps.Gps, tx *socketcan.Transmitter) {
+	lastUpdate, state, since, _, _ := movementData.Get()
+
+	if lastUpdate.IsZero() {
+		return
+	}
+
+	sendMovementFrame(state, since, gpsData.GetFrozen(), tx)
+}
+
+// Send Kalman filter related CAN frames.
+func sendFilterFrames(filterData *filter.Filter, tx *socketcan.Transmitter) {
+	lastUpdate, _, lat, lon, alt, velE, velN, velU, _, _, _ := filterData.Get()
+
+	if lastUpdate.IsZero() {
+		return
+	}
+
+	speed, heading := filter.SpeedHeading(velE, velN)
+
+	sendFloatFrame(uint32(*global.FilteredLatFrameId), lat, tx)
+	sendFloatFrame(uint32(*global.FilteredLonFrameId), lon, tx)
+	sendFloatFrame(uint32(*global.FilteredAltFrameId), alt, tx)
+	sendFilteredVelocityFrame(speed, heading, velU, tx)
+}
+
+// Send odometer related CAN frames.
+func sendOdometerFrames(odometer *gps.Odometer, tx *socketcan.Transmitter) {
+	_, counters, _ := odometer.Get()
+
+	sendOdometerFrame(counters, tx)
+	sendOdometerHoursFrame(counters, tx)
 }
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+const (
+	redacted        = "<redacted>"
+	argsVariable    = "SENSOR_ARGS"
+	maxFrequency    = 1000
+	maxStandardId   = 0x7FF
+	maxExtendedId   = 0x1FFFFFFF
+	frameIdSuffix   = "-frame-id"
+	frequencySuffix = "-frequency"
+)
+
+// Flags that can be changed at runtime. Only add flags that are read with the global mutex held on every use, e.g. in
+// the CAN loop. The frame ID and frequency suffixes only select the validation.
+var runtimeFlags = map[string]bool{
+	"can-extended":               true,
+	"gps-frequency":              true,
+	"can-frequency":              true,
+	"lat-frame-id":               true,
+	"lon-frame-id":               true,
+	"alt-frame-id":               true,
+	"speed-frame-id":             true,
+	"gps-frame-id":               true,
+	"epc-frame-id":               true,
+	"epd-frame-id":               true,
+	"eph-frame-id":               true,
+	"eps-frame-id":               true,
+	"ept-frame-id":               true,
+	"epx-frame-id":               true,
+	"epy-frame-id":               true,
+	"epv-frame-id":               true,
+	"sep-frame-id":               true,
+	"xdop-frame-id":              true,
+	"ydop-frame-id":              true,
+	"vdop-frame-id":              true,
+	"tdop-frame-id":              true,
+	"hdop-frame-id":              true,
+	"pdop-frame-id":              true,
+	"gdop-frame-id":              true,
+	"motion-frame-id":            true,
+	"motion-settings-frame-id":   true,
+	"orientation-frame-id":       true,
+	"motion-frequency":           true,
+	"event-frame-id":             true,
+	"vibration-frame-id":         true,
+	"movement-frame-id":          true,
+	"filtered-lat-frame-id":      true,
+	"filtered-lon-frame-id":      true,
+	"filtered-alt-frame-id":      true,
+	"filtered-velocity-frame-id": true,
+	"odometer-frame-id":          true,
+	"odometer-hours-frame-id":    true,
+	"geofence-frame-id":          true,
+	"overspeed-frame-id":         true,
+	"utm-frame-id":               true,
+	"utm-zone-frame-id":          true,
+	"enu-frame-id":               true,
+	"enu-up-frame-id":            true,
+	"antenna-lat-frame-id":       true,
+	"antenna-lon-frame-id":       true,
+	"antenna-alt-frame-id":       true,
+	"interference-frame-id":      true,
+}
+
+// Flags that are never shown.
+var secretFlags = map[string]bool{
+	"rest-api-key": true,
+}
+
+// Flags that are not part of the configuration.
+var ignoredFlags = map[string]bool{
+	"version": true,
+}
+
+// Check if a flag can be changed at runtime.
+func IsRuntime(name string) bool {
+	return runtimeFlags[name]
+}
+
+// Get the names of all flags that can be changed at runtime.
+func RuntimeNames() []string {
+	names := []string{}
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if IsRuntime(f.Name) {
+			names = append(names, f.Name)
+		}
+	})
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Get the effective configuration of all flags. Secrets are redacted.
+func Effective() map[string]interface{} {
+	global.Mutex.RLock()
+	defer global.Mutex.RUnlock()
+
+	values := map[string]interface{}{}
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if ignoredFlags[f.Name] {
+			return
+		}
+
+		if secretFlags[f.Name] {
+			if f.Value.String() != "" {
+				values[f.Name] = redacted
+			} else {
+				values[f.Name] = ""
+			}
+
+			return
+		}
+
+		if getter, ok := f.Value.(flag.Getter); ok {
+			values[f.Name] = getter.Get()
+		} else {
+			values[f.Name] = f.Value.String()
+		}
+	})
+
+	return values
+}
+
+// Validate configuration changes against the current configuration. All changed flags must be runtime flags,
+// frame IDs must fit the CAN frame format and be unique, and frequencies must be within range.
+func Validate(changes map[string]string) error {
+	if len(changes) == 0 {
+		return errors.New("no configuration changes")
+	}
+
+	for name := range changes {
+		if flag.Lookup(name) == nil || ignoredFlags[name] {
+			return fmt.Errorf("unknown setting %s", name)
+		}
+
+		if !IsRuntime(name) {
+			return fmt.Errorf("setting %s cannot be changed at runtime", name)
+		}
+	}
+
+	global.Mutex.RLock()
+	values := map[string]string{}
+
+	for _, name := range RuntimeNames() {
+		values[name] = flag.Lookup(name).Value.String()
+	}
+
+	global.Mutex.RUnlock()
+
+	for name, value := range changes {
+		values[name] = value
+	}
+
+	extended, err := strconv.ParseBool(values["can-extended"])
+
+	if err != nil {
+		return fmt.Errorf("invalid value %s for can-extended", values["can-extended"])
+	}
+
+	maxId := uint64(maxStandardId)
+
+	if extended {
+		maxId = maxExtendedId
+	}
+
+	frameIds := map[uint64]string{}
+
+	for _, name := range RuntimeNames() {
+		if strings.HasSuffix(name, frameIdSuffix) {
+			id, err := strconv.ParseUint(values[name], 0, 64)
+
+			if err != nil {
+				return fmt.Errorf("invalid value %s for %s", values[name], name)
+			}
+
+			if id > maxId {
+				return fmt.Errorf("frame ID %d of %s exceeds the maximum of %d", id, name, maxId)
+			}
+
+			if other, ok := frameIds[id]; ok && id != 0 {
+				return fmt.Errorf("frame ID %d of %s is already used by %s", id, name, other)
+			}
+
+			frameIds[id] = name
+		} else if strings.HasSuffix(name, frequencySuffix) {
+			frequency, err := strconv.ParseFloat(values[name], 64)
+
+			if err != nil {
+				return fmt.Errorf("invalid value %s for %s", values[name], name)
+			}
+
+			if frequency <= 0 || frequency > maxFrequency {
+				return fmt.Errorf("frequency %s of %s must be above 0 and at most %d Hz", values[name], name, maxFrequency)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Apply validated configuration changes.
+func Apply(changes map[string]string) error {
+	global.Mutex.Lock()
+	defer global.Mutex.Unlock()
+
+	for name, value := range changes {
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update the arguments of the SENSOR_ARGS variable with the changes. Existing arguments are replaced, new
+// arguments are appended.
+func updateArgs(args []string, changes map[string]string) []string {
+	updated := []string{}
+	replaced := map[string]bool{}
+
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		f := flag.Lookup(name)
+
+		if !strings.HasPrefix(args[i], "-") || f == nil {
+			updated = append(updated, args[i])
+			continue
+		}
+
+		// Skip the separate value of a non boolean flag, e.g. -can-frequency 2.
+		if !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+			if _, ok := changes[name]; ok {
+				i++
+			} else {
+				updated = append(updated, args[i])
+				i++
+				updated = append(updated, args[i])
+
+				continue
+			}
+		}
+
+		if value, ok := changes[name]; ok {
+			if !replaced[name] {
+				updated = append(updated, "--"+name+"="+value)
+				replaced[name] = true
+			}
+
+			continue
+		}
+
+		updated = append(updated, args[i])
+	}
+
+	names := []string{}
+
+	for name := range changes {
+		if !replaced[name] {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		updated = append(updated, "--"+name+"="+changes[name])
+	}
+
+	return updated
+}
+
+// Check if a flag is a boolean flag, which does not take a separate value.
+func isBoolFlag(f *flag.Flag) bool {
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+
+	return ok && boolFlag.IsBoolFlag()
+}
+
+// Persist configuration changes to the SENSOR_ARGS variable of the configuration file. The file is replaced
+// atomically.
+func Persist(changes map[string]string) error {
+	content, err := os.ReadFile(*global.ConfigFile)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(*global.ConfigFile)
+
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	found := false
+
+	for i, line := range lines {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), argsVariable+"=")
+
+		if !ok {
+			continue
+		}
+
+		args := strings.Fields(strings.Trim(value, "\"'"))
+		lines[i] = argsVariable + "=\"" + strings.Join(updateArgs(args, changes), " ") + "\""
+		found = true
+	}
+
+	if !found {
+		if len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+
+		lines = append(lines, argsVariable+"=\""+strings.Join(updateArgs(nil, changes), " ")+"\"", "")
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(*global.ConfigFile), ".sensor-conf-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.WriteString(strings.Join(lines, "\n")); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	if err := temp.Chmod(info.Mode().Perm()); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), *global.ConfigFile)
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+func TestIsRuntime(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"can-extended", true},
+		{"can-frequency", true},
+		{"lat-frame-id", true},
+		{"gpsd-host", false},
+		{"rest-port", false},
+		{"unknown-frame-id", false},
+		{"unknown-frequency", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsRuntime(test.name); got != test.want {
+				t.Errorf("IsRuntime(%s) = %v, want %v", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]string
+		err     string
+	}{
+		{"no changes", map[string]string{}, "no configuration changes"},
+		{"unknown setting", map[string]string{"unknown": "1"}, "unknown setting unknown"},
+		{"ignored setting", map[string]string{"version": "true"}, "unknown setting version"},
+		{"not a runtime setting", map[string]string{"gpsd-host": "example.com"}, "cannot be changed at runtime"},
+		{"frame ID", map[string]string{"lat-frame-id": "300"}, ""},
+		{"hexadecimal frame ID", map[string]string{"lat-frame-id": "0x12C"}, ""},
+		{"invalid frame ID", map[string]string{"lat-frame-id": "abc"}, "invalid value abc for lat-frame-id"},
+		{"standard frame ID too large", map[string]string{"lat-frame-id": "2048"}, "exceeds the maximum of 2047"},
+		{"extended frame ID", map[string]string{"lat-frame-id": "2048", "can-extended": "true"}, ""},
+		{"extended frame ID too large", map[string]string{"lat-frame-id": "536870912", "can-extended": "true"}, "exceeds the maximum of 536870911"},
+		{"invalid extended", map[string]string{"can-extended": "maybe"}, "invalid value maybe for can-extended"},
+		{"duplicate frame ID", map[string]string{"lat-frame-id": "201"}, "is already used by"},
+		{"swapped frame IDs", map[string]string{"lat-frame-id": "201", "lon-frame-id": "200"}, ""},
+		{"disabled frame IDs", map[string]string{"lat-frame-id": "0", "lon-frame-id": "0"}, ""},
+		{"frequency", map[string]string{"can-frequency": "2.5"}, ""},
+		{"invalid frequency", map[string]string{"can-frequency": "fast"}, "invalid value fast for can-frequency"},
+		{"zero frequency", map[string]string{"can-frequency": "0"}, "must be above 0"},
+		{"frequency too high", map[string]string{"can-frequency": "1001"}, "at most 1000 Hz"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.changes)
+
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("Validate(%v) returned error %s", test.changes, err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Validate(%v) error = %v, want %q", test.changes, err, test.err)
+			}
+		})
+	}
+}
+
+func TestUpdateArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		changes map[string]string
+		want    []string
+	}{
+		{
+			name:    "no arguments",
+			changes: map[string]string{"can-frequency": "2", "can-extended": "true"},
+			want:    []string{"--can-extended=true", "--can-frequency=2"},
+		},
+		{
+			name:    "separate value",
+			args:    []string{"-can-frequency", "1", "-can-interface", "can1"},
+			changes: map[string]string{"can-frequency": "2"},
+			want:    []string{"--can-frequency=2", "-can-interface", "can1"},
+		},
+		{
+			name:    "inline value",
+			args:    []string{"--can-frequency=1", "-can-interface=can1"},
+			changes: map[string]string{"can-frequency": "2"},
+			want:    []string{"--can-frequency=2", "-can-interface=can1"},
+		},
+		{
+			name:    "bool flag does not take the next argument",
+			args:    []string{"-can-extended", "-can-frequency", "1"},
+			changes: map[string]string{"can-extended": "false"},
+			want:    []string{"--can-extended=false", "-can-frequency", "1"},
+		},
+		{
+			name:    "unchanged bool flag",
+			args:    []string{"-can-extended", "-can-frequency", "1"},
+			changes: map[string]string{"can-frequency": "2"},
+			want:    []string{"-can-extended", "--can-frequency=2"},
+		},
+		{
+			name:    "duplicates are replaced once",
+			args:    []string{"-can-frequency", "1", "--can-frequency=3", "-lat-frame-id", "200"},
+			changes: map[string]string{"can-frequency": "2"},
+			want:    []string{"--can-frequency=2", "-lat-frame-id", "200"},
+		},
+		{
+			name:    "unknown arguments are kept",
+			args:    []string{"-unknown", "value", "positional"},
+			changes: map[string]string{"can-frequency": "2"},
+			want:    []string{"-unknown", "value", "positional", "--can-frequency=2"},
+		},
+		{
+			name:    "new flags are appended sorted",
+			args:    []string{"-can-interface", "can1"},
+			changes: map[string]string{"lon-frame-id": "301", "lat-frame-id": "300"},
+			want:    []string{"-can-interface", "can1", "--lat-frame-id=300", "--lon-frame-id=301"},
+		},
+		{
+			name:    "missing separate value",
+			args:    []string{"-can-frequency"},
+			changes: map[string]string{"can-frequency": "2"},
+			want:    []string{"--can-frequency=2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := updateArgs(test.args, test.changes); strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("updateArgs(%q) = %q, want %q", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPersist(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "existing arguments",
+			content: "# Sensor\nSENSOR_ARGS=\"-can-frequency 1 -can-interface can1\"\nOTHER=1\n",
+			want:    "# Sensor\nSENSOR_ARGS=\"--can-frequency=2 -can-interface can1\"\nOTHER=1\n",
+		},
+		{
+			name:    "single quoted arguments",
+			content: "SENSOR_ARGS='-can-interface can1'\n",
+			want:    "SENSOR_ARGS=\"-can-interface can1 --can-frequency=2\"\n",
+		},
+		{
+			name:    "missing arguments",
+			content: "OTHER=1\n",
+			want:    "OTHER=1\nSENSOR_ARGS=\"--can-frequency=2\"\n",
+		},
+	}
+
+	configFile := *global.ConfigFile
+
+	t.Cleanup(func() {
+		*global.ConfigFile = configFile
+	})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			*global.ConfigFile = filepath.Join(t.TempDir(), "sensor.conf")
+
+			if err := os.WriteFile(*global.ConfigFile, []byte(test.content), 0640); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Persist(map[string]string{"can-frequency": "2"}); err != nil {
+				t.Fatalf("Persist() returned error %s", err)
+			}
+
+			content, err := os.ReadFile(*global.ConfigFile)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != test.want {
+				t.Errorf("Persist() wrote %q, want %q", content, test.want)
+			}
+
+			info, err := os.Stat(*global.ConfigFile)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Mode().Perm() != 0640 {
+				t.Errorf("Persist() changed the permissions to %v", info.Mode().Perm())
+			}
+
+			entries, err := os.ReadDir(filepath.Dir(*global.ConfigFile))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != 1 {
+				t.Errorf("Persist() left %d files behind", len(entries)-1)
+			}
+		})
+	}
+}
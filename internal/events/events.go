@@ -0,0 +1,228 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Event types.
+const (
+	TypeImpact            = "impact"
+	TypeHarshBraking      = "harsh-braking"
+	TypeHarshAcceleration = "harsh-acceleration"
+	TypeHarshCornering    = "harsh-cornering"
+)
+
+// Time constant of the low-pass filter that estimates gravity from the motion samples.
+const gravityTimeConstant = 5 * time.Second
+
+// Struct to store a motion sample with the dynamic acceleration [mg] in vehicle axes.
+type Sample struct {
+	Time time.Time
+	X    float64
+	Y    float64
+	Z    float64
+}
+
+// Struct to store a detected event with the GPS position at the start of the event and the samples of the
+// pre-event and post-event window.
+type Event struct {
+	Id      uint64
+	Type    string
+	Time    time.Time
+	Peak    float64
+	Lat     float64
+	Lon     float64
+	Speed   float64
+	Samples []Sample
+}
+
+// Struct to store the detected events.
+type Detector struct {
+	Mutex      sync.RWMutex
+	StartTime  time.Time
+	LastUpdate time.Time
+	Events     []Event
+	nextId     uint64
+}
+
+// Store a detected event with mutex lock. Only the configured number of events is kept.
+func (data *Detector) Store(event Event) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.nextId++
+	event.Id = data.nextId
+	data.LastUpdate = time.Now()
+	data.Events = append(data.Events, event)
+
+	if uint64(len(data.Events)) > *global.EventHistory {
+		data.Events = data.Events[uint64(len(data.Events))-*global.EventHistory:]
+	}
+}
+
+// Get the last update and all events with an ID above the given ID with mutex lock. The last update is the start
+// time if no events were detected yet.
+func (data *Detector) GetSince(id uint64) (time.Time, []Event) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	events := []Event{}
+
+	for _, event := range data.Events {
+		if event.Id > id {
+			events = append(events, event)
+		}
+	}
+
+	if data.LastUpdate.IsZero() {
+		return data.StartTime, events
+	}
+
+	return data.LastUpdate, events
+}
+
+// Get the event type and peak of a dynamic acceleration, or an empty type if it is below all thresholds. Impacts
+// take precedence over harsh driving.
+func classify(x, y, z float64) (string, float64) {
+	if magnitude := math.Sqrt(x*x + y*y + z*z); magnitude >= *global.EventImpactThreshold {
+		return TypeImpact, magnitude
+	} else if -x >= *global.EventBrakingThreshold {
+		return TypeHarshBraking, -x
+	} else if x >= *global.EventAccelThreshold {
+		return TypeHarshAcceleration, x
+	} else if math.Abs(y) >= *global.EventCornerThreshold {
+		return TypeHarshCornering, math.Abs(y)
+	}
+
+	return "", 0
+}
+
+// Get the peak of a sample for an event type.
+func peak(eventType string, sample Sample) float64 {
+	switch eventType {
+	case TypeHarshBraking:
+		return -sample.X
+	case TypeHarshAcceleration:
+		return sample.X
+	case TypeHarshCornering:
+		return math.Abs(sample.Y)
+	}
+
+	return math.Sqrt(sample.X*sample.X + sample.Y*sample.Y + sample.Z*sample.Z)
+}
+
+// Start detecting events by sampling the motion sensor at the event sample rate.
+func (data *Detector) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan struct{}) error {
+	fmt.Printf("Starting event detector... ")
+
+	if *global.EventSampleRate <= 0 {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Invalid event sample rate: %f\n", *global.EventSampleRate)
+
+		close(done)
+
+		return errors.New("invalid event sample rate")
+	}
+
+	data.Mutex.Lock()
+	data.StartTime = time.Now()
+	data.Mutex.Unlock()
+
+	interval := time.Duration(float64(time.Second) / *global.EventSampleRate)
+	preWindow := time.Duration(*global.EventPreWindow * float64(time.Second))
+	postWindow := time.Duration(*global.EventPostWindow * float64(time.Second))
+	motionSamples := motionData.Subscribe(*global.EventSampleRate)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		buffer := []Sample{}
+		gravity := [3]float64{}
+		initialized := false
+		var active *Event
+		var activeEnd time.Time
+
+		for {
+			select {
+			case <-done:
+				global.Wg.Done()
+				return
+			case motionSample := <-motionSamples:
+				if !motionSample.Readable {
+					continue
+				}
+
+				now := motionSample.Time
+				x, y, z := motionSample.X, motionSample.Y, motionSample.Z
+
+				if !initialized {
+					gravity = [3]float64{x, y, z}
+					initialized = true
+				}
+
+				// Gravity is estimated by a low-pass filter, which is paused during an event.
+				if active == nil {
+					alpha := float64(interval) / float64(gravityTimeConstant+interval)
+					gravity[0] += alpha * (x - gravity[0])
+					gravity[1] += alpha * (y - gravity[1])
+					gravity[2] += alpha * (z - gravity[2])
+				}
+
+				sample := Sample{Time: now, X: x - gravity[0], Y: y - gravity[1], Z: z - gravity[2]}
+
+				if active != nil {
+					active.Samples = append(active.Samples, sample)
+					active.Peak = math.Max(active.Peak, peak(active.Type, sample))
+
+					if !now.Before(activeEnd) {
+						data.Store(*active)
+
+						if *global.Verbose {
+							fmt.Printf("[%v] Event detected: %s with peak %.0f mg\n", time.Now().UTC(), active.Type, active.Peak)
+						}
+
+						active = nil
+						buffer = []Sample{}
+					}
+
+					continue
+				}
+
+				buffer = append(buffer, sample)
+
+				for len(buffer) > 0 && now.Sub(buffer[0].Time) > preWindow {
+					buffer = buffer[1:]
+				}
+
+				if eventType, eventPeak := classify(sample.X, sample.Y, sample.Z); eventType != "" {
+					_, lat, lon, _, speed, _, _, _, _, _, _, _, _, _, _, _ := gpsData.GetTpv()
+
+					active = &Event{
+						Type:    eventType,
+						Time:    now,
+						Peak:    eventPeak,
+						Lat:     lat,
+						Lon:     lon,
+						Speed:   speed,
+						Samples: append([]Sample{}, buffer...),
+					}
+
+					activeEnd = now.Add(postWindow)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
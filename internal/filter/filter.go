@@ -0,0 +1,293 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Kalman filter motion models.
+const (
+	ModelConstantVelocity     = "cv"
+	ModelConstantAcceleration = "ca"
+)
+
+const (
+	earthRadius         = 6371008.8        // Mean radius [m] of the earth.
+	standardGravity     = 9.80665          // Standard gravity [m/s²], used to convert motion data to m/s².
+	biasTimeConstant    = 5 * time.Second  // Time constant of the low-pass filter that removes gravity and bias.
+	maxPredictionTime   = 10 * time.Second // Maximum time without a fix before the filter is reset.
+	maxOriginDistance   = 10000            // Maximum distance [m] from the origin of the local plane before moving it.
+	minHeadingSpeed     = 1                // Minimum speed [m/s] to rotate the acceleration to the local plane.
+	defaultEph          = 10               // Horizontal error [m] used if GPSd does not report one.
+	defaultEpv          = 20               // Vertical error [m] used if GPSd does not report one.
+	defaultEps          = 1                // Speed error [m/s] used if GPSd does not report one.
+	initialAcceleration = 1                // Initial variance [m²/s⁴] of the acceleration of the ca model.
+	errorDeviations     = 2                // GPSd errors are at 95% confidence, about 2 standard deviations.
+)
+
+// Struct to store the Kalman filtered GPS data. Velocities [m/s] are east, north, and up. Errors [m] and [m/s] are
+// at 95% confidence like GPSd.
+type Filter struct {
+	Mutex      sync.RWMutex
+	LastUpdate time.Time
+	Model      string
+	Lat        float64
+	Lon        float64
+	Alt        float64
+	VelE       float64
+	VelN       float64
+	VelU       float64
+	Eph        float64
+	Epv        float64
+	Eps        float64
+}
+
+// Store the filtered data with mutex lock.
+func (data *Filter) Store(lat, lon, alt, velE, velN, velU, eph, epv, eps float64) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.LastUpdate = time.Now()
+	data.Lat = lat
+	data.Lon = lon
+	data.Alt = alt
+	data.VelE = velE
+	data.VelN = velN
+	data.VelU = velU
+	data.Eph = eph
+	data.Epv = epv
+	data.Eps = eps
+}
+
+// Get the filtered data with mutex lock.
+func (data *Filter) Get() (time.Time, string, float64, float64, float64, float64, float64, float64, float64, float64, float64) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.LastUpdate, data.Model, data.Lat, data.Lon, data.Alt, data.VelE, data.VelN, data.VelU, data.Eph, data.Epv, data.Eps
+}
+
+// Get the speed [m/s] and heading [°] of a horizontal velocity.
+func SpeedHeading(velE, velN float64) (float64, float64) {
+	return math.Hypot(velE, velN), math.Mod(math.Atan2(velE, velN)*180/math.Pi+360, 360)
+}
+
+// Struct to store the Kalman filter state in a local plane around an origin.
+type state struct {
+	Initialized bool
+	OriginLat   float64
+	OriginLon   float64
+	East        axis
+	North       axis
+	Up          axis
+	LastFix     time.Time
+	Bias        [2]float64
+	BiasSet     bool
+}
+
+// Convert a position to the local plane around the origin. The plane is accurate enough within the maximum
+// origin distance.
+func (data *state) toLocal(lat, lon float64) (float64, float64) {
+	east := (lon - data.OriginLon) * math.Pi / 180 * earthRadius * math.Cos(data.OriginLat*math.Pi/180)
+	north := (lat - data.OriginLat) * math.Pi / 180 * earthRadius
+
+	return east, north
+}
+
+// Convert a position in the local plane around the origin to latitude and longitude.
+func (data *state) fromLocal(east, north float64) (float64, float64) {
+	lat := data.OriginLat + north/earthRadius*180/math.Pi
+	lon := data.OriginLon + east/(earthRadius*math.Cos(data.OriginLat*math.Pi/180))*180/math.Pi
+
+	return lat, lon
+}
+
+// Get a GPSd error, or the default if it is unknown, as a variance.
+func variance(value, defaultValue float64) float64 {
+	if value <= 0 || math.IsNaN(value) {
+		value = defaultValue
+	}
+
+	sigma := value / errorDeviations
+
+	return sigma * sigma
+}
+
+// Update the filter with the time since the last update, the last TPV report from GPSd, and the horizontal
+// acceleration [mg] in vehicle axes, if readable. Returns false if the filter is not initialized.
+func (data *state) step(now time.Time, dt float64, fix gps.TpvReport, x, y float64, readable bool) bool {
+	n := 2
+
+	if *global.FilterModel == ModelConstantAcceleration {
+		n = 3
+	}
+
+	newFix := fix.Mode >= 2 && fix.LastUpdate.After(data.LastFix)
+
+	if !data.Initialized {
+		if !newFix {
+			return false
+		}
+
+		data.OriginLat = fix.Lat
+		data.OriginLon = fix.Lon
+		data.LastFix = fix.LastUpdate
+		data.Initialized = true
+
+		velE := fix.Speed * math.Sin(fix.Track*math.Pi/180)
+		velN := fix.Speed * math.Cos(fix.Track*math.Pi/180)
+		data.East.init(n, 0, variance(fix.Eph, defaultEph)/2, velE, variance(fix.Eps, defaultEps)/2, initialAcceleration)
+		data.North.init(n, 0, variance(fix.Eph, defaultEph)/2, velN, variance(fix.Eps, defaultEps)/2, initialAcceleration)
+		data.Up.init(n, fix.Alt, variance(fix.Epv, defaultEpv), 0, variance(fix.Eps, defaultEps), initialAcceleration)
+
+		return true
+	}
+
+	if now.Sub(data.LastFix) > maxPredictionTime {
+		*data = state{Bias: data.Bias, BiasSet: data.BiasSet}
+
+		return false
+	}
+
+	// Gravity and the sensor bias are removed from the acceleration by a low-pass filter.
+	accelE, accelN := 0.0, 0.0
+	accelKnown := false
+
+	if readable {
+		if !data.BiasSet {
+			data.Bias = [2]float64{x, y}
+			data.BiasSet = true
+		}
+
+		alpha := dt / (biasTimeConstant.Seconds() + dt)
+		data.Bias[0] += alpha * (x - data.Bias[0])
+		data.Bias[1] += alpha * (y - data.Bias[1])
+
+		// The acceleration can only be rotated from the vehicle axes to the local plane if the heading is known.
+		speed, heading := SpeedHeading(data.East.X[1], data.North.X[1])
+
+		if speed >= minHeadingSpeed {
+			forward := (x - data.Bias[0]) / 1000 * standardGravity
+			left := (y - data.Bias[1]) / 1000 * standardGravity
+			sin, cos := math.Sincos(heading * math.Pi / 180)
+			accelE = forward*sin - left*cos
+			accelN = forward*cos + left*sin
+			accelKnown = true
+		}
+	}
+
+	processVariance := *global.FilterProcessNoise * *global.FilterProcessNoise
+	accelVariance := *global.FilterAccelNoise * *global.FilterAccelNoise
+	horizontalVariance := processVariance
+
+	if n == 2 && accelKnown {
+		horizontalVariance += accelVariance
+	}
+
+	data.East.predict(dt, accelE, horizontalVariance)
+	data.North.predict(dt, accelN, horizontalVariance)
+	data.Up.predict(dt, 0, processVariance)
+
+	if n == 3 && accelKnown {
+		data.East.update(2, accelE, accelVariance)
+		data.North.update(2, accelN, accelVariance)
+	}
+
+	if newFix {
+		data.LastFix = fix.LastUpdate
+		east, north := data.toLocal(fix.Lat, fix.Lon)
+		velE := fix.Speed * math.Sin(fix.Track*math.Pi/180)
+		velN := fix.Speed * math.Cos(fix.Track*math.Pi/180)
+
+		data.East.update(0, east, variance(fix.Eph, defaultEph)/2)
+		data.North.update(0, north, variance(fix.Eph, defaultEph)/2)
+		data.East.update(1, velE, variance(fix.Eps, defaultEps)/2)
+		data.North.update(1, velN, variance(fix.Eps, defaultEps)/2)
+
+		if fix.Mode >= 3 {
+			data.Up.update(0, fix.Alt, variance(fix.Epv, defaultEpv))
+		}
+	}
+
+	// Move the origin to the current position to keep the local plane accurate.
+	if math.Hypot(data.East.X[0], data.North.X[0]) > maxOriginDistance {
+		data.OriginLat, data.OriginLon = data.fromLocal(data.East.X[0], data.North.X[0])
+		data.East.X[0] = 0
+		data.North.X[0] = 0
+	}
+
+	return true
+}
+
+// Start filtering the GPS data at the filter rate.
+func (data *Filter) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan struct{}) error {
+	fmt.Printf("Starting Kalman filter... ")
+
+	if *global.FilterModel != ModelConstantVelocity && *global.FilterModel != ModelConstantAcceleration {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Unknown Kalman filter model: %s\n", *global.FilterModel)
+
+		close(done)
+
+		return errors.New("unknown Kalman filter model " + *global.FilterModel)
+	}
+
+	if *global.FilterRate <= 0 {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Invalid Kalman filter rate: %f\n", *global.FilterRate)
+
+		close(done)
+
+		return errors.New("invalid Kalman filter rate")
+	}
+
+	data.Mutex.Lock()
+	data.Model = *global.FilterModel
+	data.Mutex.Unlock()
+
+	motionSamples := motionData.Subscribe(*global.FilterRate)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		filterState := state{}
+		last := time.Now()
+
+		for {
+			select {
+			case <-done:
+				global.Wg.Done()
+				return
+			case sample := <-motionSamples:
+				now := sample.Time
+				dt := now.Sub(last).Seconds()
+				last = now
+
+				if !filterState.step(now, dt, gpsData.GetFix(), sample.X, sample.Y, sample.Readable) {
+					continue
+				}
+
+				// The filter estimates the antenna position, which is moved to the control point like the GPS position.
+				lat, lon := filterState.fromLocal(filterState.East.X[0], filterState.North.X[0])
+				lat, lon, alt := gpsData.ControlPoint(lat, lon, filterState.Up.X[0])
+				eph := errorDeviations * math.Sqrt(filterState.East.P[0][0]+filterState.North.P[0][0])
+				epv := errorDeviations * math.Sqrt(filterState.Up.P[0][0])
+				eps := errorDeviations * math.Sqrt(filterState.East.P[1][1]+filterState.North.P[1][1])
+
+				data.Store(lat, lon, alt, filterState.East.X[1], filterState.North.X[1], filterState.Up.X[1], eph, epv, eps)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package filter
+
+// Struct to store the Kalman filter state of a single axis. The state is the position [m] and velocity [m/s], and
+// the acceleration [m/s²] for the constant acceleration model. Only the first N states are used.
+type axis struct {
+	N int
+	X [3]float64
+	P [3][3]float64
+}
+
+// Initialize the state with a position and velocity and their variances. The acceleration starts at 0.
+func (data *axis) init(n int, position, positionVariance, velocity, velocityVariance, accelerationVariance float64) {
+	data.N = n
+	data.X = [3]float64{position, velocity, 0}
+	data.P = [3][3]float64{{positionVariance}, {0, velocityVariance}, {0, 0, accelerationVariance}}
+}
+
+// Predict the state after dt [s]. The constant velocity model uses the acceleration input [m/s²] and the process
+// noise is a white acceleration with the given variance. The constant acceleration model ignores the input and the
+// process noise is a white jerk with the given variance.
+func (data *axis) predict(dt, input, noiseVariance float64) {
+	f := [3][3]float64{{1, dt, 0}, {0, 1, 0}, {0, 0, 1}}
+	g := [3]float64{dt * dt / 2, dt, 0}
+
+	if data.N == 3 {
+		f[0][2] = dt * dt / 2
+		f[1][2] = dt
+		g = [3]float64{dt * dt * dt / 6, dt * dt / 2, dt}
+		input = 0
+	}
+
+	x := [3]float64{}
+	fp := [3][3]float64{}
+
+	for i := 0; i < data.N; i++ {
+		for j := 0; j < data.N; j++ {
+			x[i] += f[i][j] * data.X[j]
+
+			for k := 0; k < data.N; k++ {
+				fp[i][j] += f[i][k] * data.P[k][j]
+			}
+		}
+	}
+
+	// The input enters the state the same way as the process noise, after the state transition.
+	for i := 0; i < data.N; i++ {
+		x[i] += g[i] * input
+	}
+
+	p := [3][3]float64{}
+
+	for i := 0; i < data.N; i++ {
+		for j := 0; j < data.N; j++ {
+			for k := 0; k < data.N; k++ {
+				p[i][j] += fp[i][k] * f[j][k]
+			}
+
+			p[i][j] += g[i] * g[j] * noiseVariance
+		}
+	}
+
+	data.X = x
+	data.P = p
+}
+
+// Update the state with a measurement of a single state, e.g. 0 for the position, with the measurement variance.
+func (data *axis) update(index int, measurement, variance float64) {
+	s := data.P[index][index] + variance
+
+	if s <= 0 {
+		return
+	}
+
+	k := [3]float64{}
+	row := data.P[index]
+	innovation := measurement - data.X[index]
+
+	for i := 0; i < data.N; i++ {
+		k[i] = data.P[i][index] / s
+		data.X[i] += k[i] * innovation
+	}
+
+	for i := 0; i < data.N; i++ {
+		for j := 0; j < data.N; j++ {
+			data.P[i][j] -= k[i] * row[j]
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"math"
+	"testing"
+)
+
+const tolerance = 1e-9
+
+func TestAxisPredict(t *testing.T) {
+	tests := []struct {
+		name          string
+		axis          axis
+		dt            float64
+		input         float64
+		noiseVariance float64
+		wantX         [3]float64
+		wantP         [3][3]float64
+	}{
+		{
+			name:  "constant velocity without input",
+			axis:  axis{N: 2, X: [3]float64{10, 2}},
+			dt:    2,
+			wantX: [3]float64{14, 2},
+		},
+		{
+			name:  "constant velocity with acceleration input",
+			axis:  axis{N: 2, X: [3]float64{10, 2}},
+			dt:    2,
+			input: 1.5,
+			wantX: [3]float64{17, 5},
+		},
+		{
+			name:          "constant velocity covariance",
+			axis:          axis{N: 2, P: [3][3]float64{{1, 0}, {0, 4}}},
+			dt:            2,
+			noiseVariance: 0.5,
+			wantP:         [3][3]float64{{19, 10}, {10, 6}},
+		},
+		{
+			name:  "constant acceleration ignores input",
+			axis:  axis{N: 3, X: [3]float64{0, 1, 2}},
+			dt:    1,
+			input: 5,
+			wantX: [3]float64{2, 3, 2},
+		},
+		{
+			name:          "constant acceleration covariance",
+			axis:          axis{N: 3, P: [3][3]float64{{0}, {0, 0}, {0, 0, 1}}},
+			dt:            2,
+			noiseVariance: 0,
+			wantP:         [3][3]float64{{4, 4, 2}, {4, 4, 2}, {2, 2, 1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := test.axis
+			data.predict(test.dt, test.input, test.noiseVariance)
+
+			for i := 0; i < 3; i++ {
+				if math.Abs(data.X[i]-test.wantX[i]) > tolerance {
+					t.Errorf("X = %v, want %v", data.X, test.wantX)
+
+					break
+				}
+			}
+
+			for i := 0; i < 3; i++ {
+				for j := 0; j < 3; j++ {
+					if math.Abs(data.P[i][j]-test.wantP[i][j]) > tolerance {
+						t.Fatalf("P = %v, want %v", data.P, test.wantP)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAxisUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		axis        axis
+		index       int
+		measurement float64
+		variance    float64
+		wantX       [3]float64
+		wantP       [3][3]float64
+	}{
+		{
+			name:        "uncorrelated position",
+			axis:        axis{N: 2, P: [3][3]float64{{4, 0}, {0, 1}}},
+			index:       0,
+			measurement: 10,
+			variance:    4,
+			wantX:       [3]float64{5, 0},
+			wantP:       [3][3]float64{{2, 0}, {0, 1}},
+		},
+		{
+			name:        "correlated position corrects velocity",
+			axis:        axis{N: 2, P: [3][3]float64{{2, 1}, {1, 1}}},
+			index:       0,
+			measurement: 3,
+			variance:    2,
+			wantX:       [3]float64{1.5, 0.75},
+			wantP:       [3][3]float64{{1, 0.5}, {0.5, 0.75}},
+		},
+		{
+			name:        "velocity",
+			axis:        axis{N: 2, X: [3]float64{0, 1}, P: [3][3]float64{{1, 0}, {0, 1}}},
+			index:       1,
+			measurement: 3,
+			variance:    1,
+			wantX:       [3]float64{0, 2},
+			wantP:       [3][3]float64{{1, 0}, {0, 0.5}},
+		},
+		{
+			name:        "zero innovation variance is ignored",
+			axis:        axis{N: 2, X: [3]float64{1, 1}},
+			index:       0,
+			measurement: 5,
+			variance:    0,
+			wantX:       [3]float64{1, 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := test.axis
+			data.update(test.index, test.measurement, test.variance)
+
+			for i := 0; i < 3; i++ {
+				if math.Abs(data.X[i]-test.wantX[i]) > tolerance {
+					t.Errorf("X = %v, want %v", data.X, test.wantX)
+
+					break
+				}
+			}
+
+			for i := 0; i < 3; i++ {
+				for j := 0; j < 3; j++ {
+					if math.Abs(data.P[i][j]-test.wantP[i][j]) > tolerance {
+						t.Fatalf("P = %v, want %v", data.P, test.wantP)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Struct to store a position in earth-centered, earth-fixed (ECEF) coordinates [m].
+type Ecef struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+// Struct to store a position in local east, north, up (ENU) coordinates [m] relative to an origin.
+type Enu struct {
+	East  float64
+	North float64
+	Up    float64
+}
+
+// Struct to store the origin of the local tangent plane.
+type Origin struct {
+	Lat  float64
+	Lon  float64
+	Alt  float64
+	ecef Ecef
+}
+
+// Convert a WGS84 position with the height [m] above the ellipsoid to ECEF.
+func ToEcef(lat, lon, alt float64) Ecef {
+	eSq := Flattening * (2 - Flattening)
+	sinLat, cosLat := math.Sincos(radians(lat))
+	sinLon, cosLon := math.Sincos(radians(lon))
+	radius := SemiMajorAxis / math.Sqrt(1-eSq*sinLat*sinLat)
+
+	return Ecef{
+		X: (radius + alt) * cosLat * cosLon,
+		Y: (radius + alt) * cosLat * sinLon,
+		Z: (radius*(1-eSq) + alt) * sinLat,
+	}
+}
+
+// Parse an origin of comma separated lat [°], lon [°], and alt [m] values.
+func ParseOrigin(value string) (*Origin, error) {
+	values := strings.Split(value, ",")
+
+	if len(values) != 3 {
+		return nil, errors.New("origin requires 3 comma separated values")
+	}
+
+	numbers := [3]float64{}
+
+	for i, value := range values {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+		if err != nil {
+			return nil, errors.New("invalid origin value " + value)
+		}
+
+		numbers[i] = number
+	}
+
+	if math.Abs(numbers[0]) > 90 || math.Abs(numbers[1]) > 180 {
+		return nil, errors.New("origin is not a valid position")
+	}
+
+	return &Origin{Lat: numbers[0], Lon: numbers[1], Alt: numbers[2], ecef: ToEcef(numbers[0], numbers[1], numbers[2])}, nil
+}
+
+// Convert a WGS84 position to ENU relative to the origin.
+func (origin *Origin) ToEnu(lat, lon, alt float64) Enu {
+	ecef := ToEcef(lat, lon, alt)
+	dx := ecef.X - origin.ecef.X
+	dy := ecef.Y - origin.ecef.Y
+	dz := ecef.Z - origin.ecef.Z
+	sinLat, cosLat := math.Sincos(radians(origin.Lat))
+	sinLon, cosLon := math.Sincos(radians(origin.Lon))
+
+	return Enu{
+		East:  -sinLon*dx + cosLon*dy,
+		North: -sinLat*cosLon*dx - sinLat*sinLon*dy + cosLat*dz,
+		Up:    cosLat*cosLon*dx + cosLat*sinLon*dy + sinLat*dz,
+	}
+}
+
+// Move a WGS84 position by east, north, and up [m] using the radii of curvature of the ellipsoid at the position.
+// Accurate for offsets of up to a few kilometers.
+func Offset(lat, lon, alt, east, north, up float64) (float64, float64, float64) {
+	eSq := Flattening * (2 - Flattening)
+	sinLat, cosLat := math.Sincos(radians(lat))
+	w := math.Sqrt(1 - eSq*sinLat*sinLat)
+	meridian := SemiMajorAxis*(1-eSq)/(w*w*w) + alt
+	normal := SemiMajorAxis/w + alt
+
+	return lat + north/meridian*180/math.Pi, lon + east/(normal*cosLat)*180/math.Pi, alt + up
+}
@@ -0,0 +1,74 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToEcef(t *testing.T) {
+	tests := []struct {
+		name string
+		lat  float64
+		lon  float64
+		alt  float64
+		want Ecef
+	}{
+		{"equator and prime meridian", 0, 0, 0, Ecef{SemiMajorAxis, 0, 0}},
+		{"equator and 90° east", 0, 90, 100, Ecef{0, SemiMajorAxis + 100, 0}},
+		{"north pole", 90, 0, 0, Ecef{0, 0, SemiMinorAxis}},
+		{"south pole", -90, 0, 10, Ecef{0, 0, -SemiMinorAxis - 10}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ToEcef(test.lat, test.lon, test.alt)
+
+			if math.Abs(got.X-test.want.X) > 0.001 || math.Abs(got.Y-test.want.Y) > 0.001 || math.Abs(got.Z-test.want.Z) > 0.001 {
+				t.Errorf("ToEcef(%v, %v, %v) = %+v, want %+v", test.lat, test.lon, test.alt, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseOrigin(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"52.1, 5.2, 10", true},
+		{"52.1,5.2", false},
+		{"52.1,5.2,a", false},
+		{"91,5.2,0", false},
+		{"52.1,181,0", false},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseOrigin(test.value); (err == nil) != test.valid {
+			t.Errorf("ParseOrigin(%q) error = %v, want valid %v", test.value, err, test.valid)
+		}
+	}
+}
+
+func TestOffsetToEnu(t *testing.T) {
+	origin, err := ParseOrigin("52.1,5.2,10")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []Enu{{0, 0, 0}, {1.2, -0.8, 2.5}, {100, 0, 0}, {0, -250, 0}, {0, 0, 20}, {-300, 400, -5}}
+
+	for _, want := range tests {
+		lat, lon, alt := Offset(origin.Lat, origin.Lon, origin.Alt, want.East, want.North, want.Up)
+		got := origin.ToEnu(lat, lon, alt)
+
+		// Offset moves along the ellipsoid, which curves away from the local tangent plane.
+		distance := math.Hypot(want.East, want.North)
+		horizontal := 0.001 + distance*1e-4
+		vertical := 0.001 + distance*distance/(2*EarthRadius)
+
+		if math.Abs(got.East-want.East) > horizontal || math.Abs(got.North-want.North) > horizontal || math.Abs(got.Up-want.Up) > vertical {
+			t.Errorf("ToEnu(Offset(%+v)) = %+v", want, got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package geo
+
+import "math"
+
+// WGS84 ellipsoid.
+const (
+	SemiMajorAxis = 6378137.0
+	Flattening    = 1 / 298.257223563
+	SemiMinorAxis = SemiMajorAxis * (1 - Flattening)
+)
+
+// Mean radius [m] of the earth.
+const EarthRadius = 6371008.8
+
+// Convert degrees to radians.
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Get the great circle distance [m] between two positions on a sphere with the mean earth radius.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLon := radians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Get the geodesic distance [m] between two positions on the WGS84 ellipsoid using the Vincenty inverse formula.
+// Falls back to the haversine distance for nearly antipodal positions where the formula does not converge.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	if lat1 == lat2 && lon1 == lon2 {
+		return 0
+	}
+
+	l := radians(lon2 - lon1)
+	u1 := math.Atan((1 - Flattening) * math.Tan(radians(lat1)))
+	u2 := math.Atan((1 - Flattening) * math.Tan(radians(lat2)))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+	lambda := l
+
+	for i := 0; i < 100; i++ {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma := math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+
+		if sinSigma == 0 {
+			return 0
+		}
+
+		cosSigma := sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma := math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha := 1 - sinAlpha*sinAlpha
+		cos2SigmaM := 0.0
+
+		// The geodesic is along the equator if cos²α is 0.
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+
+		c := Flattening / 16 * cosSqAlpha * (4 + Flattening*(4-3*cosSqAlpha))
+		previous := lambda
+		lambda = l + (1-c)*Flattening*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+
+		if math.Abs(lambda-previous) < 1e-12 {
+			uSq := cosSqAlpha * (SemiMajorAxis*SemiMajorAxis - SemiMinorAxis*SemiMinorAxis) / (SemiMinorAxis * SemiMinorAxis)
+			a := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+			b := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+			deltaSigma := b * sinSigma * (cos2SigmaM + b/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-b/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+
+			return SemiMinorAxis * a * (sigma - deltaSigma)
+		}
+	}
+
+	return Haversine(lat1, lon1, lat2, lon2)
+}
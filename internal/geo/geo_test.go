@@ -0,0 +1,41 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name      string
+		lat1      float64
+		lon1      float64
+		lat2      float64
+		lon2      float64
+		want      float64
+		tolerance float64
+	}{
+		{"same position", 52, 5, 52, 5, 0, 0},
+		{"one degree along the equator", 0, 0, 0, 1, 111319.491, 0.001},
+		{"equator to pole", 0, 0, 90, 0, 10001965.729, 0.001},
+		{"Flinders Peak to Buninyong", -37.95103341666667, 144.42486788888888, -37.65282113888889, 143.92649552777777, 54972.271, 0.001},
+		{"symmetric", -37.65282113888889, 143.92649552777777, -37.95103341666667, 144.42486788888888, 54972.271, 0.001},
+		{"nearly antipodal falls back to haversine", 0, 0, 0.5, 179.7, Haversine(0, 0, 0.5, 179.7), 0.001},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Distance(test.lat1, test.lon1, test.lat2, test.lon2); math.Abs(got-test.want) > test.tolerance {
+				t.Errorf("Distance(%v, %v, %v, %v) = %.4f, want %.4f", test.lat1, test.lon1, test.lat2, test.lon2, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHaversine(t *testing.T) {
+	want := EarthRadius * math.Pi / 180
+
+	if got := Haversine(0, 0, 0, 1); math.Abs(got-want) > 0.001 {
+		t.Errorf("Haversine(0, 0, 0, 1) = %.4f, want %.4f", got, want)
+	}
+}
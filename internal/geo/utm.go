@@ -0,0 +1,135 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// UTM projection.
+const (
+	utmScale         = 0.9996
+	utmFalseEasting  = 500000.0
+	utmFalseNorthing = 10000000.0
+)
+
+// Latitude bands from 80°S to 84°N. Band X spans 12° instead of 8°.
+const latitudeBands = "CDEFGHJKLMNPQRSTUVWXX"
+
+// MGRS 100 km square column letters per set of zones, and row letters for odd and even zones.
+var (
+	mgrsColumns = [3]string{"ABCDEFGH", "JKLMNPQR", "STUVWXYZ"}
+	mgrsRows    = [2]string{"ABCDEFGHJKLMNPQRSTUV", "FGHJKLMNPQRSTUVABCDE"}
+)
+
+// Struct to store a UTM position. The northing includes the false northing on the southern hemisphere.
+type Utm struct {
+	Zone     uint8
+	Band     byte
+	Easting  float64
+	Northing float64
+}
+
+// Get the UTM zone of a position, including the exceptions for Norway and Svalbard.
+func utmZone(lat, lon float64) int {
+	zone := int(math.Floor((lon+180)/6)) + 1
+
+	if zone > 60 {
+		zone = 1
+	}
+
+	if lat >= 56 && lat < 64 && lon >= 3 && lon < 12 {
+		return 32
+	}
+
+	if lat >= 72 && lon >= 0 && lon < 42 {
+		switch {
+		case lon < 9:
+			return 31
+		case lon < 21:
+			return 33
+		case lon < 33:
+			return 35
+		default:
+			return 37
+		}
+	}
+
+	return zone
+}
+
+// Convert a WGS84 position to UTM using the Krüger series to the sixth order, which is accurate to well below a
+// millimeter within the zone. UTM is only defined between 80°S and 84°N.
+func ToUtm(lat, lon float64) (Utm, error) {
+	if lat < -80 || lat > 84 {
+		return Utm{}, errors.New("latitude outside of the UTM range")
+	}
+
+	zone := utmZone(lat, lon)
+	centralMeridian := float64(zone-1)*6 - 180 + 3
+	n := Flattening / (2 - Flattening)
+	e := math.Sqrt(Flattening * (2 - Flattening))
+	phi := radians(lat)
+	sinLambda, cosLambda := math.Sincos(radians(lon - centralMeridian))
+
+	// Conformal latitude.
+	tau := math.Tan(phi)
+	sigma := math.Sinh(e * math.Atanh(e*tau/math.Sqrt(1+tau*tau)))
+	tauPrime := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+
+	// Transverse Mercator on the sphere.
+	xiPrime := math.Atan2(tauPrime, cosLambda)
+	etaPrime := math.Asinh(sinLambda / math.Sqrt(tauPrime*tauPrime+cosLambda*cosLambda))
+
+	n2, n3, n4, n5, n6 := n*n, n*n*n, n*n*n*n, n*n*n*n*n, n*n*n*n*n*n
+	a := SemiMajorAxis / (1 + n) * (1 + n2/4 + n4/64 + n6/256)
+	alpha := [6]float64{
+		n/2 - 2.0/3*n2 + 5.0/16*n3 + 41.0/180*n4 - 127.0/288*n5 + 7891.0/37800*n6,
+		13.0/48*n2 - 3.0/5*n3 + 557.0/1440*n4 + 281.0/630*n5 - 1983433.0/1935360*n6,
+		61.0/240*n3 - 103.0/140*n4 + 15061.0/26880*n5 + 167603.0/181440*n6,
+		49561.0/161280*n4 - 179.0/168*n5 + 6601661.0/7257600*n6,
+		34729.0/80640*n5 - 3418889.0/1995840*n6,
+		212378941.0 / 319334400 * n6,
+	}
+
+	xi := xiPrime
+	eta := etaPrime
+
+	for j := 1; j <= 6; j++ {
+		xi += alpha[j-1] * math.Sin(2*float64(j)*xiPrime) * math.Cosh(2*float64(j)*etaPrime)
+		eta += alpha[j-1] * math.Cos(2*float64(j)*xiPrime) * math.Sinh(2*float64(j)*etaPrime)
+	}
+
+	utm := Utm{
+		Zone:     uint8(zone),
+		Band:     latitudeBands[int(math.Min(math.Floor(lat/8+10), 20))],
+		Easting:  utmScale*a*eta + utmFalseEasting,
+		Northing: utmScale * a * xi,
+	}
+
+	if lat < 0 {
+		utm.Northing += utmFalseNorthing
+	}
+
+	return utm, nil
+}
+
+// Get the MGRS grid reference of a UTM position with the given number of digits per coordinate, e.g. 5 for a
+// precision of 1 m. The coordinates are truncated, so the reference is the square the position is in.
+func (utm Utm) Mgrs(digits int) string {
+	digits = int(math.Max(0, math.Min(5, float64(digits))))
+	column := int(math.Floor(utm.Easting/100000)) - 1
+	row := int(math.Floor(utm.Northing/100000)) % 20
+	columns := mgrsColumns[(utm.Zone-1)%3]
+	rows := mgrsRows[(utm.Zone-1)%2]
+
+	divisor := math.Pow(10, float64(5-digits))
+	easting := int(math.Floor(math.Mod(utm.Easting, 100000) / divisor))
+	northing := int(math.Floor(math.Mod(utm.Northing, 100000) / divisor))
+
+	if digits == 0 {
+		return fmt.Sprintf("%d%c%c%c", utm.Zone, utm.Band, columns[column], rows[row])
+	}
+
+	return fmt.Sprintf("%d%c%c%c%0*d%0*d", utm.Zone, utm.Band, columns[column], rows[row], digits, easting, digits, northing)
+}
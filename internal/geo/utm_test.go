@@ -0,0 +1,92 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToUtm(t *testing.T) {
+	// Expected values are from the USGS series of Snyder, which agrees to a millimeter close to the central meridian.
+	tests := []struct {
+		name     string
+		lat      float64
+		lon      float64
+		zone     uint8
+		band     byte
+		easting  float64
+		northing float64
+	}{
+		{"central meridian", 45, 3, 31, 'T', 500000, 4982950.400},
+		{"equator", 0, 0, 31, 'N', 166021.443, 0},
+		{"northern hemisphere", 52, 5, 31, 'U', 637294.366, 5762926.813},
+		{"western hemisphere", 38.8895, -77.0353, 18, 'S', 323478.063, 4306483.242},
+		{"southern hemisphere", -33.8568, 151.2153, 56, 'H', 334900.570, 6252288.753},
+		{"southern central meridian", -45, -69, 19, 'G', 500000, 5017049.600},
+		{"Norway exception", 60, 5, 32, 'V', 276979.926, 6658157.202},
+		{"Svalbard exception", 78, 10, 33, 'X', 384085.475, 8663320.201},
+		{"antimeridian", 0, 180, 1, 'N', 166021.443, 0},
+		{"northern limit", 84, 0, 31, 'X', 465005.345, 9329005.182},
+		{"southern limit", -80, 0, 31, 'C', 441867.785, 1116915.044},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			utm, err := ToUtm(test.lat, test.lon)
+
+			if err != nil {
+				t.Fatalf("ToUtm(%v, %v) returned error %s", test.lat, test.lon, err)
+			}
+
+			if utm.Zone != test.zone || utm.Band != test.band {
+				t.Errorf("ToUtm(%v, %v) zone = %d%c, want %d%c", test.lat, test.lon, utm.Zone, utm.Band, test.zone, test.band)
+			}
+
+			if math.Abs(utm.Easting-test.easting) > 0.002 || math.Abs(utm.Northing-test.northing) > 0.002 {
+				t.Errorf("ToUtm(%v, %v) = %.3f, %.3f, want %.3f, %.3f", test.lat, test.lon, utm.Easting, utm.Northing, test.easting, test.northing)
+			}
+		})
+	}
+}
+
+func TestToUtmOutOfRange(t *testing.T) {
+	for _, lat := range []float64{-80.1, 84.1, 90, -90} {
+		if _, err := ToUtm(lat, 0); err == nil {
+			t.Errorf("ToUtm(%v, 0) returned no error", lat)
+		}
+	}
+}
+
+func TestMgrs(t *testing.T) {
+	tests := []struct {
+		name   string
+		lat    float64
+		lon    float64
+		digits int
+		want   string
+	}{
+		{"1 m", 38.8895, -77.0353, 5, "18SUJ2347806483"},
+		{"10 m", 38.8895, -77.0353, 4, "18SUJ23470648"},
+		{"1 km", 38.8895, -77.0353, 2, "18SUJ2306"},
+		{"100 km square", 38.8895, -77.0353, 0, "18SUJ"},
+		{"digits are clamped", 38.8895, -77.0353, 7, "18SUJ2347806483"},
+		{"first column set", 45, 3, 5, "31TEK0000082950"},
+		{"even zone rows", 52, 5, 5, "31UFT3729462926"},
+		{"southern hemisphere", -33.8568, 151.2153, 5, "56HLH3490052288"},
+		{"Norway exception", 60, 5, 3, "32VKM769581"},
+		{"equator", 0, 0, 5, "31NAA6602100000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			utm, err := ToUtm(test.lat, test.lon)
+
+			if err != nil {
+				t.Fatalf("ToUtm(%v, %v) returned error %s", test.lat, test.lon, err)
+			}
+
+			if got := utm.Mgrs(test.digits); got != test.want {
+				t.Errorf("Mgrs(%d) = %s, want %s", test.digits, got, test.want)
+			}
+		})
+	}
+}
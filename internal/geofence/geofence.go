@@ -0,0 +1,252 @@
+package geofence
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+)
+
+// Event types.
+const (
+	TypeEnter = "enter"
+	TypeExit  = "exit"
+)
+
+// Interval between checks for a new GPS fix.
+const pollInterval = 100 * time.Millisecond
+
+// Struct to store a geofence entry or exit with the GPS position.
+type Event struct {
+	Id    uint64
+	Type  string
+	Zone  string
+	Index int
+	Time  time.Time
+	Lat   float64
+	Lon   float64
+}
+
+// Struct to store the geofences, the GeoJSON they are parsed from, the membership per zone, and the entry and exit
+// events.
+type Geofences struct {
+	Mutex      sync.RWMutex
+	LastUpdate time.Time
+	GeoJson    []byte
+	Zones      []Zone
+	Inside     []bool
+	Events     []Event
+	nextId     uint64
+}
+
+// Store the zones with mutex lock. The membership is reset, so zones the vehicle is inside are entered again.
+func (data *Geofences) storeZones(content []byte, zones []Zone) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.LastUpdate = time.Now()
+	data.GeoJson = content
+	data.Zones = zones
+	data.Inside = make([]bool, len(zones))
+}
+
+// Save the geofence file. The file is replaced atomically, so the geofences are not lost if saving fails halfway.
+func saveGeoJson(path string, content []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), ".geofences-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	if err := temp.Chmod(0644); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+// Replace the geofences with a GeoJSON feature collection and the zones parsed from it, and save it to the geofence
+// file.
+func (data *Geofences) Replace(content []byte, zones []Zone) error {
+	if err := saveGeoJson(*global.GeofenceFile, content); err != nil {
+		return err
+	}
+
+	data.storeZones(content, zones)
+
+	return nil
+}
+
+// Get the GeoJSON of the geofences with mutex lock.
+func (data *Geofences) GetGeoJson() []byte {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.GeoJson
+}
+
+// Get the last update, the zones, and whether the vehicle is inside each zone with mutex lock.
+func (data *Geofences) GetZones() (time.Time, []Zone, []bool) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.LastUpdate, data.Zones, append([]bool{}, data.Inside...)
+}
+
+// Get the membership bitfield with mutex lock, where bit N is set while inside the Nth zone.
+func (data *Geofences) GetMembership() uint64 {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	membership := uint64(0)
+
+	for index, inside := range data.Inside {
+		if inside {
+			membership |= 1 << index
+		}
+	}
+
+	return membership
+}
+
+// Get all events with an ID above the given ID with mutex lock.
+func (data *Geofences) GetSince(id uint64) []Event {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	events := []Event{}
+
+	for _, event := range data.Events {
+		if event.Id > id {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// Evaluate a GPS position against the zones with mutex lock. A zone is entered at the boundary and exited once the
+// position is outside by the hysteresis distance, so GPS noise near the boundary does not cause repeated events.
+func (data *Geofences) update(lat, lon float64) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	for index := range data.Zones {
+		zone := &data.Zones[index]
+		distance := zone.Distance(lat, lon)
+		eventType := ""
+
+		if !data.Inside[index] && distance <= 0 {
+			eventType = TypeEnter
+		} else if data.Inside[index] && distance > *global.GeofenceHysteresis {
+			eventType = TypeExit
+		}
+
+		if eventType == "" {
+			continue
+		}
+
+		data.Inside[index] = eventType == TypeEnter
+		data.nextId++
+		data.LastUpdate = time.Now()
+		data.Events = append(data.Events, Event{
+			Id:    data.nextId,
+			Type:  eventType,
+			Zone:  zone.Name,
+			Index: index,
+			Time:  time.Now().UTC(),
+			Lat:   lat,
+			Lon:   lon,
+		})
+
+		if uint64(len(data.Events)) > *global.GeofenceHistory {
+			data.Events = data.Events[uint64(len(data.Events))-*global.GeofenceHistory:]
+		}
+
+		if *global.Verbose {
+			fmt.Printf("[%v] Geofence %s: %s\n", time.Now().UTC(), eventType, zone.Name)
+		}
+	}
+}
+
+// Start evaluating each new GPS fix against the geofences. An empty zone list is used if the geofence file does
+// not exist yet.
+func (data *Geofences) Start(gpsData *gps.Gps, done chan struct{}) error {
+	fmt.Printf("Starting geofencing... ")
+
+	content, err := os.ReadFile(*global.GeofenceFile)
+
+	if errors.Is(err, os.ErrNotExist) {
+		content, err = []byte(`{"type": "FeatureCollection", "features": []}`), nil
+	}
+
+	zones := []Zone{}
+
+	if err == nil {
+		zones, err = ParseZones(content)
+	}
+
+	if err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Failed to load geofences from %s: %s\n", *global.GeofenceFile, err)
+
+		close(done)
+
+		return err
+	}
+
+	data.storeZones(content, zones)
+
+	ticker := time.NewTicker(pollInterval)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		lastFix := time.Time{}
+
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				global.Wg.Done()
+				return
+			case <-ticker.C:
+				lastUpdate, lat, lon, _, _, mode, _, _, _, _, _, _, _, _, _, _ := gpsData.GetTpv()
+
+				if mode >= 2 && lastUpdate.After(lastFix) {
+					lastFix = lastUpdate
+					data.update(lat, lon)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package geofence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
+)
+
+// Maximum number of zones, limited by the membership bitfield.
+const MaxZones = 64
+
+// Struct to store a GeoJSON feature collection.
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+// Struct to store a GeoJSON feature.
+type feature struct {
+	Type       string                 `json:"type"`
+	Geometry   geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Struct to store a GeoJSON geometry.
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Struct to store a zone. A circle has a center and radius [m], a polygon has rings of [lon, lat] positions where
+// the first ring is the outer boundary and the other rings are holes. Properties are copied from the GeoJSON feature.
+type Zone struct {
+	Name       string
+	Center     [2]float64
+	Radius     float64
+	Polygons   [][][][2]float64
+	Properties map[string]interface{}
+}
+
+// Parse a GeoJSON feature collection with Polygon, MultiPolygon, and Point features. Points are circles and require
+// a radius [m] property. The name property names the zone, otherwise the zone is named by its index.
+func ParseZones(content []byte) ([]Zone, error) {
+	collection := featureCollection{}
+
+	if err := json.Unmarshal(content, &collection); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != "FeatureCollection" {
+		return nil, errors.New("GeoJSON must be a FeatureCollection")
+	}
+
+	if len(collection.Features) > MaxZones {
+		return nil, fmt.Errorf("at most %d geofences are supported", MaxZones)
+	}
+
+	zones := []Zone{}
+
+	for index, feature := range collection.Features {
+		zone := Zone{Name: strconv.Itoa(index), Properties: feature.Properties}
+
+		if name, ok := feature.Properties["name"].(string); ok && name != "" {
+			zone.Name = name
+		}
+
+		var err error
+
+		switch feature.Geometry.Type {
+		case "Point":
+			err = json.Unmarshal(feature.Geometry.Coordinates, &zone.Center)
+			radius, ok := feature.Properties["radius"].(float64)
+
+			if err == nil && (!ok || radius <= 0) {
+				err = errors.New("a Point requires a positive radius property")
+			}
+
+			zone.Radius = radius
+		case "Polygon":
+			polygon := [][][2]float64{}
+			err = json.Unmarshal(feature.Geometry.Coordinates, &polygon)
+			zone.Polygons = [][][][2]float64{polygon}
+		case "MultiPolygon":
+			err = json.Unmarshal(feature.Geometry.Coordinates, &zone.Polygons)
+
+			// A zone without polygons would be a circle without a radius.
+			if err == nil && len(zone.Polygons) == 0 {
+				err = errors.New("a MultiPolygon requires at least one polygon")
+			}
+		default:
+			err = errors.New("unsupported geometry type " + feature.Geometry.Type)
+		}
+
+		if err == nil {
+			for _, polygon := range zone.Polygons {
+				if len(polygon) == 0 {
+					err = errors.New("a polygon requires an outer ring")
+				}
+
+				for _, ring := range polygon {
+					if len(ring) < 4 {
+						err = errors.New("a polygon ring requires at least 4 positions")
+					}
+				}
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %s", index, err)
+		}
+
+		zones = append(zones, zone)
+	}
+
+	return zones, nil
+}
+
+// Convert a position to meters in a local plane around an origin.
+func toLocal(lat, lon, originLat, originLon float64) (float64, float64) {
+	x := (lon - originLon) * math.Pi / 180 * geo.EarthRadius * math.Cos(originLat*math.Pi/180)
+	y := (lat - originLat) * math.Pi / 180 * geo.EarthRadius
+
+	return x, y
+}
+
+// Get the distance [m] from the origin to a segment in the local plane.
+func segmentDistance(ax, ay, bx, by float64) float64 {
+	dx := bx - ax
+	dy := by - ay
+	t := 0.0
+
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+	}
+
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+// Check whether a position is inside a ring using ray casting.
+func insideRing(lat, lon float64, ring [][2]float64) bool {
+	inside := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		loni, lati := ring[i][0], ring[i][1]
+		lonj, latj := ring[j][0], ring[j][1]
+
+		if (lati > lat) != (latj > lat) && lon < (lonj-loni)*(lat-lati)/(latj-lati)+loni {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// Get the signed distance [m] from a position to the zone boundary. The distance is negative inside the zone.
+func (zone *Zone) Distance(lat, lon float64) float64 {
+	if len(zone.Polygons) == 0 {
+		return geo.Distance(lat, lon, zone.Center[1], zone.Center[0]) - zone.Radius
+	}
+
+	distance := math.MaxFloat64
+	inside := false
+
+	for _, polygon := range zone.Polygons {
+		insidePolygon := insideRing(lat, lon, polygon[0])
+
+		for _, hole := range polygon[1:] {
+			if insideRing(lat, lon, hole) {
+				insidePolygon = false
+			}
+		}
+
+		inside = inside || insidePolygon
+
+		for _, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				ax, ay := toLocal(ring[i-1][1], ring[i-1][0], lat, lon)
+				bx, by := toLocal(ring[i][1], ring[i][0], lat, lon)
+				distance = math.Min(distance, segmentDistance(ax, ay, bx, by))
+			}
+		}
+	}
+
+	if inside {
+		return -distance
+	}
+
+	return distance
+}
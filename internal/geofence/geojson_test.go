@@ -0,0 +1,140 @@
+package geofence
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
+)
+
+// Square ring from 52.00° to 52.01° north and 5.00° to 5.01° east.
+var square = [][2]float64{{5, 52}, {5.01, 52}, {5.01, 52.01}, {5, 52.01}, {5, 52}}
+
+// Square ring from 52.004° to 52.006° north and 5.004° to 5.006° east.
+var hole = [][2]float64{{5.004, 52.004}, {5.006, 52.004}, {5.006, 52.006}, {5.004, 52.006}, {5.004, 52.004}}
+
+// Get the distance [m] of a number of degrees latitude in the local plane.
+func latitudeMeters(degrees float64) float64 {
+	return degrees * math.Pi / 180 * geo.EarthRadius
+}
+
+// Get the distance [m] of a number of degrees longitude at a latitude in the local plane.
+func longitudeMeters(degrees, lat float64) float64 {
+	return latitudeMeters(degrees) * math.Cos(lat*math.Pi/180)
+}
+
+func TestZoneDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		zone Zone
+		lat  float64
+		lon  float64
+		want float64
+	}{
+		{"inside polygon", Zone{Polygons: [][][][2]float64{{square}}}, 52.005, 5.005, -longitudeMeters(0.005, 52.005)},
+		{"outside polygon", Zone{Polygons: [][][][2]float64{{square}}}, 52.02, 5.005, latitudeMeters(0.01)},
+		{"outside polygon corner", Zone{Polygons: [][][][2]float64{{square}}}, 51.999, 4.999, math.Hypot(latitudeMeters(0.001), longitudeMeters(0.001, 51.999))},
+		{"on polygon boundary", Zone{Polygons: [][][][2]float64{{square}}}, 52.005, 5.01, 0},
+		{"inside hole", Zone{Polygons: [][][][2]float64{{square, hole}}}, 52.005, 5.005, longitudeMeters(0.001, 52.005)},
+		{"inside polygon with hole", Zone{Polygons: [][][][2]float64{{square, hole}}}, 52.001, 5.005, -latitudeMeters(0.001)},
+		{"inside second polygon", Zone{Polygons: [][][][2]float64{{hole}, {square}}}, 52.009, 5.005, -latitudeMeters(0.001)},
+		{"inside circle", Zone{Center: [2]float64{5, 52}, Radius: 100}, 52, 5, -100},
+		{"outside circle", Zone{Center: [2]float64{5, 52}, Radius: 100}, 52.01, 5, geo.Distance(52.01, 5, 52, 5) - 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.zone.Distance(test.lat, test.lon); math.Abs(got-test.want) > 0.01 {
+				t.Errorf("Distance(%v, %v) = %.3f, want %.3f", test.lat, test.lon, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseZones(t *testing.T) {
+	tests := []struct {
+		name    string
+		geoJson string
+		zones   []string
+		err     string
+	}{
+		{
+			name:    "empty collection",
+			geoJson: `{"type": "FeatureCollection", "features": []}`,
+			zones:   []string{},
+		},
+		{
+			name: "all geometry types",
+			geoJson: `{"type": "FeatureCollection", "features": [
+				{"type": "Feature", "properties": {"name": "depot"}, "geometry": {"type": "Polygon", "coordinates": [[[5, 52], [5.01, 52], [5.01, 52.01], [5, 52]]]}},
+				{"type": "Feature", "properties": {"radius": 50}, "geometry": {"type": "Point", "coordinates": [5, 52]}},
+				{"type": "Feature", "properties": {"name": "yards"}, "geometry": {"type": "MultiPolygon", "coordinates": [[[[5, 52], [5.01, 52], [5.01, 52.01], [5, 52]]]]}}
+			]}`,
+			zones: []string{"depot", "1", "yards"},
+		},
+		{
+			name:    "not a feature collection",
+			geoJson: `{"type": "Feature"}`,
+			err:     "FeatureCollection",
+		},
+		{
+			name:    "point without radius",
+			geoJson: `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Point", "coordinates": [5, 52]}}]}`,
+			err:     "radius",
+		},
+		{
+			name:    "empty polygon",
+			geoJson: `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": []}}]}`,
+			err:     "outer ring",
+		},
+		{
+			name:    "empty multipolygon",
+			geoJson: `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": []}}]}`,
+			err:     "at least one polygon",
+		},
+		{
+			name:    "ring with too few positions",
+			geoJson: `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[5, 52], [5.01, 52], [5, 52]]]}}]}`,
+			err:     "at least 4 positions",
+		},
+		{
+			name:    "unsupported geometry",
+			geoJson: `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[5, 52], [5.01, 52]]}}]}`,
+			err:     "unsupported geometry type LineString",
+		},
+		{
+			name:    "too many zones",
+			geoJson: `{"type": "FeatureCollection", "features": [` + strings.Repeat(`{"type": "Feature", "properties": {"radius": 50}, "geometry": {"type": "Point", "coordinates": [5, 52]}},`, MaxZones) + `{"type": "Feature", "properties": {"radius": 50}, "geometry": {"type": "Point", "coordinates": [5, 52]}}]}`,
+			err:     "at most",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			zones, err := ParseZones([]byte(test.geoJson))
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ParseZones() error = %v, want %q", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseZones() returned error %s", err)
+			}
+
+			names := []string{}
+
+			for _, zone := range zones {
+				names = append(names, zone.Name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(test.zones, ",") {
+				t.Errorf("ParseZones() zones = %v, want %v", names, test.zones)
+			}
+		})
+	}
+}
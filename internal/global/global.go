@@ -5,39 +5,145 @@ import (
 	"sync"
 )
 
-// Define some global vars.
+// Define some global vars. Flags that can be changed at runtime are guarded by the Mutex.
 var (
-	Wg              sync.WaitGroup
-	GpsdHost        = flag.String("gpsd-host", "localhost", "Hostname of the device that runs the GPSd TCP feed.")
-	GpsdPort        = flag.Uint64("gpsd-port", 2947, "Port running the GPSd TCP feed.")
-	RestPort        = flag.Uint64("rest-port", 8081, "Port used to serve the HTTP REST API.")
-	RestApiKey      = flag.String("rest-api-key", "", "Expected X-API-Key header value to authenticate HTTP requests. Set a key to enable.")
-	CanInterface    = flag.String("can-interface", "can0", "CAN interface name to send sensor data.")
-	CanExtended     = flag.Bool("can-extended", false, "Use extended CAN. Set to true to enable.")
-	CanFrequency    = flag.Float64("can-frequency", 1, "Message frequency [Hz] of all sensor data on CAN bus. Set frame ID to 0 to disable.")
-	LatFrameId      = flag.Uint64("lat-frame-id", 200, "CAN frame ID for GPS latitude data [°] (float64 LE). Set frame ID to 0 to disable.")
-	LonFrameId      = flag.Uint64("lon-frame-id", 201, "CAN frame ID for GPS longitude data [°] (float64 LE). Set frame ID to 0 to disable.")
-	AltFrameId      = flag.Uint64("alt-frame-id", 202, "CAN frame ID for GPS altitude data [m] (float64 LE). Set frame ID to 0 to disable.")
-	SpeedFrameId    = flag.Uint64("speed-frame-id", 203, "CAN frame ID for GPS speed data [m/s] (float64 LE). Set frame ID to 0 to disable.")
-	GpsFrameId      = flag.Uint64("gps-frame-id", 204, "CAN frame ID for GPS mode (1:uint8), status (2:uint8), visible satellites (3+4:uint16 LE), used satellites (5+6:uint16 LE), and quality data (7: uint8) data (8: not used). Set frame ID to enable.")
-	EpcFrameId      = flag.Uint64("epc-frame-id", 0, "CAN frame ID for the GPS estimated climb error [m/s] (float64 LE). Set frame ID to enable.")
-	EpdFrameId      = flag.Uint64("epd-frame-id", 0, "CAN frame ID for the GPS estimated track (direction) error [°] (float64 LE). Set frame ID to enable.")
-	EphFrameId      = flag.Uint64("eph-frame-id", 0, "CAN frame ID for the GPS estimated horizontal position (2D) error [m] (float64 LE). Set frame ID to enable.")
-	EpsFrameId      = flag.Uint64("eps-frame-id", 0, "CAN frame ID for the GPS estimated speed error [m/s] (float64 LE). Set frame ID to enable.")
-	EptFrameId      = flag.Uint64("ept-frame-id", 0, "CAN frame ID for the GPS estimated time stamp error [s] (float64 LE). Set frame ID to enable.")
-	EpxFrameId      = flag.Uint64("epx-frame-id", 0, "CAN frame ID for the GPS estimated longitude error [m] (float64 LE). Set frame ID to enable.")
-	EpyFrameId      = flag.Uint64("epy-frame-id", 0, "CAN frame ID for the GPS estimated latitude error [m] (float64 LE). Set frame ID to enable.")
-	EpvFrameId      = flag.Uint64("epv-frame-id", 0, "CAN frame ID for the GPS estimated vertical error [m] (float64 LE). Set frame ID to enable.")
-	SepFrameId      = flag.Uint64("sep-frame-id", 0, "CAN frame ID for the GPS estimated spherical (3D) position error [m] (float64 LE). Set frame ID to enable.")
-	XdopFrameId     = flag.Uint64("xdop-frame-id", 0, "CAN frame ID for the GPS longitudinal dilution of precision (float64 LE). Set frame ID to enable.")
-	YdopFrameId     = flag.Uint64("ydop-frame-id", 0, "CAN frame ID for the GPS latitudinal dilution of precision (float64 LE). Set frame ID to enable.")
-	VdopFrameId     = flag.Uint64("vdop-frame-id", 0, "CAN frame ID for the GPS vertical (altitude) dilution of precision dilution of precision (float64 LE). Set frame ID to enable.")
-	TdopFrameId     = flag.Uint64("tdop-frame-id", 0, "CAN frame ID for the GPS time dilution of precision (float64 LE). Set frame ID to enable.")
-	HdopFrameId     = flag.Uint64("hdop-frame-id", 0, "CAN frame ID for the GPS horizontal dilution of precision (float64 LE). Set frame ID to enable.")
-	PdopFrameId     = flag.Uint64("pdop-frame-id", 0, "CAN frame ID for the GPS position (spherical/3D) dilution of precision dilution of precision (float64 LE). Set frame ID to enable.")
-	GdopFrameId     = flag.Uint64("gdop-frame-id", 0, "CAN frame ID for the GPS geometric (hyperspherical) dilution of precision (float64 LE). Set frame ID to enable.")
-	MotionFrameId   = flag.Uint64("motion-frame-id", 205, "CAN frame ID for motion X [mg] (1+2:int16 LE), Y [mg] (3+4:int16 LE), Z [mg] (5+6:int16 LE), and scale [g] (7:uint8) data (8: not used). Set frame ID to 0 to disable.")
-	MotionFrequency = flag.Float64("motion-frequency", 1, "Polling frequency [Hz] of motion sensor data")
-	Verbose         = flag.Bool("verbose", false, "More verbose output for debugging purposes. Set to true to enable.")
-	Version         = flag.Bool("version", false, "Print the current application version.")
+	Wg                     sync.WaitGroup
+	Mutex                  sync.RWMutex
+	ConfigFile             = flag.String("config-file", "/etc/sensor.conf", "Configuration file with the SENSOR_ARGS variable. Configuration changes made using the REST API are persisted to this file on request.")
+	GpsdHost               = flag.String("gpsd-host", "localhost", "Hostname of the device that runs the GPSd TCP feed.")
+	GpsdPort               = flag.Uint64("gpsd-port", 2947, "Port running the GPSd TCP feed.")
+	GpsFrequency           = flag.Float64("gps-frequency", 1, "Expected GPS report frequency [Hz] as configured by GPS_RATE_MS in /etc/gps.conf. Used for HTTP caching.")
+	RestPort               = flag.Uint64("rest-port", 8081, "Port used to serve the HTTP REST API.")
+	HealthMaxAge           = flag.Float64("health-max-age", 5, "Maximum age [s] of GPS and motion sensor data before the health check reports a failure.")
+	RestApiKey             = flag.String("rest-api-key", "", "Expected X-API-Key or Authorization: Bearer header value to authenticate HTTP requests with the admin scope. Set a key to enable.")
+	RestApiKeyFile         = flag.String("rest-api-key-file", "", "JSON file with named API keys, their scopes (gps:read, motion:read, metrics:read, admin), and optional expiry. Set a file to enable.")
+	RestReadTimeout        = flag.Float64("rest-read-timeout", 10, "Maximum duration [s] for reading an entire HTTP request. Set to 0 to disable.")
+	RestWriteTimeout       = flag.Float64("rest-write-timeout", 30, "Maximum duration [s] before timing out writes of an HTTP response. Set to 0 to disable.")
+	RestIdleTimeout        = flag.Float64("rest-idle-timeout", 60, "Maximum duration [s] to wait for the next HTTP request on a keep-alive connection. Set to 0 to disable.")
+	RestMaxConnections     = flag.Uint64("rest-max-connections", 32, "Maximum number of concurrent HTTP connections. Set to 0 to disable.")
+	RestMaxHeaderSize      = flag.Uint64("rest-max-header-size", 8192, "Maximum size [bytes] of HTTP request headers.")
+	RestMaxBodySize        = flag.Uint64("rest-max-body-size", 65536, "Maximum size [bytes] of HTTP request bodies.")
+	RestRateLimit          = flag.Float64("rest-rate-limit", 10, "Sustained HTTP request rate [requests/s] allowed per client IP address and per API key. Set to 0 to disable.")
+	RestRateBurst          = flag.Float64("rest-rate-burst", 20, "Number of HTTP requests a client IP address or API key may burst above the rate limit.")
+	RestLockoutFailures    = flag.Uint64("rest-lockout-failures", 5, "Number of consecutive unauthorized HTTP requests before a client IP address is locked out. Set to 0 to disable.")
+	RestLockoutDuration    = flag.Float64("rest-lockout-duration", 300, "Duration [s] a client IP address is locked out after repeated unauthorized HTTP requests.")
+	RestMaxWait            = flag.Float64("rest-max-wait", 25, "Maximum duration [s] a long-poll request (?wait=5s) is held until newer sensor data is available. Keep it below the write timeout.")
+	RestTls                = flag.Bool("rest-tls", false, "Serve the HTTP REST API over HTTPS. A self-signed certificate is generated if the certificate and key files do not exist. Send SIGHUP to reload the certificates. Set to true to enable.")
+	RestTlsCert            = flag.String("rest-tls-cert", "/etc/sensor/tls.crt", "PEM encoded TLS certificate file used to serve HTTPS.")
+	RestTlsKey             = flag.String("rest-tls-key", "/etc/sensor/tls.key", "PEM encoded TLS private key file used to serve HTTPS.")
+	RestTlsClientCa        = flag.String("rest-tls-client-ca", "", "PEM encoded CA bundle to verify client certificates against (mutual TLS). Set a file to enable.")
+	CanInterface           = flag.String("can-interface", "can0", "CAN interface name to send sensor data.")
+	CanExtended            = flag.Bool("can-extended", false, "Use extended CAN. Set to true to enable.")
+	CanFrequency           = flag.Float64("can-frequency", 1, "Message frequency [Hz] of all sensor data on CAN bus. Set frame ID to 0 to disable.")
+	LatFrameId             = flag.Uint64("lat-frame-id", 200, "CAN frame ID for GPS latitude data [°] (float64 LE). Set frame ID to 0 to disable.")
+	LonFrameId             = flag.Uint64("lon-frame-id", 201, "CAN frame ID for GPS longitude data [°] (float64 LE). Set frame ID to 0 to disable.")
+	AltFrameId             = flag.Uint64("alt-frame-id", 202, "CAN frame ID for GPS altitude data [m] (float64 LE). Set frame ID to 0 to disable.")
+	SpeedFrameId           = flag.Uint64("speed-frame-id", 203, "CAN frame ID for GPS speed data [m/s] (float64 LE). Set frame ID to 0 to disable.")
+	GpsFrameId             = flag.Uint64("gps-frame-id", 204, "CAN frame ID for GPS mode (1:uint8), status (2:uint8), visible satellites (3+4:uint16 LE), used satellites (5+6:uint16 LE), and quality data (7: uint8) data (8: not used). Set frame ID to enable.")
+	EpcFrameId             = flag.Uint64("epc-frame-id", 0, "CAN frame ID for the GPS estimated climb error [m/s] (float64 LE). Set frame ID to enable.")
+	EpdFrameId             = flag.Uint64("epd-frame-id", 0, "CAN frame ID for the GPS estimated track (direction) error [°] (float64 LE). Set frame ID to enable.")
+	EphFrameId             = flag.Uint64("eph-frame-id", 0, "CAN frame ID for the GPS estimated horizontal position (2D) error [m] (float64 LE). Set frame ID to enable.")
+	EpsFrameId             = flag.Uint64("eps-frame-id", 0, "CAN frame ID for the GPS estimated speed error [m/s] (float64 LE). Set frame ID to enable.")
+	EptFrameId             = flag.Uint64("ept-frame-id", 0, "CAN frame ID for the GPS estimated time stamp error [s] (float64 LE). Set frame ID to enable.")
+	EpxFrameId             = flag.Uint64("epx-frame-id", 0, "CAN frame ID for the GPS estimated longitude error [m] (float64 LE). Set frame ID to enable.")
+	EpyFrameId             = flag.Uint64("epy-frame-id", 0, "CAN frame ID for the GPS estimated latitude error [m] (float64 LE). Set frame ID to enable.")
+	EpvFrameId             = flag.Uint64("epv-frame-id", 0, "CAN frame ID for the GPS estimated vertical error [m] (float64 LE). Set frame ID to enable.")
+	SepFrameId             = flag.Uint64("sep-frame-id", 0, "CAN frame ID for the GPS estimated spherical (3D) position error [m] (float64 LE). Set frame ID to enable.")
+	XdopFrameId            = flag.Uint64("xdop-frame-id", 0, "CAN frame ID for the GPS longitudinal dilution of precision (float64 LE). Set frame ID to enable.")
+	YdopFrameId            = flag.Uint64("ydop-frame-id", 0, "CAN frame ID for the GPS latitudinal dilution of precision (float64 LE). Set frame ID to enable.")
+	VdopFrameId            = flag.Uint64("vdop-frame-id", 0, "CAN frame ID for the GPS vertical (altitude) dilution of precision dilution of precision (float64 LE). Set frame ID to enable.")
+	TdopFrameId            = flag.Uint64("tdop-frame-id", 0, "CAN frame ID for the GPS time dilution of precision (float64 LE). Set frame ID to enable.")
+	HdopFrameId            = flag.Uint64("hdop-frame-id", 0, "CAN frame ID for the GPS horizontal dilution of precision (float64 LE). Set frame ID to enable.")
+	PdopFrameId            = flag.Uint64("pdop-frame-id", 0, "CAN frame ID for the GPS position (spherical/3D) dilution of precision dilution of precision (float64 LE). Set frame ID to enable.")
+	GdopFrameId            = flag.Uint64("gdop-frame-id", 0, "CAN frame ID for the GPS geometric (hyperspherical) dilution of precision (float64 LE). Set frame ID to enable.")
+	MotionFrameId          = flag.Uint64("motion-frame-id", 205, "CAN frame ID for motion X [mg] (1+2:int16 LE), Y [mg] (3+4:int16 LE), Z [mg] (5+6:int16 LE), and scale [g] (7:uint8) data (8: not used). Set frame ID to 0 to disable.")
+	MotionSource           = flag.String("motion-source", "sysfs", "Motion data source: sysfs (built-in TCG4 motion sensor), iio (Linux IIO accelerometer), replay (recorded file), or simulated.")
+	MotionSysfsXyz         = flag.String("motion-sysfs-xyz", "/sys/bus/i2c/devices/0-0018/xyz", "I2C Sysfs file with the xyz values of the motion sensor, used by the sysfs motion source.")
+	MotionSysfsControl     = flag.String("motion-sysfs-control", "/sys/bus/i2c/devices/0-0018/control", "I2C Sysfs control file with the range and data rate of the motion sensor, used by the sysfs motion source.")
+	MotionIioDevice        = flag.String("motion-iio-device", "/sys/bus/iio/devices/iio:device0", "Linux IIO device directory of the accelerometer, used by the iio motion source.")
+	MotionReplayFile       = flag.String("motion-replay-file", "", "File with recorded motion data, one X;Y;Z [mg] sample per line with an optional ;scale [g], used by the replay motion source. Replays from the start at the end of the file.")
+	MotionReplayRate       = flag.Float64("motion-replay-rate", 100, "Sample rate [Hz] of the recorded motion data, used by the replay motion source. The samples are replayed in real time.")
+	MotionRange            = flag.Uint("motion-range", 0, "Measurement range [g] written to the motion sensor at startup, e.g. 2, 4, 8, or 16. Set to 0 to keep the current range.")
+	MotionDataRate         = flag.Float64("motion-data-rate", 0, "Output data rate [Hz] written to the motion sensor at startup. Set to 0 to keep the current data rate.")
+	MotionSettingsInterval = flag.Float64("motion-settings-interval", 10, "Interval [s] between reads of the motion sensor range and data rate, so changes made outside the application are reflected.")
+	MotionSettingsFrameId  = flag.Uint64("motion-settings-frame-id", 0, "CAN frame ID for the motion sensor range [g] (1:uint8) and output data rate [0.1 Hz] (2+3:uint16 LE) (4-8: not used). Set frame ID to enable.")
+	OrientationFrameId     = flag.Uint64("orientation-frame-id", 0, "CAN frame ID for motion pitch [0.01°] (1+2:int16 LE) and roll [0.01°] (3+4:int16 LE) data (5-8: not used). Set frame ID to enable.")
+	MotionMounting         = flag.String("motion-mounting", "1,0,0,0,1,0,0,0,1", "Mounting rotation matrix (row-major, 9 comma separated values) from the motion sensor axes to the vehicle axes (X forward, Y left, Z up).")
+	MotionCalibrationFile  = flag.String("motion-calibration-file", "/etc/sensor/motion-calibration.json", "JSON file with the per-axis offset and scale of the motion sensor, written by the calibration. Calibrate using the REST API or the calibrate subcommand.")
+	MotionFrequency        = flag.Float64("motion-frequency", 1, "Polling frequency [Hz] of motion sensor data")
+	Events                 = flag.Bool("events", false, "Detect impacts, harsh braking, harsh acceleration, and harsh cornering from motion data sampled at the event sample rate. Set to true to enable.")
+	EventSampleRate        = flag.Float64("event-sample-rate", 100, "Sample rate [Hz] of the motion sensor used for event detection.")
+	EventImpactThreshold   = flag.Float64("event-impact-threshold", 2500, "Minimum peak magnitude [mg] of the dynamic acceleration to detect an impact.")
+	EventBrakingThreshold  = flag.Float64("event-braking-threshold", 400, "Minimum backward dynamic acceleration [mg] to detect harsh braking.")
+	EventAccelThreshold    = flag.Float64("event-acceleration-threshold", 350, "Minimum forward dynamic acceleration [mg] to detect harsh acceleration.")
+	EventCornerThreshold   = flag.Float64("event-cornering-threshold", 400, "Minimum sideways dynamic acceleration [mg] to detect harsh cornering.")
+	EventPreWindow         = flag.Float64("event-pre-window", 2, "Duration [s] of motion samples recorded before an event.")
+	EventPostWindow        = flag.Float64("event-post-window", 2, "Duration [s] of motion samples recorded after an event.")
+	EventHistory           = flag.Uint64("event-history", 100, "Number of events kept in memory for the REST API.")
+	EventFrameId           = flag.Uint64("event-frame-id", 0, "CAN frame ID for detected events with the type (1:uint8, 1: impact, 2: harsh braking, 3: harsh acceleration, 4: harsh cornering), peak [mg] (2+3:uint16 LE), and event counter (4:uint8) data (5-8: not used). Sent once per event. Set frame ID to enable.")
+	Vibration              = flag.Bool("vibration", false, "Analyze vibration of motion data sampled at the vibration sample rate. Set to true to enable.")
+	VibrationSampleRate    = flag.Float64("vibration-sample-rate", 200, "Sample rate [Hz] of the motion sensor used for vibration analysis. The spectrum covers frequencies up to half the sample rate.")
+	VibrationWindow        = flag.Uint64("vibration-window", 256, "Number of samples per vibration analysis window. Must be a power of 2.")
+	VibrationFrameId       = flag.Uint64("vibration-frame-id", 0, "CAN frame ID for vibration RMS X [mg] (1+2:uint16 LE), Y [mg] (3+4:uint16 LE), Z [mg] (5+6:uint16 LE), and the dominant frequency [0.1 Hz] of the axis with the highest RMS (7+8:uint16 LE) data. Set frame ID to enable.")
+	Movement               = flag.Bool("movement", false, "Detect whether the vehicle is stationary or moving from the motion sensor variance and the GPS speed. Set to true to enable.")
+	MovementSampleRate     = flag.Float64("movement-sample-rate", 50, "Sample rate [Hz] of the motion sensor used for movement detection.")
+	MovementWindow         = flag.Float64("movement-window", 1, "Duration [s] of the window of motion samples of which the variance is computed.")
+	MovementVariance       = flag.Float64("movement-variance-threshold", 400, "Minimum total variance [mg²] of the motion samples in a window to detect movement.")
+	MovementSpeed          = flag.Float64("movement-speed-threshold", 1, "Minimum GPS speed [m/s] to detect movement.")
+	MovementHysteresis     = flag.Float64("movement-hysteresis", 5, "Duration [s] the movement must be detected, or not detected, before the state changes.")
+	MovementFreeze         = flag.Bool("movement-freeze-position", false, "Report the GPS position at the moment the vehicle became stationary while it is stationary, to suppress GPS drift. Set to true to enable.")
+	MovementFrameId        = flag.Uint64("movement-frame-id", 0, "CAN frame ID for the movement status (1:uint8, bit 0: moving, bit 1: position frozen) and duration [s] since the last state change (2-5:uint32 LE) data (6-8: not used). Set frame ID to enable.")
+	DeadReckoning          = flag.Bool("dead-reckoning", false, "Estimate the position when the GPS fix is lost using the last course and speed and the longitudinal acceleration of the motion sensor. Estimated positions are reported with status 5 (DR). Set to true to enable.")
+	DeadReckoningRate      = flag.Float64("dead-reckoning-rate", 10, "Update rate [Hz] of the dead reckoning estimate.")
+	DeadReckoningTimeout   = flag.Float64("dead-reckoning-timeout", 2, "Maximum age [s] of the last GPS fix before dead reckoning starts.")
+	DeadReckoningMaxTime   = flag.Float64("dead-reckoning-max-duration", 60, "Maximum duration [s] of dead reckoning, after which the position is no longer estimated.")
+	DeadReckoningDrift     = flag.Float64("dead-reckoning-drift", 0.05, "Uncertainty [m/s²] of the longitudinal acceleration, used to grow the estimated horizontal error.")
+	Filter                 = flag.Bool("filter", false, "Smooth the GPS position and velocity using a Kalman filter with the motion sensor as input. The filtered data is published separately from the raw GPS data. Set to true to enable.")
+	FilterModel            = flag.String("filter-model", "cv", "Kalman filter motion model: cv (constant velocity with the motion sensor acceleration as input) or ca (constant acceleration with the motion sensor acceleration as measurement).")
+	FilterRate             = flag.Float64("filter-rate", 10, "Output rate [Hz] of the Kalman filter.")
+	FilterProcessNoise     = flag.Float64("filter-process-noise", 0.5, "Standard deviation of the Kalman filter process noise, in acceleration [m/s²] for the cv model or jerk [m/s³] for the ca model.")
+	FilterAccelNoise       = flag.Float64("filter-accel-noise", 0.3, "Standard deviation [m/s²] of the motion sensor acceleration used by the Kalman filter.")
+	FilteredLatFrameId     = flag.Uint64("filtered-lat-frame-id", 0, "CAN frame ID for the filtered latitude [°] (float64 LE). Set frame ID to enable.")
+	FilteredLonFrameId     = flag.Uint64("filtered-lon-frame-id", 0, "CAN frame ID for the filtered longitude [°] (float64 LE). Set frame ID to enable.")
+	FilteredAltFrameId     = flag.Uint64("filtered-alt-frame-id", 0, "CAN frame ID for the filtered altitude [m] (float64 LE). Set frame ID to enable.")
+	FilteredVelFrameId     = flag.Uint64("filtered-velocity-frame-id", 0, "CAN frame ID for the filtered speed [0.01 m/s] (1+2:uint16 LE), heading [0.01°] (3+4:uint16 LE), and vertical velocity [0.01 m/s] (5+6:int16 LE) data (7-8: not used). Set frame ID to enable.")
+	Odometer               = flag.Bool("odometer", false, "Count the travelled distance and moving time between valid 3D GPS fixes in a persistent total odometer and a trip counter. Set to true to enable.")
+	OdometerFile           = flag.String("odometer-file", "/etc/sensor/odometer.json", "JSON file with the odometer and trip counters, saved periodically and on shutdown.")
+	OdometerMaxEph         = flag.Float64("odometer-max-eph", 50, "Maximum estimated horizontal position error [m] of a GPS fix used by the odometer.")
+	OdometerMinSpeed       = flag.Float64("odometer-min-speed", 0.5, "Minimum GPS speed [m/s] to count distance and moving time, and to start a trip.")
+	OdometerTripTimeout    = flag.Float64("odometer-trip-timeout", 300, "Duration [s] below the minimum speed after which a trip ends. The current trip is reset when the next trip starts.")
+	OdometerFrameId        = flag.Uint64("odometer-frame-id", 0, "CAN frame ID for the total odometer [0.1 km] (1-4:uint32 LE), trip distance [0.1 km] (5+6:uint16 LE), and trip status (7:uint8, bit 0: trip active) data (8: not used). Set frame ID to enable.")
+	OdometerHoursFrameId   = flag.Uint64("odometer-hours-frame-id", 0, "CAN frame ID for the total moving time [0.1 h] (1-4:uint32 LE) and trip moving time [s] (5-8:uint32 LE) data. Set frame ID to enable.")
+	GeofenceFile           = flag.String("geofence-file", "", "GeoJSON file with a FeatureCollection of Polygon, MultiPolygon, and Point (circle with a radius [m] property) geofences, also written when geofences are uploaded using the REST API. Set a file to enable.")
+	GeofenceHysteresis     = flag.Float64("geofence-hysteresis", 10, "Distance [m] outside a geofence before an exit is detected. An entry is detected at the boundary.")
+	GeofenceHistory        = flag.Uint64("geofence-history", 100, "Number of geofence entry and exit events kept in memory for the REST API.")
+	GeofenceFrameId        = flag.Uint64("geofence-frame-id", 0, "CAN frame ID for the geofence membership bitfield (uint64 LE), where bit N is set while inside the Nth geofence. Set frame ID to enable.")
+	Overspeed              = flag.Bool("overspeed", false, "Detect overspeed above the global speed limit and the speedLimit [m/s] property of the geofences the vehicle is inside. Set to true to enable.")
+	SpeedLimit             = flag.Float64("speed-limit", 0, "Global speed limit [m/s]. Set to 0 to only use the speed limits of geofences.")
+	OverspeedDebounce      = flag.Float64("overspeed-debounce", 3, "Duration [s] the speed must be above the limit before overspeed starts, and below the limit before it ends.")
+	OverspeedHistory       = flag.Uint64("overspeed-history", 100, "Number of overspeed start and end events kept in memory for the REST API.")
+	OverspeedFrameId       = flag.Uint64("overspeed-frame-id", 0, "CAN frame ID for the overspeed alarm (1:uint8, bit 0: overspeed), speed [0.01 m/s] (2+3:uint16 LE), active speed limit [0.01 m/s] (4+5:uint16 LE), and overspeed counter (6:uint8) data (7-8: not used). Set frame ID to enable.")
+	EnuOrigin              = flag.String("enu-origin", "", "Site base point (lat [°], lon [°], alt [m], comma separated) used as origin of the local east, north, up (ENU) coordinates. The altitude is the height above the WGS84 ellipsoid. Set an origin to enable.")
+	UtmFrameId             = flag.Uint64("utm-frame-id", 0, "CAN frame ID for the UTM easting [0.01 m] (1-4:int32 LE) and northing [0.01 m] (5-8:int32 LE) data. Set frame ID to enable.")
+	UtmZoneFrameId         = flag.Uint64("utm-zone-frame-id", 0, "CAN frame ID for the UTM zone (1:uint8) and latitude band letter (2:ASCII) data (3-8: not used). Set frame ID to enable.")
+	EnuFrameId             = flag.Uint64("enu-frame-id", 0, "CAN frame ID for the ENU east [0.01 m] (1-4:int32 LE) and north [0.01 m] (5-8:int32 LE) data. Requires an ENU origin. Set frame ID to enable.")
+	EnuUpFrameId           = flag.Uint64("enu-up-frame-id", 0, "CAN frame ID for the ENU up [0.01 m] (1-4:int32 LE) data (5-8: not used). Requires an ENU origin. Set frame ID to enable.")
+	LeverArm               = flag.String("lever-arm", "", "Lever arm [m] from the GPS antenna to the control point in the vehicle axes (X forward, Y left, Z up, comma separated). Reported positions are moved to the control point using the pitch and roll of the motion sensor and the GPS course as heading. Set a lever arm to enable.")
+	LeverArmMinSpeed       = flag.Float64("lever-arm-min-speed", 1, "Minimum GPS speed [m/s] to use the GPS course as heading for the lever arm. The last heading is kept at lower speeds.")
+	AntennaLatFrameId      = flag.Uint64("antenna-lat-frame-id", 0, "CAN frame ID for the GPS antenna latitude [°] (float64 LE) before the lever arm correction. Set frame ID to enable.")
+	AntennaLonFrameId      = flag.Uint64("antenna-lon-frame-id", 0, "CAN frame ID for the GPS antenna longitude [°] (float64 LE) before the lever arm correction. Set frame ID to enable.")
+	AntennaAltFrameId      = flag.Uint64("antenna-alt-frame-id", 0, "CAN frame ID for the GPS antenna altitude [m] (float64 LE) before the lever arm correction. Set frame ID to enable.")
+	Interference           = flag.Bool("interference", false, "Detect GNSS jamming and spoofing from the signal strengths of the satellites and the GPS position, velocity, and time. Set to true to enable.")
+	InterferenceCn0Drop    = flag.Float64("interference-cn0-drop", 6, "Mean drop [dB-Hz] of the satellite signal strengths below their baseline that indicates jamming, if all satellites drop by a similar amount.")
+	InterferenceCn0Spread  = flag.Float64("interference-cn0-spread", 2, "Standard deviation [dB-Hz] of the satellite signal strengths below which the signals are abnormally uniform, which indicates spoofing.")
+	InterferenceMaxSpeed   = flag.Float64("interference-max-speed", 70, "Maximum speed [m/s] of the vehicle. Position changes that are not possible at this speed within the estimated horizontal errors are impossible jumps.")
+	InterferenceMaxAccel   = flag.Float64("interference-max-accel", 15, "Maximum acceleration [m/s²] of the vehicle. Speed changes above this acceleration are impossible jumps.")
+	InterferenceTimeJump   = flag.Float64("interference-time-jump", 1, "Maximum difference [s] between the elapsed GPS time and the elapsed system time of two fixes before it is a time discontinuity.")
+	InterferenceMaxFreeze  = flag.Float64("interference-max-freeze", 300, "Maximum duration [s] the satellite signal strength baselines are frozen during a drop. The baselines then follow the signal strengths again, so a lasting change of the environment is not reported as jamming forever.")
+	InterferenceHold       = flag.Float64("interference-hold", 30, "Duration [s] an indicator stays active after it is detected. One active indicator is suspected interference, two or more is likely interference.")
+	InterferenceFile       = flag.String("interference-file", "/etc/sensor/interference.json", "JSON file with the interference events and event counter, saved periodically and on shutdown.")
+	InterferenceHistory    = flag.Uint64("interference-history", 100, "Number of interference events kept for the REST API and in the interference file.")
+	InterferenceFrameId    = flag.Uint64("interference-frame-id", 0, "CAN frame ID for the interference status (1:uint8, 0: none, 1: suspected, 2: likely), active indicators (2:uint8, bit 0: C/N0 drop, bit 1: uniform C/N0, bit 2: position or velocity jump, bit 3: time discontinuity), mean C/N0 [0.1 dB-Hz] (3+4:uint16 LE), number of satellites with a signal (5:uint8), and event counter (6:uint8) data (7-8: not used). Set frame ID to enable.")
+	Verbose                = flag.Bool("verbose", false, "More verbose output for debugging purposes. Set to true to enable.")
+	Version                = flag.Bool("version", false, "Print the current application version.")
 )
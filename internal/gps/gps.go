@@ -7,24 +7,46 @@ import (
 	"sync"
 	"time"
 
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
 	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/metrics"
 	"github.com/vuhuy/tcg4-sensor/pkg/gpsd"
 )
 
 // Struct to store GPS data from GPSd.
 type Gps struct {
-	Mutex sync.RWMutex
-	Tpv   TpvReport
-	Sky   SkyReport
+	Mutex     sync.RWMutex
+	Connected bool
+	Tpv       TpvReport
+	Sky       SkyReport
+	Frozen    *Position
+	Estimate  *TpvReport
+	Control   *Offset
+}
+
+// Struct to store a position.
+type Position struct {
+	Lat float64
+	Lon float64
+	Alt float64
+}
+
+// Struct to store the offset [m] from the GPS antenna to the control point in east, north, and up.
+type Offset struct {
+	East  float64
+	North float64
+	Up    float64
 }
 
 // Struct to store TPV report data.
 type TpvReport struct {
 	LastUpdate time.Time
+	Time       time.Time
 	Lat        float64
 	Lon        float64
 	Alt        float64
 	Speed      float64
+	Track      float64
 	Mode       uint8
 	Status     uint8
 	Epc        float64
@@ -36,6 +58,7 @@ type TpvReport struct {
 	Epy        float64
 	Epv        float64
 	Sep        float64
+	GeoidSep   float64
 }
 
 // Struct to store TPV report data.
@@ -54,16 +77,19 @@ type SkyReport struct {
 	Satellites []gpsd.Satellite
 }
 
-// Store TPV report with mutex lock.
-func (data *Gps) StoreTpv(lat, lon, alt, speed float64, mode, status uint8, epc, epd, eph, eps, ept, epx, epy, epv, sep float64) {
+// Store TPV report with the time reported by the GPS and the geoid separation [m] with mutex lock. The altitude is
+// above mean sea level.
+func (data *Gps) StoreTpv(gpsTime time.Time, lat, lon, alt, speed, track float64, mode, status uint8, epc, epd, eph, eps, ept, epx, epy, epv, sep, geoidSep float64) {
 	data.Mutex.Lock()
 	defer data.Mutex.Unlock()
 
 	data.Tpv.LastUpdate = time.Now()
+	data.Tpv.Time = gpsTime
 	data.Tpv.Lat = lat
 	data.Tpv.Lon = lon
 	data.Tpv.Alt = alt
 	data.Tpv.Speed = speed
+	data.Tpv.Track = track
 	data.Tpv.Mode = mode
 	data.Tpv.Status = status
 	data.Tpv.Epc = epc
@@ -75,6 +101,7 @@ func (data *Gps) StoreTpv(lat, lon, alt, speed float64, mode, status uint8, epc,
 	data.Tpv.Epy = epy
 	data.Tpv.Epv = epv
 	data.Tpv.Sep = sep
+	data.Tpv.GeoidSep = geoidSep
 }
 
 // Store SKY report with mutex lock.
@@ -97,12 +124,118 @@ func (data *Gps) StoreSky(qual uint8, xdop, ydop, vdop, tdop, hdop, pdop, gdop f
 	data.Sky.Satellites = append(data.Sky.Satellites, satellites...)
 }
 
-// Get TPV report with mutex lock.
+// Store GPSd connection state with mutex lock.
+func (data *Gps) StoreConnected(connected bool) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.Connected = connected
+}
+
+// Get GPSd connection state with mutex lock.
+func (data *Gps) GetConnected() bool {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.Connected
+}
+
+// Freeze the reported position at the current position with mutex lock. Nothing is frozen without a fix.
+func (data *Gps) Freeze() {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	if data.Frozen == nil && data.Tpv.Mode >= 2 {
+		data.Frozen = &Position{Lat: data.Tpv.Lat, Lon: data.Tpv.Lon, Alt: data.Tpv.Alt}
+	}
+}
+
+// Report the current position again with mutex lock.
+func (data *Gps) Unfreeze() {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.Frozen = nil
+}
+
+// Get whether the reported position is frozen with mutex lock.
+func (data *Gps) GetFrozen() bool {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.Frozen != nil
+}
+
+// Store an estimated TPV report, which is reported instead of the TPV report from GPSd, with mutex lock. Set nil
+// to report the TPV report from GPSd again.
+func (data *Gps) StoreEstimate(estimate *TpvReport) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.Estimate = estimate
+}
+
+// Store the offset from the GPS antenna to the control point with mutex lock. Reported positions are moved to the
+// control point from now on.
+func (data *Gps) StoreControlOffset(east, north, up float64) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.Control = &Offset{East: east, North: north, Up: up}
+}
+
+// Move a position from the GPS antenna to the control point with mutex lock held.
+func (data *Gps) controlPoint(lat, lon, alt float64) (float64, float64, float64) {
+	if data.Control == nil {
+		return lat, lon, alt
+	}
+
+	return geo.Offset(lat, lon, alt, data.Control.East, data.Control.North, data.Control.Up)
+}
+
+// Move a position from the GPS antenna to the control point with mutex lock. The position is not changed if no
+// control point offset is stored.
+func (data *Gps) ControlPoint(lat, lon, alt float64) (float64, float64, float64) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.controlPoint(lat, lon, alt)
+}
+
+// Get the last TPV report from GPSd with mutex lock, without the estimated, frozen, or lever arm corrected position.
+func (data *Gps) GetFix() TpvReport {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.Tpv
+}
+
+// Get TPV report with mutex lock. The estimated TPV report is reported if there is one, and the position is the
+// frozen position if it is frozen. The position is moved to the control point if a lever arm is applied.
 func (data *Gps) GetTpv() (time.Time, float64, float64, float64, float64, uint8, uint8, float64, float64, float64, float64, float64, float64, float64, float64, float64) {
 	data.Mutex.RLock()
 	defer data.Mutex.RUnlock()
 
-	return data.Tpv.LastUpdate, data.Tpv.Lat, data.Tpv.Lon, data.Tpv.Alt, data.Tpv.Speed, data.Tpv.Mode, data.Tpv.Status, data.Tpv.Epc, data.Tpv.Epd, data.Tpv.Eph, data.Tpv.Eps, data.Tpv.Ept, data.Tpv.Epx, data.Tpv.Epy, data.Tpv.Epv, data.Tpv.Sep
+	report := data.Tpv
+
+	if data.Estimate != nil {
+		report = *data.Estimate
+	} else if data.Frozen != nil {
+		report.Lat, report.Lon, report.Alt = data.Frozen.Lat, data.Frozen.Lon, data.Frozen.Alt
+	}
+
+	report.Lat, report.Lon, report.Alt = data.controlPoint(report.Lat, report.Lon, report.Alt)
+
+	return report.LastUpdate, report.Lat, report.Lon, report.Alt, report.Speed, report.Mode, report.Status, report.Epc, report.Epd, report.Eph, report.Eps, report.Ept, report.Epx, report.Epy, report.Epv, report.Sep
+}
+
+// Get the geoid separation [m] of the last TPV report with mutex lock. Add it to an altitude above mean sea level to
+// get the height above the WGS84 ellipsoid.
+func (data *Gps) GetGeoidSeparation() float64 {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.Tpv.GeoidSep
 }
 
 // Get SKY report with mutex lock.
@@ -128,6 +261,14 @@ func (data *Gps) Start(done chan struct{}) error {
 		return err
 	}
 
+	gps.AddReportFilter(func(class string) {
+		metrics.GpsdReports.Inc(class)
+	})
+
+	gps.AddErrorFilter(func(err error) {
+		metrics.GpsdParseErrors.Inc()
+	})
+
 	gps.AddFilter("SKY", func(r interface{}) {
 		sky := r.(*gpsd.SKYReport)
 		data.StoreSky(sky.Qual, sky.Xdop, sky.Ydop, sky.Vdop, sky.Tdop, sky.Hdop, sky.Pdop, sky.Gdop, sky.Nsat, sky.Usat, sky.Satellites)
@@ -139,7 +280,18 @@ func (data *Gps) Start(done chan struct{}) error {
 
 	gps.AddFilter("TPV", func(r interface{}) {
 		tpv := r.(*gpsd.TPVReport)
-		data.StoreTpv(tpv.Lat, tpv.Lon, tpv.Alt, tpv.Speed, uint8(tpv.Mode), tpv.Status, tpv.Epc, tpv.Epd, tpv.Eph, tpv.Eps, tpv.Ept, tpv.Epx, tpv.Epy, tpv.Epv, tpv.Sep)
+		alt, geoidSep := tpv.Alt, tpv.GeoidSep
+
+		// GPSd 3.20 and later report the altitude above mean sea level as altMSL, older versions as alt.
+		if tpv.AltMSL != 0 {
+			alt = tpv.AltMSL
+		}
+
+		if geoidSep == 0 && tpv.AltHAE != 0 {
+			geoidSep = tpv.AltHAE - alt
+		}
+
+		data.StoreTpv(tpv.Time, tpv.Lat, tpv.Lon, alt, tpv.Speed, tpv.Track, uint8(tpv.Mode), tpv.Status, tpv.Epc, tpv.Epd, tpv.Eph, tpv.Eps, tpv.Ept, tpv.Epx, tpv.Epy, tpv.Epv, tpv.Sep, geoidSep)
 
 		if *global.Verbose {
 			fmt.Printf("[%v] Read GPS TPV report: %#v\n", time.Now().UTC(), tpv)
@@ -148,6 +300,7 @@ func (data *Gps) Start(done chan struct{}) error {
 
 	fmt.Printf("OK\n")
 
+	data.StoreConnected(true)
 	watch := gps.Watch()
 
 	global.Wg.Add(1)
@@ -155,6 +308,7 @@ func (data *Gps) Start(done chan struct{}) error {
 	go func() {
 		select {
 		case <-watch:
+			data.StoreConnected(false)
 			close(done)
 			gps.Close()
 			global.Wg.Done()
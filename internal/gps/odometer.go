@@ -0,0 +1,291 @@
+package gps
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+const (
+	odometerPollInterval = 100 * time.Millisecond // Interval between checks for a new GPS fix.
+	odometerSaveInterval = time.Minute            // Interval between saves of changed counters.
+	maxMovingInterval    = 5.0                    // Maximum time [s] between fixes counted as moving time.
+	jumpSpeedFactor      = 1.5                    // Margin on the GPS speed before a position change is a jump.
+)
+
+// Struct to store the odometer counters. Distances are in meters and moving times in seconds. The trip counter is
+// only reset manually. The current trip is detected automatically, and is reset when the next trip starts.
+type Counters struct {
+	Total                 float64   `json:"total"`
+	MovingTime            float64   `json:"movingTime"`
+	Trip                  float64   `json:"trip"`
+	TripMovingTime        float64   `json:"tripMovingTime"`
+	TripReset             time.Time `json:"tripReset"`
+	CurrentTrip           float64   `json:"currentTrip"`
+	CurrentTripMovingTime float64   `json:"currentTripMovingTime"`
+	TripActive            bool      `json:"tripActive"`
+	TripStart             time.Time `json:"tripStart"`
+	TripEnd               time.Time `json:"tripEnd"`
+}
+
+// Struct to store the odometer and the number of rejected position jumps.
+type Odometer struct {
+	Mutex      sync.RWMutex
+	LastUpdate time.Time
+	Counters   Counters
+	Rejected   uint64
+	Changed    bool
+	previous   *TpvReport
+	lastMoving time.Time
+}
+
+// Load the odometer counters file. Returns empty counters if the file does not exist.
+func LoadCounters(path string) (Counters, error) {
+	counters := Counters{}
+	content, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return counters, nil
+	} else if err != nil {
+		return counters, err
+	}
+
+	if err := json.Unmarshal(content, &counters); err != nil {
+		return counters, fmt.Errorf("cannot parse %s: %s", path, err)
+	}
+
+	return counters, nil
+}
+
+// Save the odometer counters file. The file is replaced atomically, so the counters survive a power loss while
+// saving.
+func (counters *Counters) Save(path string) error {
+	content, err := json.MarshalIndent(counters, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".odometer-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(append(content, '\n')); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	// The content is flushed to disk before the rename, so the renamed file is never empty after a power loss.
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+
+	// The rename is only durable once the directory is flushed to disk.
+	dir, err := os.Open(filepath.Dir(path))
+
+	if err != nil {
+		return err
+	}
+
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+// Get the odometer counters and the number of rejected position jumps with mutex lock.
+func (data *Odometer) Get() (time.Time, Counters, uint64) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.LastUpdate, data.Counters, data.Rejected
+}
+
+// Save the odometer counters with mutex lock if they changed.
+func (data *Odometer) save() {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	if !data.Changed {
+		return
+	}
+
+	if err := data.Counters.Save(*global.OdometerFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot save odometer: %s\n", err)
+
+		return
+	}
+
+	data.Changed = false
+}
+
+// Reset the trip counter with mutex lock and save the counters. The current trip is not reset.
+func (data *Odometer) ResetTrip() error {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.LastUpdate = time.Now()
+	data.Counters.Trip = 0
+	data.Counters.TripMovingTime = 0
+	data.Counters.TripReset = time.Now().UTC()
+
+	if err := data.Counters.Save(*global.OdometerFile); err != nil {
+		data.Changed = true
+
+		return err
+	}
+
+	data.Changed = false
+
+	return nil
+}
+
+// Count the distance and moving time to a new GPS fix with mutex lock. Only 3D fixes within the maximum horizontal
+// error are used. Distance is only counted above the minimum speed, so GPS drift while parked is ignored, and
+// position changes that are not possible at the GPS speed within the horizontal errors are rejected as jumps.
+func (data *Odometer) update(fix TpvReport) {
+	if fix.Mode < 3 || fix.Eph > *global.OdometerMaxEph {
+		return
+	}
+
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	if data.previous == nil {
+		data.previous = &fix
+
+		return
+	}
+
+	previous := data.previous
+	dt := fix.LastUpdate.Sub(previous.LastUpdate).Seconds()
+	speed := math.Max(fix.Speed, previous.Speed)
+	counters := &data.Counters
+
+	if dt <= 0 {
+		return
+	}
+
+	if speed >= *global.OdometerMinSpeed {
+		data.lastMoving = fix.LastUpdate
+
+		if !counters.TripActive {
+			counters.TripActive = true
+			counters.TripStart = time.Now().UTC()
+			counters.TripEnd = time.Time{}
+			counters.CurrentTrip = 0
+			counters.CurrentTripMovingTime = 0
+		}
+	} else {
+		if counters.TripActive && fix.LastUpdate.Sub(data.lastMoving).Seconds() > *global.OdometerTripTimeout {
+			counters.TripActive = false
+			counters.TripEnd = data.lastMoving.UTC()
+			data.LastUpdate = time.Now()
+			data.Changed = true
+		}
+
+		// The previous fix is kept, so only the distance from where the vehicle stopped is counted.
+		return
+	}
+
+	distance := geo.Distance(previous.Lat, previous.Lon, fix.Lat, fix.Lon)
+
+	// A rejected fix does not become the previous fix, so the next fix is compared to the last accepted position.
+	if distance > speed*dt*jumpSpeedFactor+previous.Eph+fix.Eph {
+		data.Rejected++
+
+		if *global.Verbose {
+			fmt.Printf("[%v] Odometer rejected a position jump of %.1f m in %.1f s\n", time.Now().UTC(), distance, dt)
+		}
+
+		return
+	}
+
+	data.previous = &fix
+	movingTime := math.Min(dt, maxMovingInterval)
+	counters.Total += distance
+	counters.Trip += distance
+	counters.CurrentTrip += distance
+	counters.MovingTime += movingTime
+	counters.TripMovingTime += movingTime
+	counters.CurrentTripMovingTime += movingTime
+	data.LastUpdate = time.Now()
+	data.Changed = true
+}
+
+// Start counting the travelled distance of the GPS fixes. The counters are saved periodically and on shutdown.
+func (data *Odometer) Start(gpsData *Gps, done chan struct{}) error {
+	fmt.Printf("Starting odometer... ")
+
+	counters, err := LoadCounters(*global.OdometerFile)
+
+	if err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Failed to load odometer: %s\n", err)
+
+		close(done)
+
+		return err
+	}
+
+	data.Mutex.Lock()
+	data.LastUpdate = time.Now()
+	data.Counters = counters
+	data.lastMoving = time.Now()
+	data.Mutex.Unlock()
+
+	ticker := time.NewTicker(odometerPollInterval)
+	saveTicker := time.NewTicker(odometerSaveInterval)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		lastFix := time.Time{}
+
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				saveTicker.Stop()
+				data.save()
+				global.Wg.Done()
+				return
+			case <-saveTicker.C:
+				data.save()
+			case <-ticker.C:
+				fix := gpsData.GetFix()
+
+				if fix.LastUpdate.After(lastFix) {
+					lastFix = fix.LastUpdate
+					data.update(fix)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,505 @@
+package interference
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/pkg/gpsd"
+)
+
+// Interference status.
+const (
+	StatusNone      = "none"
+	StatusSuspected = "suspected"
+	StatusLikely    = "likely"
+)
+
+// Interference indicators.
+const (
+	IndicatorCn0Drop    = "cn0Drop"
+	IndicatorUniformCn0 = "uniformCn0"
+	IndicatorJump       = "jump"
+	IndicatorTime       = "timeDiscontinuity"
+)
+
+// All indicators in the order of the indicator bits of the interference CAN frame.
+var Indicators = []string{IndicatorCn0Drop, IndicatorUniformCn0, IndicatorJump, IndicatorTime}
+
+const (
+	pollInterval         = 100 * time.Millisecond // Interval between checks for new GPS reports.
+	saveInterval         = time.Minute            // Interval between saves of changed events.
+	baselineTimeConstant = 60 * time.Second       // Time constant of the C/N0 baseline per satellite.
+	baselineExpiry       = 5 * time.Minute        // Baselines of satellites without a signal for this duration are removed.
+	minSatellites        = 4                      // Minimum number of satellites with a signal to analyze the C/N0.
+)
+
+// Struct to store a change of the interference status or the active indicators, with the details of the active
+// indicators as evidence and the last GPS position.
+type Event struct {
+	Id         uint64    `json:"id"`
+	Time       time.Time `json:"time"`
+	Status     string    `json:"status"`
+	Indicators []string  `json:"indicators"`
+	Details    []string  `json:"details"`
+	Cn0        float64   `json:"cn0"`
+	Satellites int       `json:"satellites"`
+	Lat        float64   `json:"lat"`
+	Lon        float64   `json:"lon"`
+}
+
+// Struct to store the interference events and the number of status changes in the interference file.
+type History struct {
+	Count  uint64  `json:"count"`
+	Events []Event `json:"events"`
+}
+
+// Struct to store the interference status, the active indicators, and the mean, baseline, and standard deviation
+// [dB-Hz] of the C/N0 of the satellites with a signal.
+type Status struct {
+	Status      string
+	Indicators  []string
+	Cn0         float64
+	Cn0Baseline float64
+	Cn0Spread   float64
+	Satellites  int
+	Count       uint64
+}
+
+// Struct to store the C/N0 baseline [dB-Hz] of a satellite.
+type baseline struct {
+	Cn0      float64
+	LastSeen time.Time
+}
+
+// Struct to identify a satellite.
+type satelliteKey struct {
+	Gnssid uint8
+	Prn    float64
+}
+
+// Struct to store the interference status and events.
+type Detector struct {
+	Mutex      sync.RWMutex
+	LastUpdate time.Time
+	Status     Status
+	Events     []Event
+	Changed    bool
+	nextId     uint64
+	triggered  map[string]time.Time
+	details    map[string]string
+	baselines  map[satelliteKey]*baseline
+	frozen     time.Time
+	previous   *gps.TpvReport
+}
+
+// Load the interference file. Returns an empty history if the file does not exist.
+func LoadHistory(path string) (History, error) {
+	history := History{Events: []Event{}}
+	content, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	} else if err != nil {
+		return history, err
+	}
+
+	if err := json.Unmarshal(content, &history); err != nil {
+		return history, fmt.Errorf("cannot parse %s: %s", path, err)
+	}
+
+	return history, nil
+}
+
+// Save the interference file. The file is replaced atomically, so the events survive a power loss while saving.
+func (history *History) Save(path string) error {
+	content, err := json.MarshalIndent(history, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".interference-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(append(content, '\n')); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	// The content is flushed to disk before the rename, so the renamed file is never empty after a power loss.
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+
+	// The rename is only durable once the directory is flushed to disk.
+	dir, err := os.Open(filepath.Dir(path))
+
+	if err != nil {
+		return err
+	}
+
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+// Save the interference events with mutex lock if they changed.
+func (data *Detector) save() {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	if !data.Changed {
+		return
+	}
+
+	history := History{Count: data.Status.Count, Events: data.Events}
+
+	if err := history.Save(*global.InterferenceFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot save interference events: %s\n", err)
+
+		return
+	}
+
+	data.Changed = false
+}
+
+// Get the interference status with mutex lock.
+func (data *Detector) Get() (time.Time, Status) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	status := data.Status
+	status.Indicators = append([]string{}, data.Status.Indicators...)
+
+	return data.LastUpdate, status
+}
+
+// Get all events with an ID above the given ID with mutex lock.
+func (data *Detector) GetSince(id uint64) []Event {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	events := []Event{}
+
+	for _, event := range data.Events {
+		if event.Id > id {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// Get the mean and standard deviation of values.
+func meanDeviation(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	mean := 0.0
+
+	for _, value := range values {
+		mean += value
+	}
+
+	mean /= float64(len(values))
+	variance := 0.0
+
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// Trigger an indicator with mutex lock held.
+func (data *Detector) trigger(now time.Time, indicator, detail string) {
+	data.triggered[indicator] = now
+	data.details[indicator] = detail
+
+	if *global.Verbose {
+		fmt.Printf("[%v] Interference indicator %s: %s\n", time.Now().UTC(), indicator, detail)
+	}
+}
+
+// Analyze the C/N0 of the satellites with mutex lock held. Jamming raises the noise floor, so the C/N0 of all
+// satellites drops by a similar amount, while obstructions only affect some satellites. A spoofer transmits all
+// signals from a single antenna, so the C/N0 is abnormally uniform across satellites at different elevations.
+func (data *Detector) updateSky(now time.Time, satellites []gpsd.Satellite) {
+	signals := []float64{}
+	drops := []float64{}
+	baselines := []float64{}
+
+	for _, satellite := range satellites {
+		if satellite.Ss <= 0 {
+			continue
+		}
+
+		signals = append(signals, satellite.Ss)
+
+		if satelliteBaseline, ok := data.baselines[satelliteKey{satellite.Gnssid, satellite.PRN}]; ok {
+			drops = append(drops, satelliteBaseline.Cn0-satellite.Ss)
+			baselines = append(baselines, satelliteBaseline.Cn0)
+		}
+	}
+
+	cn0, spread := meanDeviation(signals)
+	drop, dropSpread := meanDeviation(drops)
+	dropped := len(drops) >= minSatellites && drop >= *global.InterferenceCn0Drop && dropSpread <= *global.InterferenceCn0Drop/2
+
+	if dropped {
+		data.trigger(now, IndicatorCn0Drop, fmt.Sprintf("Mean C/N0 dropped %.1f dB-Hz with a standard deviation of %.1f dB-Hz on %d satellites", drop, dropSpread, len(drops)))
+	}
+
+	if len(signals) >= minSatellites && spread < *global.InterferenceCn0Spread {
+		data.trigger(now, IndicatorUniformCn0, fmt.Sprintf("C/N0 standard deviation of %.1f dB-Hz on %d satellites", spread, len(signals)))
+	}
+
+	// The baselines are not updated during a drop, so they keep the C/N0 from before the jamming. A drop that lasts
+	// longer than the maximum freeze duration is a change of the environment, e.g. a parking garage, so the baselines
+	// follow the C/N0 again until the drop ends.
+	if !dropped {
+		data.frozen = time.Time{}
+	} else if data.frozen.IsZero() {
+		data.frozen = now
+	}
+
+	freeze := dropped && now.Sub(data.frozen).Seconds() < *global.InterferenceMaxFreeze
+
+	for _, satellite := range satellites {
+		if satellite.Ss <= 0 {
+			continue
+		}
+
+		key := satelliteKey{satellite.Gnssid, satellite.PRN}
+		satelliteBaseline, ok := data.baselines[key]
+
+		if !ok {
+			data.baselines[key] = &baseline{Cn0: satellite.Ss, LastSeen: now}
+
+			continue
+		}
+
+		if !freeze {
+			dt := now.Sub(satelliteBaseline.LastSeen).Seconds()
+			satelliteBaseline.Cn0 += dt / (baselineTimeConstant.Seconds() + dt) * (satellite.Ss - satelliteBaseline.Cn0)
+		}
+
+		satelliteBaseline.LastSeen = now
+	}
+
+	for key, satelliteBaseline := range data.baselines {
+		if now.Sub(satelliteBaseline.LastSeen) > baselineExpiry {
+			delete(data.baselines, key)
+		}
+	}
+
+	data.LastUpdate = time.Now()
+	data.Status.Cn0 = cn0
+	data.Status.Cn0Spread = spread
+	data.Status.Satellites = len(signals)
+	data.Status.Cn0Baseline, _ = meanDeviation(baselines)
+}
+
+// Check a new GPS fix against the previous fix with mutex lock held. A spoofer or a faulty receiver can cause position
+// and velocity jumps that are not possible for the vehicle, and jumps of the GPS time compared to the system time.
+func (data *Detector) updateFix(now time.Time, fix gps.TpvReport) {
+	if fix.Mode < 2 {
+		return
+	}
+
+	previous := data.previous
+	data.previous = &fix
+
+	if previous == nil {
+		return
+	}
+
+	dt := fix.LastUpdate.Sub(previous.LastUpdate).Seconds()
+
+	if dt <= 0 {
+		return
+	}
+
+	if !fix.Time.IsZero() && !previous.Time.IsZero() {
+		gpsDt := fix.Time.Sub(previous.Time).Seconds()
+
+		if math.Abs(gpsDt-dt) > *global.InterferenceTimeJump {
+			data.trigger(now, IndicatorTime, fmt.Sprintf("GPS time advanced %.3f s in %.3f s", gpsDt, dt))
+		}
+	}
+
+	// GPSd can report multiple fixes per second, so speed changes are compared over at least a second.
+	distance := geo.Distance(previous.Lat, previous.Lon, fix.Lat, fix.Lon)
+
+	if distance > *global.InterferenceMaxSpeed*dt+previous.Eph+fix.Eph {
+		data.trigger(now, IndicatorJump, fmt.Sprintf("Position jumped %.0f m in %.1f s", distance, dt))
+	} else if change := math.Abs(fix.Speed - previous.Speed); change/math.Max(dt, 1) > *global.InterferenceMaxAccel {
+		data.trigger(now, IndicatorJump, fmt.Sprintf("Speed changed %.1f m/s in %.1f s", change, dt))
+	}
+}
+
+// Update the status from the indicators that are active within the hold duration with mutex lock held. An event is
+// stored when the status or the active indicators change.
+func (data *Detector) evaluate(now time.Time) {
+	hold := time.Duration(*global.InterferenceHold * float64(time.Second))
+	indicators := []string{}
+	details := []string{}
+
+	for _, indicator := range Indicators {
+		if triggered, ok := data.triggered[indicator]; ok && now.Sub(triggered) < hold {
+			indicators = append(indicators, indicator)
+			details = append(details, data.details[indicator])
+		}
+	}
+
+	status := StatusNone
+
+	if len(indicators) == 1 {
+		status = StatusSuspected
+	} else if len(indicators) > 1 {
+		status = StatusLikely
+	}
+
+	if status == data.Status.Status && slices.Equal(indicators, data.Status.Indicators) {
+		return
+	}
+
+	data.LastUpdate = time.Now()
+	data.Status.Status = status
+	data.Status.Indicators = indicators
+	data.Status.Count++
+	data.nextId++
+
+	event := Event{
+		Id:         data.nextId,
+		Time:       time.Now().UTC(),
+		Status:     status,
+		Indicators: indicators,
+		Details:    details,
+		Cn0:        data.Status.Cn0,
+		Satellites: data.Status.Satellites,
+	}
+
+	if data.previous != nil {
+		event.Lat, event.Lon = data.previous.Lat, data.previous.Lon
+	}
+
+	data.Events = append(data.Events, event)
+	data.Changed = true
+
+	if uint64(len(data.Events)) > *global.InterferenceHistory {
+		data.Events = data.Events[uint64(len(data.Events))-*global.InterferenceHistory:]
+	}
+
+	if *global.Verbose {
+		fmt.Printf("[%v] GNSS interference %s: %v\n", time.Now().UTC(), status, indicators)
+	}
+}
+
+// Start detecting jamming and spoofing for each new GPS SKY and TPV report. The events are saved periodically and on
+// shutdown.
+func (data *Detector) Start(gpsData *gps.Gps, done chan struct{}) error {
+	fmt.Printf("Starting interference detector... ")
+
+	history, err := LoadHistory(*global.InterferenceFile)
+
+	if err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Failed to load interference events: %s\n", err)
+
+		close(done)
+
+		return err
+	}
+
+	if uint64(len(history.Events)) > *global.InterferenceHistory {
+		history.Events = history.Events[uint64(len(history.Events))-*global.InterferenceHistory:]
+	}
+
+	data.Mutex.Lock()
+	data.LastUpdate = time.Now()
+	data.Status = Status{Status: StatusNone, Indicators: []string{}, Count: history.Count}
+	data.Events = history.Events
+
+	// Event IDs continue after the saved events, so clients polling with ?since=<id> do not miss new events.
+	if len(data.Events) > 0 {
+		data.nextId = data.Events[len(data.Events)-1].Id
+	}
+
+	data.triggered = make(map[string]time.Time)
+	data.details = make(map[string]string)
+	data.baselines = make(map[satelliteKey]*baseline)
+	data.Mutex.Unlock()
+
+	ticker := time.NewTicker(pollInterval)
+	saveTicker := time.NewTicker(saveInterval)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		lastSky := time.Time{}
+		lastFix := time.Time{}
+
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				saveTicker.Stop()
+				data.save()
+				global.Wg.Done()
+				return
+			case <-saveTicker.C:
+				data.save()
+			case now := <-ticker.C:
+				skyUpdate, _, _, _, _, _, _, _, _, _, _, satellites := gpsData.GetSky()
+				fix := gpsData.GetFix()
+
+				data.Mutex.Lock()
+
+				if skyUpdate.After(lastSky) {
+					lastSky = skyUpdate
+					data.updateSky(now, satellites)
+				}
+
+				if fix.LastUpdate.After(lastFix) {
+					lastFix = fix.LastUpdate
+					data.updateFix(now, fix)
+				}
+
+				data.evaluate(now)
+				data.Mutex.Unlock()
+			}
+		}
+	}()
+
+	return nil
+}
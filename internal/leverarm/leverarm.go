@@ -0,0 +1,167 @@
+package leverarm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Interval between attitude updates.
+const updateInterval = 100 * time.Millisecond
+
+// Time constant of the low-pass filter on the pitch and roll, so vibration and driving accelerations do not move the
+// control point.
+const attitudeTimeConstant = time.Second
+
+// Struct to store the lever arm [m] from the GPS antenna to the control point in the vehicle axes (X forward, Y left,
+// Z up), and the attitude [°] of the vehicle used to rotate it. The heading is clockwise from north, pitch is positive
+// nose up, and roll is positive right side down.
+type Arm struct {
+	X       float64
+	Y       float64
+	Z       float64
+	Heading float64
+	Pitch   float64
+	Roll    float64
+}
+
+// Struct to store the lever arm correction and whether it is applied to the reported positions.
+type LeverArm struct {
+	Mutex   sync.RWMutex
+	Arm     Arm
+	Applied bool
+}
+
+// Parse a lever arm of 3 comma separated values.
+func ParseArm(value string) (Arm, error) {
+	arm := Arm{}
+	values := strings.Split(value, ",")
+
+	if len(values) != 3 {
+		return arm, errors.New("lever arm requires 3 comma separated values")
+	}
+
+	numbers := [3]float64{}
+
+	for i, value := range values {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+		if err != nil {
+			return arm, errors.New("invalid lever arm value " + value)
+		}
+
+		numbers[i] = number
+	}
+
+	arm.X, arm.Y, arm.Z = numbers[0], numbers[1], numbers[2]
+
+	return arm, nil
+}
+
+// Rotate the lever arm from the vehicle axes to east, north, and up [m] using the attitude.
+func (arm Arm) Enu() (float64, float64, float64) {
+	sinRoll, cosRoll := math.Sincos(arm.Roll * math.Pi / 180)
+	sinPitch, cosPitch := math.Sincos(arm.Pitch * math.Pi / 180)
+	sinHeading, cosHeading := math.Sincos(arm.Heading * math.Pi / 180)
+
+	// Level the vehicle axes by rotating around X for roll, then around Y for pitch.
+	left := arm.Y*cosRoll - arm.Z*sinRoll
+	up := arm.Y*sinRoll + arm.Z*cosRoll
+	forward := arm.X*cosPitch - up*sinPitch
+	up = arm.X*sinPitch + up*cosPitch
+
+	return forward*sinHeading - left*cosHeading, forward*cosHeading + left*sinHeading, up
+}
+
+// Store the lever arm with the current attitude with mutex lock, and move the reported positions to the control point.
+func (data *LeverArm) Store(gpsData *gps.Gps, arm Arm) {
+	data.Mutex.Lock()
+	data.Arm = arm
+	data.Applied = true
+	data.Mutex.Unlock()
+
+	gpsData.StoreControlOffset(arm.Enu())
+}
+
+// Get the lever arm with the current attitude with mutex lock. Returns false if no lever arm is applied yet.
+func (data *LeverArm) Get() (Arm, bool) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.Arm, data.Applied
+}
+
+// Start tracking the attitude of the vehicle and apply the lever arm to the reported positions. The lever arm is
+// applied once the GPS course is known, until then the antenna position is reported.
+func (data *LeverArm) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan struct{}) error {
+	fmt.Printf("Starting lever arm correction... ")
+
+	arm, err := ParseArm(*global.LeverArm)
+
+	if err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Invalid lever arm %s: %s\n", *global.LeverArm, err)
+
+		close(done)
+
+		return err
+	}
+
+	ticker := time.NewTicker(updateInterval)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		headingKnown := false
+		lastOrientation := time.Time{}
+		alpha := updateInterval.Seconds() / (attitudeTimeConstant.Seconds() + updateInterval.Seconds())
+
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				global.Wg.Done()
+				return
+			case <-ticker.C:
+				fix := gpsData.GetFix()
+
+				if fix.Mode >= 2 && fix.Speed >= *global.LeverArmMinSpeed {
+					if !headingKnown && *global.Verbose {
+						fmt.Printf("[%v] GPS course known, lever arm applied\n", time.Now().UTC())
+					}
+
+					arm.Heading = fix.Track
+					headingKnown = true
+				}
+
+				if lastUpdate, pitch, roll := motionData.GetOrientation(); lastUpdate.After(lastOrientation) {
+					if lastOrientation.IsZero() {
+						arm.Pitch, arm.Roll = pitch, roll
+					} else {
+						arm.Pitch += alpha * (pitch - arm.Pitch)
+						arm.Roll += alpha * (roll - arm.Roll)
+					}
+
+					lastOrientation = lastUpdate
+				}
+
+				if headingKnown {
+					data.Store(gpsData, arm)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
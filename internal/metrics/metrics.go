@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"sync"
+)
+
+// Struct to store a monotonically increasing counter.
+type Counter struct {
+	Mutex sync.RWMutex
+	Value uint64
+}
+
+// Struct to store a monotonically increasing counter per label value.
+type CounterVec struct {
+	Mutex  sync.RWMutex
+	Values map[string]uint64
+}
+
+// Define the operational counters.
+var (
+	GpsdReports         CounterVec
+	GpsdParseErrors     Counter
+	CanFramesSent       Counter
+	CanFramesFailed     Counter
+	MotionReadFailures  Counter
+	MotionParseFailures Counter
+	HttpRequests        CounterVec
+	HttpRequestsByKey   CounterVec
+)
+
+// Increment counter with mutex lock.
+func (counter *Counter) Inc() {
+	counter.Mutex.Lock()
+	defer counter.Mutex.Unlock()
+
+	counter.Value++
+}
+
+// Get counter value with mutex lock.
+func (counter *Counter) Get() uint64 {
+	counter.Mutex.RLock()
+	defer counter.Mutex.RUnlock()
+
+	return counter.Value
+}
+
+// Increment the counter of a label value with mutex lock.
+func (counter *CounterVec) Inc(label string) {
+	counter.Mutex.Lock()
+	defer counter.Mutex.Unlock()
+
+	if counter.Values == nil {
+		counter.Values = make(map[string]uint64)
+	}
+
+	counter.Values[label]++
+}
+
+// Get a copy of all label values and their counters with mutex lock.
+func (counter *CounterVec) Get() map[string]uint64 {
+	counter.Mutex.RLock()
+	defer counter.Mutex.RUnlock()
+
+	values := make(map[string]uint64, len(counter.Values))
+
+	for label, value := range counter.Values {
+		values[label] = value
+	}
+
+	return values
+}
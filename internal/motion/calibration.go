@@ -0,0 +1,322 @@
+package motion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+// Calibration methods.
+const (
+	CalibrationLevel          = "level"
+	CalibrationSixOrientation = "six-orientation"
+)
+
+const (
+	calibrationSamples        = 50
+	calibrationSampleInterval = 20 * time.Millisecond
+	calibrationTimeout        = time.Second // Maximum time to wait for a sample of the motion sampler.
+	calibrationMaxDeviation   = 50          // Maximum peak-to-peak deviation [mg] of a stationary sensor.
+	calibrationMinAligned     = 800         // Minimum gravity [mg] on the axis pointing up or down.
+	calibrationMaxMisaligned  = 300         // Maximum gravity [mg] on the other axes.
+	gravity                   = 1000
+)
+
+// Orientations of the six-orientation calibration, named by the sensor axis pointing up.
+var CalibrationOrientations = []string{"+x", "-x", "+y", "-y", "+z", "-z"}
+
+// Struct to store the per-axis offset [mg] and scale of the motion sensor. Calibrated values are
+// (raw - offset) * scale.
+type Calibration struct {
+	Method string     `json:"method"`
+	Time   time.Time  `json:"time"`
+	Offset [3]float64 `json:"offset"`
+	Scale  [3]float64 `json:"scale"`
+}
+
+// Load the calibration file. Returns nil if the file does not exist.
+func LoadCalibration(path string) (*Calibration, error) {
+	content, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	calibration := Calibration{}
+
+	if err := json.Unmarshal(content, &calibration); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %s", path, err)
+	}
+
+	for _, scale := range calibration.Scale {
+		if scale <= 0 {
+			return nil, fmt.Errorf("cannot parse %s: scale must be positive", path)
+		}
+	}
+
+	return &calibration, nil
+}
+
+// Save the calibration file.
+func (calibration *Calibration) Save(path string) error {
+	content, err := json.MarshalIndent(calibration, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Apply the calibration to raw motion sensor values.
+func (calibration *Calibration) Apply(x, y, z int16) (int16, int16, int16) {
+	raw := [3]int16{x, y, z}
+	calibrated := [3]int16{}
+
+	for i := range raw {
+		value := math.Round((float64(raw[i]) - calibration.Offset[i]) * calibration.Scale[i])
+		calibrated[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, value)))
+	}
+
+	return calibrated[0], calibrated[1], calibrated[2]
+}
+
+// Sample the raw motion sensor values from the motion sampler and return the mean. Fails if the sensor is not stationary.
+func (data *Motion) sampleStationary() ([3]float64, error) {
+	sum := [3]float64{}
+	minimum := [3]float64{math.MaxFloat64, math.MaxFloat64, math.MaxFloat64}
+	maximum := [3]float64{-math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64}
+
+	samples := data.Subscribe(float64(time.Second / calibrationSampleInterval))
+	defer data.Unsubscribe(samples)
+
+	for i := 0; i < calibrationSamples; i++ {
+		var sample Sample
+
+		select {
+		case sample = <-samples:
+		case <-time.After(calibrationTimeout):
+		}
+
+		if !sample.Readable {
+			return sum, errors.New("cannot read motion sensor")
+		}
+
+		for axis, value := range sample.Raw {
+			sum[axis] += float64(value)
+			minimum[axis] = math.Min(minimum[axis], float64(value))
+			maximum[axis] = math.Max(maximum[axis], float64(value))
+		}
+	}
+
+	mean := [3]float64{}
+
+	for axis := range sum {
+		if maximum[axis]-minimum[axis] > calibrationMaxDeviation {
+			return mean, errors.New("motion sensor is not stationary")
+		}
+
+		mean[axis] = sum[axis] / calibrationSamples
+	}
+
+	return mean, nil
+}
+
+// Detect which sensor axis points up or down, e.g. -z if the sensor is upside down.
+func detectCalibrationOrientation(mean [3]float64) (string, error) {
+	names := []string{"x", "y", "z"}
+
+	for axis := range mean {
+		if math.Abs(mean[axis]) < calibrationMinAligned {
+			continue
+		}
+
+		for other := range mean {
+			if other != axis && math.Abs(mean[other]) > calibrationMaxMisaligned {
+				return "", errors.New("motion sensor is not aligned with an axis")
+			}
+		}
+
+		if mean[axis] > 0 {
+			return "+" + names[axis], nil
+		}
+
+		return "-" + names[axis], nil
+	}
+
+	return "", errors.New("motion sensor is not aligned with an axis")
+}
+
+// Load the mounting matrix, the motion source, and the calibration.
+func (data *Motion) LoadSettings() error {
+	mounting, err := ParseMatrix(*global.MotionMounting)
+
+	if err != nil {
+		return err
+	}
+
+	source, err := NewSource(*global.MotionSource)
+
+	if err != nil {
+		return err
+	}
+
+	calibration, err := LoadCalibration(*global.MotionCalibrationFile)
+
+	if err != nil {
+		return err
+	}
+
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.Mounting = mounting
+	data.Source = source
+	data.Calibration = calibration
+
+	return nil
+}
+
+// Get a copy of the calibration and the pending orientations of a six-orientation calibration with mutex lock.
+// The calibration is nil if the sensor is not calibrated.
+func (data *Motion) GetCalibration() (*Calibration, []string) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	var calibration *Calibration
+
+	if data.Calibration != nil {
+		copied := *data.Calibration
+		calibration = &copied
+	}
+
+	pending := []string{}
+
+	if data.CalibrationSamples != nil {
+		for _, orientation := range CalibrationOrientations {
+			if _, ok := data.CalibrationSamples[orientation]; !ok {
+				pending = append(pending, orientation)
+			}
+		}
+	}
+
+	return calibration, pending
+}
+
+// Store and save a new calibration with mutex lock.
+func (data *Motion) storeCalibration(calibration *Calibration) error {
+	if err := calibration.Save(*global.MotionCalibrationFile); err != nil {
+		return err
+	}
+
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.Calibration = calibration
+	data.CalibrationSamples = nil
+
+	return nil
+}
+
+// Calibrate the offset of the motion sensor while stationary on a level surface. The expected gravity is rotated
+// from the vehicle axes to the sensor axes using the mounting matrix. The scale is reset to 1.
+func (data *Motion) CalibrateLevel() error {
+	mean, err := data.sampleStationary()
+
+	if err != nil {
+		return err
+	}
+
+	data.Mutex.RLock()
+	mounting := data.Mounting
+	data.Mutex.RUnlock()
+
+	// The transpose of a rotation matrix is its inverse.
+	expected := [3]float64{mounting[2][0] * gravity, mounting[2][1] * gravity, mounting[2][2] * gravity}
+	calibration := Calibration{
+		Method: CalibrationLevel,
+		Time:   time.Now().UTC(),
+		Scale:  [3]float64{1, 1, 1},
+	}
+
+	for axis := range mean {
+		calibration.Offset[axis] = mean[axis] - expected[axis]
+	}
+
+	return data.storeCalibration(&calibration)
+}
+
+// Sample one orientation of a six-orientation calibration. The orientation is detected automatically. The offset
+// and scale are computed and saved once all six orientations are sampled. Returns the sampled orientation.
+func (data *Motion) CalibrateOrientation() (string, error) {
+	mean, err := data.sampleStationary()
+
+	if err != nil {
+		return "", err
+	}
+
+	orientation, err := detectCalibrationOrientation(mean)
+
+	if err != nil {
+		return "", err
+	}
+
+	data.Mutex.Lock()
+
+	if data.CalibrationSamples == nil {
+		data.CalibrationSamples = make(map[string][3]float64)
+	}
+
+	data.CalibrationSamples[orientation] = mean
+	samples := map[string][3]float64{}
+
+	for name, sample := range data.CalibrationSamples {
+		samples[name] = sample
+	}
+
+	data.Mutex.Unlock()
+
+	if len(samples) < len(CalibrationOrientations) {
+		return orientation, nil
+	}
+
+	calibration := Calibration{
+		Method: CalibrationSixOrientation,
+		Time:   time.Now().UTC(),
+	}
+
+	names := []string{"x", "y", "z"}
+
+	for axis, name := range names {
+		up := samples["+"+name][axis]
+		down := samples["-"+name][axis]
+		calibration.Offset[axis] = (up + down) / 2
+		calibration.Scale[axis] = 2 * gravity / (up - down)
+	}
+
+	return orientation, data.storeCalibration(&calibration)
+}
+
+// Remove the calibration and cancel a pending six-orientation calibration.
+func (data *Motion) ResetCalibration() error {
+	err := os.Remove(*global.MotionCalibrationFile)
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.Calibration = nil
+	data.CalibrationSamples = nil
+
+	return nil
+}
@@ -3,11 +3,8 @@ package motion
 import (
 	"errors"
 	"fmt"
-	"io"
+	"math"
 	"os"
-	"regexp"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -15,31 +12,76 @@ import (
 	"github.com/vuhuy/tcg4-sensor/internal/helper"
 )
 
-const motionSensorXyzPath = "/sys/bus/i2c/devices/0-0018/xyz"
-const motionSensorControlPath = "/sys/bus/i2c/devices/0-0018/control"
-
-var cachedScaleValue uint8 = 0
-
-// Struct to store motion data from I2C Sysfs.
+// Struct to store motion data from the motion source.
 type Motion struct {
 	Mutex      sync.RWMutex
 	LastUpdate time.Time
+	Readable   bool
 	X          int16
 	Y          int16
 	Z          int16
 	Scale      uint8
+	DataRate   float64
+	Mounting   Matrix
+	Source     Source
+
+	Calibration        *Calibration
+	CalibrationSamples map[string][3]float64
+
+	subscribers []*subscriber
+}
+
+// Calibrated motion sensor sample in vehicle axes (X forward, Y left, Z up) [mg], with the raw values in the sensor
+// axes. The sample is not readable if reading the motion sensor failed.
+type Sample struct {
+	Time     time.Time
+	Readable bool
+	X        float64
+	Y        float64
+	Z        float64
+	Raw      [3]int16
+}
+
+// Struct to store a subscriber of the shared motion sampler.
+type subscriber struct {
+	Interval time.Duration
+	Last     time.Time
+	Samples  chan Sample
 }
 
-// Store motion data with mutex lock.
-func (data *Motion) Store(x, y, z int16, scale uint8) {
+// Number of samples buffered for each subscriber. Samples are dropped if a subscriber falls further behind.
+const subscriberBuffer = 16
+
+// Store motion data with mutex lock. The calibration is applied to the raw values.
+func (data *Motion) Store(x, y, z int16) {
 	data.Mutex.Lock()
 	defer data.Mutex.Unlock()
 
+	if data.Calibration != nil {
+		x, y, z = data.Calibration.Apply(x, y, z)
+	}
+
 	data.LastUpdate = time.Now()
 	data.X = x
 	data.Y = y
 	data.Z = z
+}
+
+// Store the motion sensor scale and data rate with mutex lock.
+func (data *Motion) StoreSettings(scale uint8, dataRate float64) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
 	data.Scale = scale
+	data.DataRate = dataRate
+}
+
+// Get the motion sensor data rate with mutex lock.
+func (data *Motion) GetDataRate() float64 {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.DataRate
 }
 
 // Get motion data with mutex lock.
@@ -50,153 +92,212 @@ func (data *Motion) Get() (time.Time, int16, int16, int16, uint8) {
 	return data.LastUpdate, data.X, data.Y, data.Z, data.Scale
 }
 
-// Check if file exists.
-func fileExists(filename string) bool {
-	info, err := os.Stat(filename)
+// Store whether the last motion sensor read succeeded with mutex lock.
+func (data *Motion) StoreReadable(readable bool) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
 
-	if os.IsNotExist(err) {
-		return false
-	}
+	data.Readable = readable
+}
+
+// Get whether the last motion sensor read succeeded with mutex lock.
+func (data *Motion) GetReadable() bool {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
 
-	return !info.IsDir()
+	return data.Readable
 }
 
-// Start updating the motion data by reading the motion sensor periodically.
-func (data *Motion) Start(done chan struct{}) error {
-	fmt.Printf("Starting motion monitor... ")
+// Subscribe to the motion sensor samples at a sample rate [Hz]. The motion sensor is read once by a shared sampler at
+// the highest rate of the motion frequency and all subscribers, and each sample is fanned out to the subscribers.
+func (data *Motion) Subscribe(rate float64) chan Sample {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
 
-	ticker := time.NewTicker(time.Duration(helper.ConvertHzToMilliseconds(*global.MotionFrequency)) * time.Millisecond)
+	samples := make(chan Sample, subscriberBuffer)
+	data.subscribers = append(data.subscribers, &subscriber{Interval: time.Duration(float64(time.Second) / rate), Samples: samples})
 
-	if !fileExists(motionSensorXyzPath) || !fileExists(motionSensorControlPath) {
-		fmt.Printf("Fail\n")
-		fmt.Fprintf(os.Stderr, "Failed to read motion sensor: snsor i2c Sysfs file does not exists")
+	return samples
+}
 
-		close(done)
+// Stop sending motion sensor samples to a subscriber.
+func (data *Motion) Unsubscribe(samples chan Sample) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
 
-		return errors.New("sensor i2c Sysfs file does not exists")
+	for i, subscriber := range data.subscribers {
+		if subscriber.Samples == samples {
+			data.subscribers = append(data.subscribers[:i], data.subscribers[i+1:]...)
+			return
+		}
 	}
+}
 
-	fmt.Print("OK\n")
+// Get the sample interval of the shared sampler, the shortest interval of the motion frequency and all subscribers.
+func (data *Motion) sampleInterval(interval time.Duration) time.Duration {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
 
-	global.Wg.Add(1)
+	for _, subscriber := range data.subscribers {
+		if subscriber.Interval < interval {
+			interval = subscriber.Interval
+		}
+	}
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				ticker.Stop()
-				global.Wg.Done()
-				return
-			case <-ticker.C:
-				xyzSuccess, x, y, z := readXyz()
+	return interval
+}
 
-				if cachedScaleValue == 0 {
-					scaleSuccess, scale := readScale()
+// Send a sample to the subscribers whose interval has passed. A sample is due if less than half a sampler interval
+// remains, so a subscriber at half the sampler rate gets every second sample.
+func (data *Motion) fanOut(sample Sample, interval time.Duration) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
 
-					if xyzSuccess && scaleSuccess {
-						cachedScaleValue = scale
-						data.Store(x, y, z, scale)
-					}
-				} else if xyzSuccess {
-					data.Store(x, y, z, cachedScaleValue)
-				}
-			}
+	for _, subscriber := range data.subscribers {
+		if sample.Time.Sub(subscriber.Last) < subscriber.Interval-interval/2 {
+			continue
 		}
-	}()
 
-	return nil
+		subscriber.Last = sample.Time
+
+		select {
+		case subscriber.Samples <- sample:
+		default:
+		}
+	}
 }
 
-// Read xyz values from I2C Sysfs.
-func readXyz() (bool, int16, int16, int16) {
-	file, openErr := os.Open(motionSensorXyzPath)
+// Read a motion sensor sample, and apply the calibration and the mounting matrix.
+func (data *Motion) readSample(now time.Time) Sample {
+	success, x, y, z := data.Source.ReadXyz()
 
-	if openErr != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open motion sensor: %s\n", openErr)
+	if !success {
+		return Sample{Time: now}
+	}
 
-		return false, 0, 0, 0
+	data.Mutex.RLock()
+	calibration := data.Calibration
+	mounting := data.Mounting
+	data.Mutex.RUnlock()
+
+	sample := Sample{Time: now, Readable: true, Raw: [3]int16{x, y, z}}
+
+	if calibration != nil {
+		x, y, z = calibration.Apply(x, y, z)
 	}
 
-	defer file.Close()
+	sample.X, sample.Y, sample.Z = mounting.Rotate(float64(x), float64(y), float64(z))
+
+	return sample
+}
 
-	xyz, readErr := io.ReadAll(file)
+// Start updating the motion data by reading the motion sensor periodically.
+func (data *Motion) Start(done chan struct{}) error {
+	fmt.Printf("Starting motion monitor... ")
 
-	if readErr != nil {
-		fmt.Fprintf(os.Stderr, "Cannot read motion sensor: %s\n", readErr)
+	frequency := *global.MotionFrequency
+	motionInterval := time.Duration(helper.ConvertHzToMilliseconds(frequency)) * time.Millisecond
+	interval := data.sampleInterval(motionInterval)
+	ticker := time.NewTicker(interval)
 
-		return false, 0, 0, 0
-	}
+	if err := data.LoadSettings(); err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Failed to load motion settings: %s\n", err)
 
-	xyzString := string(xyz[:])
-	xyzSplit := strings.Split(xyzString, ";")
+		close(done)
 
-	for i := range xyzSplit {
-		xyzSplit[i] = strings.TrimSpace(xyzSplit[i])
+		return err
 	}
 
-	if len(xyzSplit) != 3 {
-		fmt.Fprintf(os.Stderr, "Error parsing motion sensor data: %s\n", xyzString)
+	if err := data.Source.Check(); err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Failed to read motion sensor: %s\n", err)
+
+		close(done)
 
-		return false, 0, 0, 0
+		return err
 	}
 
-	x, xParseErr := strconv.ParseInt(xyzSplit[0], 10, 16)
-	y, yParseErr := strconv.ParseInt(xyzSplit[1], 10, 16)
-	z, zParseErr := strconv.ParseInt(xyzSplit[2], 10, 16)
+	if *global.MotionRange > math.MaxUint8 || *global.MotionDataRate < 0 {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Invalid motion sensor range %d or data rate %f\n", *global.MotionRange, *global.MotionDataRate)
 
-	if xParseErr != nil || yParseErr != nil || zParseErr != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing motion sensor data: %s\n", xyzString)
+		close(done)
 
-		return false, 0, 0, 0
+		return errors.New("invalid motion sensor range or data rate")
 	}
 
-	if *global.Verbose {
-		fmt.Printf("[%v] Read motion sensor XYZ: (%d; %d; %d)\n", time.Now().UTC(), x, y, z)
+	if err := configureSource(data.Source, uint8(*global.MotionRange), *global.MotionDataRate); err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Failed to configure motion sensor: %s\n", err)
+
+		close(done)
+
+		return err
 	}
 
-	return true, int16(x), int16(y), int16(z)
-}
+	fmt.Print("OK\n")
 
-// Read scale from I2C Sysfs.
-func readScale() (bool, uint8) {
-	file, openErr := os.Open(motionSensorControlPath)
+	global.Wg.Add(1)
 
-	if openErr != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open motion settings: %s\n", openErr)
+	go func() {
+		settingsRead := time.Time{}
+		stored := time.Time{}
 
-		return false, 0
-	}
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				global.Wg.Done()
+				return
+			case now := <-ticker.C:
+				// The frequency can be changed at runtime, and subscribers can be added after the sampler started.
+				global.Mutex.RLock()
 
-	defer file.Close()
+				if *global.MotionFrequency != frequency {
+					frequency = *global.MotionFrequency
+					motionInterval = time.Duration(helper.ConvertHzToMilliseconds(frequency)) * time.Millisecond
+				}
 
-	control, readErr := io.ReadAll(file)
+				global.Mutex.RUnlock()
 
-	if readErr != nil {
-		fmt.Fprintf(os.Stderr, "Cannot read motion settings: %s\n", readErr)
+				if sampleInterval := data.sampleInterval(motionInterval); sampleInterval != interval {
+					interval = sampleInterval
+					ticker.Reset(interval)
+				}
 
-		return false, 0
-	}
+				sample := data.readSample(now)
+				data.fanOut(sample, interval)
 
-	scaleRegex := regexp.MustCompile(`scale=(\d*)g`)
-	scaleMatches := scaleRegex.FindAllStringSubmatch(string(control[:]), -1)
+				// The motion data is stored at the motion frequency, so the resource does not change faster.
+				if now.Sub(stored) < motionInterval-interval/2 {
+					continue
+				}
 
-	if len(scaleMatches) != 1 || len(scaleMatches[0]) != 2 {
-		fmt.Fprintf(os.Stderr, "Error parsing motion settings: cannot find scale\n")
+				stored = now
+				settingsSuccess := true
 
-		return false, 0
-	}
+				// The settings can be changed outside the application, so they are read again periodically.
+				if settingsRead.IsZero() || time.Since(settingsRead).Seconds() >= *global.MotionSettingsInterval {
+					var scale uint8
+					var dataRate float64
 
-	scale, parseErr := strconv.Atoi(scaleMatches[0][1])
+					settingsSuccess, scale, dataRate = data.Source.ReadSettings()
 
-	if parseErr != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing motion settings: %s\n", scaleMatches[0][1])
+					if settingsSuccess {
+						settingsRead = time.Now()
+						data.StoreSettings(scale, dataRate)
+					}
+				}
 
-		return false, 0
-	}
+				if sample.Readable && settingsSuccess {
+					data.Store(sample.Raw[0], sample.Raw[1], sample.Raw[2])
+				}
 
-	if *global.Verbose {
-		fmt.Printf("[%v] Read motion sensor scale: %d\n", time.Now().UTC(), scale)
-	}
+				data.StoreReadable(sample.Readable && settingsSuccess)
+			}
+		}
+	}()
 
-	return true, uint8(scale)
+	return nil
 }
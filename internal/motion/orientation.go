@@ -0,0 +1,86 @@
+package motion
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rotation matrix from the motion sensor axes to the vehicle axes.
+type Matrix [3][3]float64
+
+// Parse a row-major rotation matrix of 9 comma separated values.
+func ParseMatrix(value string) (Matrix, error) {
+	matrix := Matrix{}
+	values := strings.Split(value, ",")
+
+	if len(values) != 9 {
+		return matrix, errors.New("mounting matrix requires 9 comma separated values")
+	}
+
+	for i, value := range values {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+		if err != nil {
+			return matrix, errors.New("invalid mounting matrix value " + value)
+		}
+
+		matrix[i/3][i%3] = number
+	}
+
+	// A rotation matrix is orthogonal, so its transpose times itself is the identity, and has a determinant of 1.
+	// Allow some rounding in the configured values.
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			product := matrix[0][i]*matrix[0][j] + matrix[1][i]*matrix[1][j] + matrix[2][i]*matrix[2][j]
+			identity := 0.0
+
+			if i == j {
+				identity = 1
+			}
+
+			if math.Abs(product-identity) > 0.01 {
+				return matrix, errors.New("mounting matrix is not a rotation matrix")
+			}
+		}
+	}
+
+	determinant := matrix[0][0]*(matrix[1][1]*matrix[2][2]-matrix[1][2]*matrix[2][1]) -
+		matrix[0][1]*(matrix[1][0]*matrix[2][2]-matrix[1][2]*matrix[2][0]) +
+		matrix[0][2]*(matrix[1][0]*matrix[2][1]-matrix[1][1]*matrix[2][0])
+
+	if math.Abs(determinant-1) > 0.01 {
+		return matrix, errors.New("mounting matrix is not a rotation matrix")
+	}
+
+	return matrix, nil
+}
+
+// Rotate a motion sensor vector to the vehicle axes (X forward, Y left, Z up).
+func (matrix *Matrix) Rotate(x, y, z float64) (float64, float64, float64) {
+	return matrix[0][0]*x + matrix[0][1]*y + matrix[0][2]*z,
+		matrix[1][0]*x + matrix[1][1]*y + matrix[1][2]*z,
+		matrix[2][0]*x + matrix[2][1]*y + matrix[2][2]*z
+}
+
+// Compute the static pitch and roll [°] from gravity in vehicle axes. Pitch is positive nose up, roll is positive
+// right side down.
+func computeOrientation(x, y, z float64) (float64, float64) {
+	pitch := math.Atan2(x, math.Sqrt(y*y+z*z)) * 180 / math.Pi
+	roll := math.Atan2(y, z) * 180 / math.Pi
+
+	return pitch, roll
+}
+
+// Get the pitch and roll [°] of the vehicle computed from the last motion data with mutex lock.
+func (data *Motion) GetOrientation() (time.Time, float64, float64) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	x, y, z := data.Mounting.Rotate(float64(data.X), float64(data.Y), float64(data.Z))
+	pitch, roll := computeOrientation(x, y, z)
+
+	return data.LastUpdate, pitch, roll
+}
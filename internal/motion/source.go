@@ -0,0 +1,416 @@
+package motion
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/metrics"
+)
+
+// Standard gravity [m/s²], used to convert IIO accelerations to mg.
+const standardGravity = 9.80665
+
+// Default scale [g] of motion sources that do not report one.
+const defaultScale = 2
+
+// Interface of a motion data source. Values are accelerations [mg] in the sensor axes, the scale is the measurement
+// range [g], and the data rate is the output data rate [Hz] of the sensor or 0 if unknown. Configure is called once
+// at startup, a zero scale or data rate keeps the current setting. Reads may be called concurrently.
+type Source interface {
+	Check() error
+	Configure(scale uint8, dataRate float64) error
+	ReadXyz() (bool, int16, int16, int16)
+	ReadSettings() (bool, uint8, float64)
+}
+
+// Minimum time between two motion sensor errors on stderr. A missing or failing sensor fails on every read, so the
+// errors are rate limited. The failures are still counted in the metrics.
+const errorInterval = 10 * time.Second
+
+// Struct to store the rate limit of the motion sensor errors.
+type errorLimiter struct {
+	Mutex      sync.Mutex
+	Last       time.Time
+	Suppressed int
+}
+
+var sourceErrors = errorLimiter{}
+
+// Print a motion sensor error to stderr, unless an error was printed within the error interval. The number of
+// suppressed errors is printed with the next error.
+func (limiter *errorLimiter) Printf(format string, a ...interface{}) {
+	limiter.Mutex.Lock()
+	defer limiter.Mutex.Unlock()
+
+	if !limiter.Last.IsZero() && time.Since(limiter.Last) < errorInterval {
+		limiter.Suppressed++
+
+		return
+	}
+
+	if limiter.Suppressed > 0 {
+		fmt.Fprintf(os.Stderr, "Suppressed %d motion sensor errors\n", limiter.Suppressed)
+	}
+
+	limiter.Last = time.Now()
+	limiter.Suppressed = 0
+
+	fmt.Fprintf(os.Stderr, format, a...)
+}
+
+// Create the motion source by name.
+func NewSource(name string) (Source, error) {
+	switch name {
+	case "sysfs":
+		return &sysfsSource{XyzPath: *global.MotionSysfsXyz, ControlPath: *global.MotionSysfsControl}, nil
+	case "iio":
+		return &iioSource{Device: *global.MotionIioDevice}, nil
+	case "replay":
+		return &replaySource{Path: *global.MotionReplayFile, Rate: *global.MotionReplayRate}, nil
+	case "simulated":
+		return &simulatedSource{StartTime: time.Now(), Scale: defaultScale}, nil
+	}
+
+	return nil, errors.New("unknown motion source " + name)
+}
+
+// Write the scale [g] and the output data rate [Hz] to the motion source, and read them back. A driver may accept a
+// write that it does not support without an error, so the settings are compared with the written values. The data
+// rate may be rounded by the driver, so it must be within 1%.
+func configureSource(source Source, scale uint8, dataRate float64) error {
+	if scale == 0 && dataRate == 0 {
+		return nil
+	}
+
+	if err := source.Configure(scale, dataRate); err != nil {
+		return err
+	}
+
+	success, activeScale, activeDataRate := source.ReadSettings()
+
+	if !success {
+		return errors.New("cannot read the motion sensor settings back")
+	}
+
+	if scale != 0 && activeScale != scale {
+		return fmt.Errorf("the motion sensor range is %d g after writing %d g", activeScale, scale)
+	}
+
+	if dataRate != 0 && activeDataRate == 0 {
+		return errors.New("the motion sensor does not report its data rate, so the written data rate cannot be verified")
+	}
+
+	if dataRate != 0 && math.Abs(activeDataRate-dataRate) > dataRate/100 {
+		return fmt.Errorf("the motion sensor data rate is %g Hz after writing %g Hz", activeDataRate, dataRate)
+	}
+
+	return nil
+}
+
+// Motion source of an accelerometer using the Linux Industrial I/O subsystem.
+type iioSource struct {
+	Device string
+}
+
+// Read a float value from a file of the IIO device.
+func (source *iioSource) readValue(name string) (float64, error) {
+	content, err := os.ReadFile(filepath.Join(source.Device, name))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(strings.TrimSpace(string(content)), 64)
+}
+
+// Check if the IIO device has raw acceleration values and a scale.
+func (source *iioSource) Check() error {
+	for _, name := range []string{"in_accel_x_raw", "in_accel_y_raw", "in_accel_z_raw", "in_accel_scale"} {
+		if !fileExists(filepath.Join(source.Device, name)) {
+			return fmt.Errorf("IIO device %s has no %s", source.Device, name)
+		}
+	}
+
+	return nil
+}
+
+// Read the raw acceleration values and convert them to mg using the IIO scale [m/s² per LSB].
+func (source *iioSource) ReadXyz() (bool, int16, int16, int16) {
+	scale, err := source.readValue("in_accel_scale")
+
+	if err != nil {
+		sourceErrors.Printf("Cannot read IIO accelerometer scale: %s\n", err)
+		metrics.MotionReadFailures.Inc()
+
+		return false, 0, 0, 0
+	}
+
+	values := [3]int16{}
+
+	for i, axis := range []string{"x", "y", "z"} {
+		raw, err := source.readValue("in_accel_" + axis + "_raw")
+
+		if err != nil {
+			sourceErrors.Printf("Cannot read IIO accelerometer: %s\n", err)
+			metrics.MotionReadFailures.Inc()
+
+			return false, 0, 0, 0
+		}
+
+		mg := raw * scale / standardGravity * 1000
+		values[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(mg))))
+	}
+
+	if *global.Verbose {
+		fmt.Printf("[%v] Read IIO motion sensor XYZ: (%d; %d; %d)\n", time.Now().UTC(), values[0], values[1], values[2])
+	}
+
+	return true, values[0], values[1], values[2]
+}
+
+// Get the name of the sampling frequency file of the IIO device, which is either specific to the accelerometer or
+// shared by all channels. Empty if the device has none.
+func (source *iioSource) samplingFrequencyName() string {
+	for _, name := range []string{"in_accel_sampling_frequency", "sampling_frequency"} {
+		if fileExists(filepath.Join(source.Device, name)) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Read the measurement range [g] and the sampling frequency [Hz]. IIO does not expose the range, so it is derived
+// from the scale assuming 16-bit left-justified raw values.
+func (source *iioSource) ReadSettings() (bool, uint8, float64) {
+	scale, err := source.readValue("in_accel_scale")
+
+	if err != nil {
+		sourceErrors.Printf("Cannot read IIO accelerometer scale: %s\n", err)
+		metrics.MotionReadFailures.Inc()
+
+		return false, 0, 0
+	}
+
+	dataRate := 0.0
+
+	if name := source.samplingFrequencyName(); name != "" {
+		if dataRate, err = source.readValue(name); err != nil {
+			sourceErrors.Printf("Cannot read IIO accelerometer sampling frequency: %s\n", err)
+			metrics.MotionReadFailures.Inc()
+
+			return false, 0, 0
+		}
+	}
+
+	return true, uint8(math.Round(scale * 32768 / standardGravity)), dataRate
+}
+
+// Write the scale [m/s² per LSB] derived from the measurement range and the sampling frequency to the IIO device.
+func (source *iioSource) Configure(scale uint8, dataRate float64) error {
+	if scale != 0 {
+		value := strconv.FormatFloat(float64(scale)*standardGravity/32768, 'f', 9, 64)
+
+		if err := os.WriteFile(filepath.Join(source.Device, "in_accel_scale"), []byte(value), 0644); err != nil {
+			return err
+		}
+	}
+
+	if dataRate != 0 {
+		name := source.samplingFrequencyName()
+
+		if name == "" {
+			return fmt.Errorf("IIO device %s has no sampling frequency", source.Device)
+		}
+
+		if err := os.WriteFile(filepath.Join(source.Device, name), []byte(strconv.FormatFloat(dataRate, 'f', -1, 64)), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Struct to store a recorded motion sample.
+type replaySample struct {
+	X     int16
+	Y     int16
+	Z     int16
+	Scale uint8
+}
+
+// Motion source that replays recorded motion data from a file at the sample rate [Hz] of the recording. The sample
+// is selected by the time since the recording was loaded, so all readers see the same sample at the same time, like
+// they would with a motion sensor.
+type replaySource struct {
+	Mutex     sync.Mutex
+	Path      string
+	Rate      float64
+	Samples   []replaySample
+	StartTime time.Time
+}
+
+// Load the recorded motion data. Empty lines and lines starting with # are ignored.
+func (source *replaySource) Check() error {
+	content, err := os.ReadFile(source.Path)
+
+	if err != nil {
+		return err
+	}
+
+	samples := []replaySample{}
+
+	for number, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ";")
+		values := []int64{}
+
+		for _, field := range fields {
+			value, err := strconv.ParseInt(strings.TrimSpace(field), 10, 16)
+
+			if err != nil {
+				return fmt.Errorf("cannot parse line %d of %s", number+1, source.Path)
+			}
+
+			values = append(values, value)
+		}
+
+		if len(values) == 3 {
+			values = append(values, defaultScale)
+		}
+
+		if len(values) != 4 || values[3] < 0 || values[3] > math.MaxUint8 {
+			return fmt.Errorf("cannot parse line %d of %s", number+1, source.Path)
+		}
+
+		samples = append(samples, replaySample{X: int16(values[0]), Y: int16(values[1]), Z: int16(values[2]), Scale: uint8(values[3])})
+	}
+
+	if len(samples) == 0 {
+		return errors.New("no motion samples found in " + source.Path)
+	}
+
+	if source.Rate <= 0 {
+		return fmt.Errorf("invalid replay sample rate %f", source.Rate)
+	}
+
+	source.Mutex.Lock()
+	defer source.Mutex.Unlock()
+
+	source.Samples = samples
+	source.StartTime = time.Now()
+
+	return nil
+}
+
+// Get the recorded sample at the elapsed time since the start of the recording with mutex lock held. Replays from the
+// start at the end of the recording.
+func (source *replaySource) sampleAt(elapsed time.Duration) (bool, replaySample) {
+	if len(source.Samples) == 0 {
+		return false, replaySample{}
+	}
+
+	index := int(math.Floor(math.Max(0, elapsed.Seconds()) * source.Rate))
+
+	return true, source.Samples[index%len(source.Samples)]
+}
+
+// Read the recorded sample at the elapsed time since the start of the recording, e.g. to replay a recording faster
+// than real time.
+func (source *replaySource) ReadXyzAt(elapsed time.Duration) (bool, int16, int16, int16) {
+	source.Mutex.Lock()
+	defer source.Mutex.Unlock()
+
+	success, sample := source.sampleAt(elapsed)
+
+	return success, sample.X, sample.Y, sample.Z
+}
+
+// Read the recorded sample at the current time.
+func (source *replaySource) ReadXyz() (bool, int16, int16, int16) {
+	source.Mutex.Lock()
+	defer source.Mutex.Unlock()
+
+	success, sample := source.sampleAt(time.Since(source.StartTime))
+
+	return success, sample.X, sample.Y, sample.Z
+}
+
+// Read the scale of the current recorded sample and the sample rate of the recording.
+func (source *replaySource) ReadSettings() (bool, uint8, float64) {
+	source.Mutex.Lock()
+	defer source.Mutex.Unlock()
+
+	success, sample := source.sampleAt(time.Since(source.StartTime))
+
+	if !success {
+		return false, 0, 0
+	}
+
+	return true, sample.Scale, source.Rate
+}
+
+// The settings of a recording cannot be changed.
+func (source *replaySource) Configure(scale uint8, dataRate float64) error {
+	if scale != 0 || dataRate != 0 {
+		return errors.New("the replay motion source cannot be configured")
+	}
+
+	return nil
+}
+
+// Motion source that simulates a vehicle at rest on a slowly rocking surface with some engine vibration and noise.
+type simulatedSource struct {
+	StartTime time.Time
+	Scale     uint8
+	DataRate  float64
+}
+
+// The simulated source is always available.
+func (source *simulatedSource) Check() error {
+	return nil
+}
+
+// Simulate gravity with a roll of ±5° over 60 s, a 25 Hz vibration of 20 mg on the Z axis, and 5 mg noise.
+func (source *simulatedSource) ReadXyz() (bool, int16, int16, int16) {
+	elapsed := time.Since(source.StartTime).Seconds()
+	roll := 5 * math.Pi / 180 * math.Sin(2*math.Pi*elapsed/60)
+
+	x := rand.NormFloat64() * 5
+	y := 1000*math.Sin(roll) + rand.NormFloat64()*5
+	z := 1000*math.Cos(roll) + 20*math.Sin(2*math.Pi*25*elapsed) + rand.NormFloat64()*5
+
+	return true, int16(math.Round(x)), int16(math.Round(y)), int16(math.Round(z))
+}
+
+// Get the simulated scale and data rate.
+func (source *simulatedSource) ReadSettings() (bool, uint8, float64) {
+	return true, source.Scale, source.DataRate
+}
+
+// Set the simulated scale and data rate.
+func (source *simulatedSource) Configure(scale uint8, dataRate float64) error {
+	if scale != 0 {
+		source.Scale = scale
+	}
+
+	if dataRate != 0 {
+		source.DataRate = dataRate
+	}
+
+	return nil
+}
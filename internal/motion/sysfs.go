@@ -0,0 +1,168 @@
+package motion
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/metrics"
+)
+
+// Motion source of the built-in TCG4 motion sensor using I2C Sysfs.
+type sysfsSource struct {
+	XyzPath     string
+	ControlPath string
+}
+
+// Check if file exists.
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+
+	if os.IsNotExist(err) {
+		return false
+	}
+
+	return !info.IsDir()
+}
+
+// Check if the I2C Sysfs files exist.
+func (source *sysfsSource) Check() error {
+	if !fileExists(source.XyzPath) || !fileExists(source.ControlPath) {
+		return errors.New("sensor i2c Sysfs file does not exists")
+	}
+
+	return nil
+}
+
+// Read xyz values from I2C Sysfs.
+func (source *sysfsSource) ReadXyz() (bool, int16, int16, int16) {
+	file, openErr := os.Open(source.XyzPath)
+
+	if openErr != nil {
+		sourceErrors.Printf("Failed to open motion sensor: %s\n", openErr)
+		metrics.MotionReadFailures.Inc()
+
+		return false, 0, 0, 0
+	}
+
+	defer file.Close()
+
+	xyz, readErr := io.ReadAll(file)
+
+	if readErr != nil {
+		sourceErrors.Printf("Cannot read motion sensor: %s\n", readErr)
+		metrics.MotionReadFailures.Inc()
+
+		return false, 0, 0, 0
+	}
+
+	xyzString := string(xyz[:])
+	xyzSplit := strings.Split(xyzString, ";")
+
+	for i := range xyzSplit {
+		xyzSplit[i] = strings.TrimSpace(xyzSplit[i])
+	}
+
+	if len(xyzSplit) != 3 {
+		sourceErrors.Printf("Error parsing motion sensor data: %s\n", xyzString)
+		metrics.MotionParseFailures.Inc()
+
+		return false, 0, 0, 0
+	}
+
+	x, xParseErr := strconv.ParseInt(xyzSplit[0], 10, 16)
+	y, yParseErr := strconv.ParseInt(xyzSplit[1], 10, 16)
+	z, zParseErr := strconv.ParseInt(xyzSplit[2], 10, 16)
+
+	if xParseErr != nil || yParseErr != nil || zParseErr != nil {
+		sourceErrors.Printf("Error parsing motion sensor data: %s\n", xyzString)
+		metrics.MotionParseFailures.Inc()
+
+		return false, 0, 0, 0
+	}
+
+	if *global.Verbose {
+		fmt.Printf("[%v] Read motion sensor XYZ: (%d; %d; %d)\n", time.Now().UTC(), x, y, z)
+	}
+
+	return true, int16(x), int16(y), int16(z)
+}
+
+// Read the scale and the output data rate from I2C Sysfs. The data rate is 0 if the control file does not report one.
+func (source *sysfsSource) ReadSettings() (bool, uint8, float64) {
+	file, openErr := os.Open(source.ControlPath)
+
+	if openErr != nil {
+		sourceErrors.Printf("Failed to open motion settings: %s\n", openErr)
+		metrics.MotionReadFailures.Inc()
+
+		return false, 0, 0
+	}
+
+	defer file.Close()
+
+	control, readErr := io.ReadAll(file)
+
+	if readErr != nil {
+		sourceErrors.Printf("Cannot read motion settings: %s\n", readErr)
+		metrics.MotionReadFailures.Inc()
+
+		return false, 0, 0
+	}
+
+	scaleRegex := regexp.MustCompile(`scale=(\d*)g`)
+	scaleMatches := scaleRegex.FindAllStringSubmatch(string(control[:]), -1)
+
+	if len(scaleMatches) != 1 || len(scaleMatches[0]) != 2 {
+		sourceErrors.Printf("Error parsing motion settings: cannot find scale\n")
+		metrics.MotionParseFailures.Inc()
+
+		return false, 0, 0
+	}
+
+	scale, parseErr := strconv.Atoi(scaleMatches[0][1])
+
+	if parseErr != nil {
+		sourceErrors.Printf("Error parsing motion settings: %s\n", scaleMatches[0][1])
+		metrics.MotionParseFailures.Inc()
+
+		return false, 0, 0
+	}
+
+	dataRate := 0.0
+	rateRegex := regexp.MustCompile(`odr=(\d+(?:\.\d+)?)Hz`)
+
+	if rateMatches := rateRegex.FindStringSubmatch(string(control[:])); len(rateMatches) == 2 {
+		dataRate, _ = strconv.ParseFloat(rateMatches[1], 64)
+	}
+
+	if *global.Verbose {
+		fmt.Printf("[%v] Read motion sensor scale: %d, data rate: %f\n", time.Now().UTC(), scale, dataRate)
+	}
+
+	return true, uint8(scale), dataRate
+}
+
+// Write the scale and the output data rate to the I2C Sysfs control file, e.g. scale=4g and odr=100Hz. A zero value
+// keeps the current setting.
+func (source *sysfsSource) Configure(scale uint8, dataRate float64) error {
+	if scale != 0 {
+		if err := os.WriteFile(source.ControlPath, []byte(fmt.Sprintf("scale=%dg\n", scale)), 0644); err != nil {
+			return err
+		}
+	}
+
+	if dataRate != 0 {
+		if err := os.WriteFile(source.ControlPath, []byte("odr="+strconv.FormatFloat(dataRate, 'f', -1, 64)+"Hz\n"), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package motion
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+// Struct to store the vibration analysis of a single axis.
+type AxisVibration struct {
+	Rms               float64
+	PeakToPeak        float64
+	CrestFactor       float64
+	DominantFrequency float64
+	Spectrum          []float64
+}
+
+// Struct to store the vibration analysis of the last window of motion samples in vehicle axes [mg].
+type Vibration struct {
+	Mutex      sync.RWMutex
+	LastUpdate time.Time
+	SampleRate float64
+	Window     int
+	X          AxisVibration
+	Y          AxisVibration
+	Z          AxisVibration
+}
+
+// Store the vibration analysis with mutex lock.
+func (data *Vibration) Store(x, y, z AxisVibration) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.LastUpdate = time.Now()
+	data.X = x
+	data.Y = y
+	data.Z = z
+}
+
+// Get the vibration analysis with mutex lock.
+func (data *Vibration) Get() (time.Time, float64, int, AxisVibration, AxisVibration, AxisVibration) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.LastUpdate, data.SampleRate, data.Window, data.X, data.Y, data.Z
+}
+
+// Compute the discrete Fourier transform in place using the iterative radix-2 Cooley-Tukey algorithm. The length
+// must be a power of 2.
+func fft(values []complex128) {
+	n := len(values)
+
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+
+		j ^= bit
+
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+
+	for length := 2; length <= n; length <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(length)))
+
+		for start := 0; start < n; start += length {
+			w := complex(1, 0)
+
+			for k := 0; k < length/2; k++ {
+				even := values[start+k]
+				odd := values[start+k+length/2] * w
+				values[start+k] = even + odd
+				values[start+k+length/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+// Analyze the vibration of a window of samples of a single axis. The mean (gravity) is removed first. The
+// spectrum is the single-sided amplitude spectrum [mg] of the Hann windowed samples, from 0 Hz up to half the
+// sample rate.
+func analyzeAxis(samples []float64, sampleRate float64) AxisVibration {
+	n := len(samples)
+	mean := 0.0
+
+	for _, sample := range samples {
+		mean += sample
+	}
+
+	mean /= float64(n)
+
+	sumSquares := 0.0
+	peak := 0.0
+	minimum := math.MaxFloat64
+	maximum := -math.MaxFloat64
+	values := make([]complex128, n)
+
+	for i, sample := range samples {
+		value := sample - mean
+		sumSquares += value * value
+		peak = math.Max(peak, math.Abs(value))
+		minimum = math.Min(minimum, sample)
+		maximum = math.Max(maximum, sample)
+
+		hann := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		values[i] = complex(value*hann, 0)
+	}
+
+	fft(values)
+
+	vibration := AxisVibration{
+		Rms:        math.Sqrt(sumSquares / float64(n)),
+		PeakToPeak: maximum - minimum,
+		Spectrum:   make([]float64, n/2+1),
+	}
+
+	if vibration.Rms > 0 {
+		vibration.CrestFactor = peak / vibration.Rms
+	}
+
+	dominant := 0.0
+
+	// The Hann window halves the amplitude, which is corrected by the factor 2. The other factor 2 is for the
+	// single-sided spectrum, except for the DC and Nyquist bins.
+	for i := range vibration.Spectrum {
+		amplitude := 2 * cmplx.Abs(values[i]) / float64(n)
+
+		if i > 0 && i < n/2 {
+			amplitude *= 2
+		}
+
+		vibration.Spectrum[i] = amplitude
+
+		if i > 0 && amplitude > dominant {
+			dominant = amplitude
+			vibration.DominantFrequency = float64(i) * sampleRate / float64(n)
+		}
+	}
+
+	return vibration
+}
+
+// Start analyzing vibration by sampling the motion sensor at the vibration sample rate.
+func (data *Vibration) Start(motionData *Motion, done chan struct{}) error {
+	fmt.Printf("Starting vibration analysis... ")
+
+	window := int(*global.VibrationWindow)
+
+	if window < 16 || window&(window-1) != 0 || *global.VibrationSampleRate <= 0 {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Invalid vibration window %d or sample rate %f\n", window, *global.VibrationSampleRate)
+
+		close(done)
+
+		return errors.New("vibration window must be a power of 2 of at least 16 and the sample rate must be positive")
+	}
+
+	data.Mutex.Lock()
+	data.SampleRate = *global.VibrationSampleRate
+	data.Window = window
+	data.Mutex.Unlock()
+
+	motionSamples := motionData.Subscribe(*global.VibrationSampleRate)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		samples := [3][]float64{}
+
+		for {
+			select {
+			case <-done:
+				global.Wg.Done()
+				return
+			case sample := <-motionSamples:
+				if !sample.Readable {
+					continue
+				}
+
+				samples[0] = append(samples[0], sample.X)
+				samples[1] = append(samples[1], sample.Y)
+				samples[2] = append(samples[2], sample.Z)
+
+				if len(samples[0]) < window {
+					continue
+				}
+
+				sampleRate := *global.VibrationSampleRate
+				data.Store(analyzeAxis(samples[0], sampleRate), analyzeAxis(samples[1], sampleRate), analyzeAxis(samples[2], sampleRate))
+				samples = [3][]float64{}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package movement
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Movement states.
+const (
+	StateStationary = "stationary"
+	StateMoving     = "moving"
+)
+
+// Struct to store the movement state, the time of the last state change, and the variance [mg²] and GPS speed
+// [m/s] of the last window of motion samples.
+type Movement struct {
+	Mutex      sync.RWMutex
+	LastUpdate time.Time
+	State      string
+	Since      time.Time
+	Variance   float64
+	Speed      float64
+}
+
+// Store the movement state with mutex lock.
+func (data *Movement) Store(state string, since time.Time, variance, speed float64) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	data.LastUpdate = time.Now()
+	data.State = state
+	data.Since = since
+	data.Variance = variance
+	data.Speed = speed
+}
+
+// Get the movement state with mutex lock.
+func (data *Movement) Get() (time.Time, string, time.Time, float64, float64) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.LastUpdate, data.State, data.Since, data.Variance, data.Speed
+}
+
+// Compute the total variance [mg²] of the motion samples, which is the sum of the variance per axis.
+func variance(samples [][3]float64) float64 {
+	mean := [3]float64{}
+
+	for _, sample := range samples {
+		for axis := range sample {
+			mean[axis] += sample[axis] / float64(len(samples))
+		}
+	}
+
+	total := 0.0
+
+	for _, sample := range samples {
+		for axis := range sample {
+			total += (sample[axis] - mean[axis]) * (sample[axis] - mean[axis]) / float64(len(samples))
+		}
+	}
+
+	return total
+}
+
+// Start detecting movement by sampling the motion sensor at the movement sample rate. Movement is detected if the
+// variance of a window of motion samples or the GPS speed with a fix exceeds its threshold. The state only changes
+// after the opposite state is detected for the hysteresis duration.
+func (data *Movement) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan struct{}) error {
+	fmt.Printf("Starting movement detector... ")
+
+	if *global.MovementSampleRate <= 0 || *global.MovementWindow <= 0 {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Invalid movement sample rate %f or window %f\n", *global.MovementSampleRate, *global.MovementWindow)
+
+		close(done)
+
+		return errors.New("movement sample rate and window must be positive")
+	}
+
+	window := int(*global.MovementSampleRate * *global.MovementWindow)
+
+	if window < 2 {
+		window = 2
+	}
+
+	hysteresis := time.Duration(*global.MovementHysteresis * float64(time.Second))
+	motionSamples := motionData.Subscribe(*global.MovementSampleRate)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		samples := [][3]float64{}
+		state := StateStationary
+		since := time.Now()
+		var pending time.Time
+
+		for {
+			select {
+			case <-done:
+				global.Wg.Done()
+				return
+			case sample := <-motionSamples:
+				if !sample.Readable {
+					continue
+				}
+
+				now := sample.Time
+				samples = append(samples, [3]float64{sample.X, sample.Y, sample.Z})
+
+				if len(samples) < window {
+					continue
+				}
+
+				windowVariance := variance(samples)
+				samples = [][3]float64{}
+
+				_, _, _, _, speed, mode, _, _, _, _, _, _, _, _, _, _ := gpsData.GetTpv()
+				detected := StateStationary
+
+				if windowVariance >= *global.MovementVariance || (mode >= 2 && speed >= *global.MovementSpeed) {
+					detected = StateMoving
+				}
+
+				if detected == state {
+					pending = time.Time{}
+				} else if pending.IsZero() {
+					pending = now
+				}
+
+				if !pending.IsZero() && now.Sub(pending) >= hysteresis {
+					state = detected
+					since = pending
+					pending = time.Time{}
+
+					if *global.Verbose {
+						fmt.Printf("[%v] Movement state changed to %s\n", time.Now().UTC(), state)
+					}
+				}
+
+				if *global.MovementFreeze && state == StateStationary {
+					gpsData.Freeze()
+				} else {
+					gpsData.Unfreeze()
+				}
+
+				data.Store(state, since, windowVariance, speed)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package overspeed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/geofence"
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+)
+
+// Event types.
+const (
+	TypeStart = "start"
+	TypeEnd   = "end"
+)
+
+const (
+	pollInterval  = 100 * time.Millisecond // Interval between checks for a new GPS fix.
+	minFixTimeout = 2 * time.Second        // Minimum duration without a GPS fix before an overspeed ends.
+)
+
+// Struct to store an overspeed start or end with the maximum speed [m/s] and the speed limit [m/s] at the start of
+// the overspeed. The duration [s] is only set for the end.
+type Event struct {
+	Id       uint64
+	Type     string
+	Time     time.Time
+	MaxSpeed float64
+	Limit    float64
+	Zone     string
+	Duration float64
+	Lat      float64
+	Lon      float64
+}
+
+// Struct to store the current speed [m/s] and speed limit [m/s], the zone that sets the speed limit, the current
+// overspeed, and the number and total duration [s] of all overspeeds.
+type Status struct {
+	Active        bool
+	Speed         float64
+	Limit         float64
+	Zone          string
+	Start         time.Time
+	MaxSpeed      float64
+	Count         uint64
+	TotalDuration float64
+}
+
+// Struct to store the overspeed status and events.
+type Detector struct {
+	Mutex      sync.RWMutex
+	LastUpdate time.Time
+	Status     Status
+	Events     []Event
+	nextId     uint64
+	startLimit float64
+	startZone  string
+	above      time.Time
+	below      time.Time
+}
+
+// Get the overspeed status with mutex lock.
+func (data *Detector) Get() (time.Time, Status) {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	return data.LastUpdate, data.Status
+}
+
+// Get all events with an ID above the given ID with mutex lock.
+func (data *Detector) GetSince(id uint64) []Event {
+	data.Mutex.RLock()
+	defer data.Mutex.RUnlock()
+
+	events := []Event{}
+
+	for _, event := range data.Events {
+		if event.Id > id {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// Get the lowest speed limit [m/s] of the global speed limit and the speedLimit property of the geofences the
+// vehicle is inside, and the name of the geofence that sets it. The speed limit is 0 if there is none.
+func speedLimit(geofences *geofence.Geofences) (float64, string) {
+	limit := *global.SpeedLimit
+	zoneName := ""
+
+	if geofences == nil {
+		return limit, zoneName
+	}
+
+	_, zones, inside := geofences.GetZones()
+
+	for index, zone := range zones {
+		zoneLimit, ok := zone.Properties["speedLimit"].(float64)
+
+		if inside[index] && ok && zoneLimit > 0 && (limit <= 0 || zoneLimit < limit) {
+			limit = zoneLimit
+			zoneName = zone.Name
+		}
+	}
+
+	return limit, zoneName
+}
+
+// Store an event with mutex lock held. Only the configured number of events is kept.
+func (data *Detector) storeEvent(event Event) {
+	data.nextId++
+	event.Id = data.nextId
+	data.Events = append(data.Events, event)
+
+	if uint64(len(data.Events)) > *global.OverspeedHistory {
+		data.Events = data.Events[uint64(len(data.Events))-*global.OverspeedHistory:]
+	}
+
+	if *global.Verbose {
+		fmt.Printf("[%v] Overspeed %s: %.1f m/s above %.1f m/s\n", time.Now().UTC(), event.Type, event.MaxSpeed, event.Limit)
+	}
+}
+
+// Update the overspeed status with a GPS fix with mutex lock. Overspeed starts once the speed is above the limit
+// for the debounce duration, and ends once the speed is below the limit for the debounce duration.
+func (data *Detector) update(now time.Time, speed, lat, lon, limit float64, zone string) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	debounce := time.Duration(*global.OverspeedDebounce * float64(time.Second))
+	status := &data.Status
+
+	if limit > 0 && speed > limit {
+		data.below = time.Time{}
+
+		if data.above.IsZero() {
+			data.above = now
+		}
+	} else {
+		data.above = time.Time{}
+
+		if data.below.IsZero() {
+			data.below = now
+		}
+	}
+
+	data.LastUpdate = time.Now()
+	status.Speed = speed
+	status.Limit = limit
+	status.Zone = zone
+
+	if !status.Active && !data.above.IsZero() && now.Sub(data.above) >= debounce {
+		status.Active = true
+		status.Start = data.above.UTC()
+		status.MaxSpeed = speed
+		status.Count++
+		data.startLimit = limit
+		data.startZone = zone
+
+		data.storeEvent(Event{Type: TypeStart, Time: status.Start, MaxSpeed: speed, Limit: limit, Zone: zone, Lat: lat, Lon: lon})
+	}
+
+	if !status.Active {
+		return
+	}
+
+	status.MaxSpeed = math.Max(status.MaxSpeed, speed)
+
+	if !data.below.IsZero() && now.Sub(data.below) >= debounce {
+		duration := data.below.Sub(status.Start).Seconds()
+		status.Active = false
+		status.TotalDuration += duration
+
+		data.storeEvent(Event{Type: TypeEnd, Time: data.below.UTC(), MaxSpeed: status.MaxSpeed, Limit: data.startLimit, Zone: data.startZone, Duration: duration, Lat: lat, Lon: lon})
+	}
+}
+
+// End the overspeed with mutex lock when there is no GPS fix for the debounce duration, so the status does not stay
+// active on stale data. The overspeed ends at the last fix.
+func (data *Detector) expire(now, lastFix time.Time, lat, lon float64) {
+	data.Mutex.Lock()
+	defer data.Mutex.Unlock()
+
+	timeout := max(time.Duration(*global.OverspeedDebounce*float64(time.Second)), minFixTimeout)
+	status := &data.Status
+
+	if lastFix.IsZero() || now.Sub(lastFix) < timeout {
+		return
+	}
+
+	data.above = time.Time{}
+	data.below = time.Time{}
+
+	if !status.Active {
+		return
+	}
+
+	duration := math.Max(0, lastFix.Sub(status.Start).Seconds())
+	data.LastUpdate = time.Now()
+	status.Active = false
+	status.TotalDuration += duration
+
+	data.storeEvent(Event{Type: TypeEnd, Time: lastFix.UTC(), MaxSpeed: status.MaxSpeed, Limit: data.startLimit, Zone: data.startZone, Duration: duration, Lat: lat, Lon: lon})
+}
+
+// Start detecting overspeed for each new GPS fix. The geofences are optional.
+func (data *Detector) Start(gpsData *gps.Gps, geofences *geofence.Geofences, done chan struct{}) error {
+	fmt.Printf("Starting overspeed detector... ")
+
+	if *global.SpeedLimit <= 0 && geofences == nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Overspeed detection requires a speed limit or geofences\n")
+
+		close(done)
+
+		return errors.New("overspeed detection requires a speed limit or geofences")
+	}
+
+	data.Mutex.Lock()
+	data.LastUpdate = time.Now()
+	data.Mutex.Unlock()
+
+	ticker := time.NewTicker(pollInterval)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		lastFix := time.Time{}
+		lastLat, lastLon := 0.0, 0.0
+
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				global.Wg.Done()
+				return
+			case now := <-ticker.C:
+				lastUpdate, lat, lon, _, speed, mode, _, _, _, _, _, _, _, _, _, _ := gpsData.GetTpv()
+
+				if mode >= 2 && lastUpdate.After(lastFix) {
+					lastFix = lastUpdate
+					lastLat, lastLon = lat, lon
+					limit, zone := speedLimit(geofences)
+					data.update(lastUpdate, speed, lat, lon, limit, zone)
+				} else {
+					data.expire(now, lastFix, lastLat, lastLon)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package reckoning
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// GPSd status of a dead reckoning fix.
+const StatusDeadReckoning = 5
+
+// Mean radius [m] of the earth.
+const earthRadius = 6371008.8
+
+// Standard gravity [m/s²], used to convert motion data to m/s².
+const standardGravity = 9.80665
+
+// Time constant of the low-pass filter that estimates the longitudinal bias of the motion sensor while there is a
+// fix. The bias includes gravity when driving uphill or downhill.
+const biasTimeConstant = 5 * time.Second
+
+// Struct to store the dead reckoning state between updates.
+type estimator struct {
+	Fix      gps.TpvReport
+	Bias     float64
+	Active   bool
+	Expired  bool
+	Lat      float64
+	Lon      float64
+	Speed    float64
+	LastTime time.Time
+}
+
+// Get the destination of a great circle path from a position with a bearing [°] and distance [m].
+func destination(lat, lon, bearing, distance float64) (float64, float64) {
+	phi := lat * math.Pi / 180
+	lambda := lon * math.Pi / 180
+	theta := bearing * math.Pi / 180
+	delta := distance / earthRadius
+
+	phi2 := math.Asin(math.Sin(phi)*math.Cos(delta) + math.Cos(phi)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi), math.Cos(delta)-math.Sin(phi)*math.Sin(phi2))
+
+	return phi2 * 180 / math.Pi, math.Mod(lambda2*180/math.Pi+540, 360) - 180
+}
+
+// Move the estimate to the given time with the course of the last fix and the longitudinal acceleration [mg] of the
+// motion sensor, if readable.
+func (data *estimator) propagate(now time.Time, x float64, readable bool) {
+	dt := now.Sub(data.LastTime).Seconds()
+
+	if dt <= 0 {
+		return
+	}
+
+	data.LastTime = now
+
+	if readable {
+		data.Speed = math.Max(0, data.Speed+(x-data.Bias)/1000*standardGravity*dt)
+	}
+
+	data.Lat, data.Lon = destination(data.Lat, data.Lon, data.Fix.Track, data.Speed*dt)
+}
+
+// Update the estimator with the last TPV report from GPSd and the longitudinal acceleration [mg] of the motion
+// sensor, if readable. Returns the estimated TPV report, or nil if there is a fix or no estimate is possible. The
+// estimate is also moved while the last fix is not too old, so it does not miss the acceleration until dead
+// reckoning starts.
+func (data *estimator) update(now time.Time, fix gps.TpvReport, x float64, readable bool) *gps.TpvReport {
+	timeout := time.Duration(*global.DeadReckoningTimeout * float64(time.Second))
+
+	if fix.Mode >= 2 && now.Sub(fix.LastUpdate) <= timeout {
+		if readable {
+			if data.Fix.LastUpdate.IsZero() {
+				data.Bias = x
+			} else if dt := now.Sub(data.LastTime); dt > 0 {
+				data.Bias += float64(dt) / float64(biasTimeConstant+dt) * (x - data.Bias)
+			}
+		}
+
+		if !fix.LastUpdate.Equal(data.Fix.LastUpdate) {
+			data.Fix = fix
+			data.Lat = fix.Lat
+			data.Lon = fix.Lon
+			data.Speed = fix.Speed
+			data.LastTime = fix.LastUpdate
+		}
+
+		data.propagate(now, x, readable)
+		data.Active = false
+		data.Expired = false
+
+		return nil
+	}
+
+	if data.Fix.LastUpdate.IsZero() || data.Expired {
+		return nil
+	}
+
+	elapsed := now.Sub(data.Fix.LastUpdate)
+
+	if elapsed.Seconds() > *global.DeadReckoningMaxTime {
+		data.Active = false
+		data.Expired = true
+
+		return nil
+	}
+
+	data.Active = true
+	data.propagate(now, x, readable)
+
+	// The error grows linearly with the speed error and quadratically with the acceleration error.
+	t := elapsed.Seconds()
+	eph := data.Fix.Eph + data.Fix.Eps*t + 0.5**global.DeadReckoningDrift*t*t
+
+	return &gps.TpvReport{
+		LastUpdate: now,
+		Lat:        data.Lat,
+		Lon:        data.Lon,
+		Alt:        data.Fix.Alt,
+		Speed:      data.Speed,
+		Track:      data.Fix.Track,
+		Mode:       2,
+		Status:     StatusDeadReckoning,
+		Epc:        data.Fix.Epc,
+		Epd:        data.Fix.Epd,
+		Eph:        eph,
+		Eps:        data.Fix.Eps + *global.DeadReckoningDrift*t,
+		Ept:        data.Fix.Ept,
+		Epx:        eph / math.Sqrt2,
+		Epy:        eph / math.Sqrt2,
+		Epv:        data.Fix.Epv,
+		Sep:        math.Sqrt(eph*eph + data.Fix.Epv*data.Fix.Epv),
+	}
+}
+
+// Start estimating the position when the GPS fix is lost. The estimate is reported by the GPS data until the fix
+// returns.
+func Start(gpsData *gps.Gps, motionData *motion.Motion, done chan struct{}) error {
+	fmt.Printf("Starting dead reckoning... ")
+
+	if *global.DeadReckoningRate <= 0 {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Invalid dead reckoning rate: %f\n", *global.DeadReckoningRate)
+
+		close(done)
+
+		return errors.New("invalid dead reckoning rate")
+	}
+
+	motionSamples := motionData.Subscribe(*global.DeadReckoningRate)
+
+	fmt.Printf("OK\n")
+
+	global.Wg.Add(1)
+
+	go func() {
+		data := estimator{}
+
+		for {
+			select {
+			case <-done:
+				global.Wg.Done()
+				return
+			case sample := <-motionSamples:
+				active := data.Active
+				estimate := data.update(sample.Time, gpsData.GetFix(), sample.X, sample.Readable)
+				gpsData.StoreEstimate(estimate)
+
+				if *global.Verbose && active != data.Active {
+					if data.Active {
+						fmt.Printf("[%v] GPS fix lost, dead reckoning started\n", time.Now().UTC())
+					} else {
+						fmt.Printf("[%v] Dead reckoning stopped\n", time.Now().UTC())
+					}
+				}
+			}
+		}
+	}()
+
+	return nil
+}
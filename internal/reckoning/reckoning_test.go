@@ -0,0 +1,258 @@
+package reckoning
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Update rate [Hz] of the replay, which is also the sample rate of the recorded motion data.
+const replayRate = 10
+
+// Get the great circle distance [m] between two positions, the inverse of destination.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := phi2 - phi1
+	dLambda := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// Load GPS fixes of time [s];lat;lon;alt;speed;track;mode;eph;eps lines relative to the start time.
+func loadFixes(t *testing.T, path string, start time.Time) []gps.TpvReport {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixes := []gps.TpvReport{}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		values := []float64{}
+
+		for _, field := range strings.Split(line, ";") {
+			value, err := strconv.ParseFloat(field, 64)
+
+			if err != nil {
+				t.Fatalf("cannot parse %q of %s", line, path)
+			}
+
+			values = append(values, value)
+		}
+
+		lastUpdate := start.Add(time.Duration(values[0] * float64(time.Second)))
+		fixes = append(fixes, gps.TpvReport{
+			LastUpdate: lastUpdate,
+			Lat:        values[1],
+			Lon:        values[2],
+			Alt:        values[3],
+			Speed:      values[4],
+			Track:      values[5],
+			Mode:       uint8(values[6]),
+			Eph:        values[7],
+			Eps:        values[8],
+		})
+	}
+
+	return fixes
+}
+
+// Open the recorded motion data with the replay motion source.
+func openReplay(t *testing.T, path string) interface {
+	ReadXyzAt(time.Duration) (bool, int16, int16, int16)
+} {
+	*global.MotionReplayFile = path
+	*global.MotionReplayRate = replayRate
+
+	source, err := motion.NewSource("replay")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := source.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, ok := source.(interface {
+		ReadXyzAt(time.Duration) (bool, int16, int16, int16)
+	})
+
+	if !ok {
+		t.Fatal("the replay motion source cannot be read at a time")
+	}
+
+	return replay
+}
+
+func TestEstimatorReplay(t *testing.T) {
+	// State at a time [s] of the recording. The expected speed [m/s] and distance [m] from the last fix are only
+	// checked while estimating.
+	type check struct {
+		at         float64
+		estimating bool
+		speed      float64
+		distance   float64
+	}
+
+	tests := []struct {
+		name        string
+		timeout     float64
+		maxTime     float64
+		stopReports bool
+		checks      []check
+	}{
+		{
+			name:    "hand-off and hand-back",
+			timeout: 2,
+			maxTime: 60,
+			checks: []check{
+				{at: 10.5},
+				{at: 11, estimating: true, speed: 10, distance: 10},
+				{at: 15, estimating: true, speed: 8.04, distance: 48},
+				{at: 20, estimating: true, speed: 5.1, distance: 77.9},
+				{at: 24.9, estimating: true, speed: 5.1, distance: 102.9},
+				{at: 25},
+				{at: 40},
+			},
+		},
+		{
+			name:    "maximum duration expires",
+			timeout: 2,
+			maxTime: 8,
+			checks: []check{
+				{at: 11, estimating: true, speed: 10, distance: 10},
+				{at: 17.9, estimating: true, speed: 5.2, distance: 67.2},
+				{at: 18.1},
+				{at: 24.9},
+				{at: 25},
+				{at: 40},
+			},
+		},
+		{
+			name:        "reports stop",
+			timeout:     2,
+			maxTime:     60,
+			stopReports: true,
+			checks: []check{
+				{at: 11.5},
+				{at: 12},
+				{at: 12.1, estimating: true, speed: 10, distance: 21},
+				{at: 24.9, estimating: true, speed: 5.1, distance: 102.9},
+				{at: 25},
+			},
+		},
+		{
+			name:        "reports stop while braking",
+			timeout:     4,
+			maxTime:     60,
+			stopReports: true,
+			checks: []check{
+				{at: 14},
+				{at: 14.1, estimating: true, speed: 8.92, distance: 40.5},
+			},
+		},
+	}
+
+	*global.DeadReckoningDrift = 0.05
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			*global.DeadReckoningTimeout = test.timeout
+			*global.DeadReckoningMaxTime = test.maxTime
+
+			start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			replay := openReplay(t, "testdata/drive.motion")
+			checks := map[int]check{}
+
+			for _, check := range test.checks {
+				checks[int(math.Round(check.at*replayRate))] = check
+			}
+
+			fixes := []gps.TpvReport{}
+
+			for _, fix := range loadFixes(t, "testdata/drive.fix", start) {
+				if !test.stopReports || fix.Mode >= 2 {
+					fixes = append(fixes, fix)
+				}
+			}
+
+			data := estimator{}
+			current := gps.TpvReport{}
+			lastGood := gps.TpvReport{}
+			var previous *gps.TpvReport
+
+			for tick := 0; tick <= 40*replayRate; tick++ {
+				elapsed := time.Duration(tick) * time.Second / replayRate
+				now := start.Add(elapsed)
+
+				for len(fixes) > 0 && !fixes[0].LastUpdate.After(now) {
+					current = fixes[0]
+					fixes = fixes[1:]
+
+					if current.Mode >= 2 {
+						lastGood = current
+					}
+				}
+
+				readable, x, _, _ := replay.ReadXyzAt(elapsed)
+				estimate := data.update(now, current, float64(x), readable)
+
+				if (estimate != nil) != data.Active {
+					t.Fatalf("%v: estimate %v while active is %v", elapsed, estimate != nil, data.Active)
+				}
+
+				if estimate != nil {
+					if estimate.Status != StatusDeadReckoning || estimate.Mode != 2 {
+						t.Fatalf("%v: estimate has status %d and mode %d", elapsed, estimate.Status, estimate.Mode)
+					}
+
+					if estimate.Eph <= lastGood.Eph || estimate.Eps <= lastGood.Eps {
+						t.Fatalf("%v: estimated errors %.2f m, %.2f m/s are not above the fix errors", elapsed, estimate.Eph, estimate.Eps)
+					}
+
+					if previous != nil && (estimate.Eph <= previous.Eph || estimate.Eps <= previous.Eps) {
+						t.Fatalf("%v: estimated errors %.2f m, %.2f m/s do not grow from %.2f m, %.2f m/s", elapsed, estimate.Eph, estimate.Eps, previous.Eph, previous.Eps)
+					}
+				}
+
+				previous = estimate
+				check, ok := checks[tick]
+
+				if !ok {
+					continue
+				}
+
+				if (estimate != nil) != check.estimating {
+					t.Fatalf("%v: estimating is %v, want %v", elapsed, estimate != nil, check.estimating)
+				}
+
+				if estimate == nil {
+					continue
+				}
+
+				if math.Abs(estimate.Speed-check.speed) > 0.2 {
+					t.Errorf("%v: speed is %.2f m/s, want %.2f m/s", elapsed, estimate.Speed, check.speed)
+				}
+
+				if distance := distance(lastGood.Lat, lastGood.Lon, estimate.Lat, estimate.Lon); math.Abs(distance-check.distance) > 2 {
+					t.Errorf("%v: distance from the last fix is %.1f m, want %.1f m", elapsed, distance, check.distance)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/leverarm"
+)
+
+// Struct to store the GPS antenna position before the lever arm correction, and the lever arm rotated to east, north,
+// and up with the attitude used to rotate it.
+type AntennaJson struct {
+	Lat     float64 `json:"lat" proto:"1"`
+	Lon     float64 `json:"lon" proto:"2"`
+	Alt     float64 `json:"alt" proto:"3"`
+	Applied bool    `json:"applied" proto:"4"`
+	Heading float64 `json:"heading" proto:"5"`
+	Pitch   float64 `json:"pitch" proto:"6"`
+	Roll    float64 `json:"roll" proto:"7"`
+	East    float64 `json:"east" proto:"8"`
+	North   float64 `json:"north" proto:"9"`
+	Up      float64 `json:"up" proto:"10"`
+}
+
+// Handle sensors/gps/antenna request.
+func handleSensorsGpsAntennaRequest(w http.ResponseWriter, r *http.Request, data *gps.Gps, leverArm *leverarm.LeverArm) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time { return data.GetFix().LastUpdate })
+
+	fix := data.GetFix()
+	format, ok := negotiateFormat(w, r, AntennaJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, fix.LastUpdate, gpsPeriod()) {
+		return
+	}
+
+	arm, applied := leverArm.Get()
+	jsonData := AntennaJson{
+		Lat:     fix.Lat,
+		Lon:     fix.Lon,
+		Alt:     fix.Alt,
+		Applied: applied,
+	}
+
+	if applied {
+		jsonData.Heading, jsonData.Pitch, jsonData.Roll = arm.Heading, arm.Pitch, arm.Roll
+		jsonData.East, jsonData.North, jsonData.Up = arm.Enu()
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
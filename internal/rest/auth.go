@@ -0,0 +1,174 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/metrics"
+)
+
+// Scopes that can be granted to an API key.
+const (
+	ScopeGpsRead     = "gps:read"
+	ScopeMotionRead  = "motion:read"
+	ScopeMetricsRead = "metrics:read"
+	ScopeAdmin       = "admin"
+)
+
+// Struct to store the API key file.
+type ApiKeyFile struct {
+	Keys []ApiKey `json:"keys"`
+}
+
+// Struct to store a named API key with its scopes and optional expiry.
+type ApiKey struct {
+	Name    string    `json:"name"`
+	Key     string    `json:"key"`
+	Scopes  []string  `json:"scopes"`
+	Expires time.Time `json:"expires"`
+	hash    [sha256.Size]byte
+}
+
+var apiKeys []ApiKey
+
+// Load the API keys from the key file and the -rest-api-key flag.
+func loadApiKeys() error {
+	keys := []ApiKey{}
+
+	if *global.RestApiKey != "" {
+		keys = append(keys, ApiKey{
+			Name:   "default",
+			Key:    *global.RestApiKey,
+			Scopes: []string{ScopeAdmin},
+		})
+	}
+
+	if *global.RestApiKeyFile != "" {
+		content, err := os.ReadFile(*global.RestApiKeyFile)
+
+		if err != nil {
+			return err
+		}
+
+		keyFile := ApiKeyFile{}
+
+		if err := json.Unmarshal(content, &keyFile); err != nil {
+			return fmt.Errorf("cannot parse %s: %s", *global.RestApiKeyFile, err)
+		}
+
+		for _, key := range keyFile.Keys {
+			if key.Name == "" || key.Key == "" {
+				return fmt.Errorf("cannot parse %s: each key requires a name and a key", *global.RestApiKeyFile)
+			}
+
+			keys = append(keys, key)
+		}
+
+		if len(keys) == 0 {
+			return errors.New("no API keys found in " + *global.RestApiKeyFile)
+		}
+	}
+
+	for i := range keys {
+		keys[i].hash = sha256.Sum256([]byte(keys[i].Key))
+	}
+
+	apiKeys = keys
+
+	return nil
+}
+
+// Check if the API key grants a scope.
+func (key *ApiKey) HasScope(scope string) bool {
+	for _, keyScope := range key.Scopes {
+		if keyScope == scope || keyScope == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get the API key from the X-API-Key or Authorization: Bearer header.
+func getRequestApiKey(r *http.Request) string {
+	if apiKeyHeader := r.Header.Get("X-API-Key"); apiKeyHeader != "" {
+		return apiKeyHeader
+	}
+
+	authorization := r.Header.Get("Authorization")
+
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+
+	return ""
+}
+
+// Find the API key matching the request. All keys are compared in constant time.
+func findApiKey(r *http.Request) *ApiKey {
+	requestHash := sha256.Sum256([]byte(getRequestApiKey(r)))
+	var match *ApiKey
+
+	for i := range apiKeys {
+		if subtle.ConstantTimeCompare(requestHash[:], apiKeys[i].hash[:]) == 1 {
+			match = &apiKeys[i]
+		}
+	}
+
+	return match
+}
+
+// Authorize a request for all required scopes. Writes the error response if not authorized.
+func authorizeRequest(w http.ResponseWriter, r *http.Request, scopes []string) bool {
+	if len(apiKeys) == 0 {
+		return true
+	}
+
+	key := findApiKey(r)
+
+	if key == nil || (!key.Expires.IsZero() && time.Now().After(key.Expires)) {
+		lockout.Fail(getClientIp(r))
+		fmt.Printf("[%v] Unauthorized HTTP request: %s \"%s %s\" \"%s\"\n", time.Now().UTC(), r.RemoteAddr, r.Method, r.URL.Path, r.UserAgent())
+		writeError(w, http.StatusUnauthorized, "Missing, wrong, or expired X-API-Key or Authorization header")
+
+		return false
+	}
+
+	lockout.Reset(getClientIp(r))
+	metrics.HttpRequestsByKey.Inc(key.Name)
+
+	for _, scope := range scopes {
+		if !key.HasScope(scope) {
+			fmt.Printf("[%v] Forbidden HTTP request by key \"%s\": %s \"%s %s\" \"%s\"\n", time.Now().UTC(), key.Name, r.RemoteAddr, r.Method, r.URL.Path, r.UserAgent())
+			writeError(w, http.StatusForbidden, "API key lacks the "+scope+" scope")
+
+			return false
+		}
+	}
+
+	if *global.Verbose {
+		fmt.Printf("[%v] HTTP request authorized by key \"%s\": %s \"%s %s\"\n", time.Now().UTC(), key.Name, r.RemoteAddr, r.Method, r.URL.Path)
+	}
+
+	return true
+}
+
+// Authorize a request that changes the configuration or the calibration. Requires the admin scope, and is never
+// allowed without configured API keys.
+func authorizeChange(w http.ResponseWriter, r *http.Request) bool {
+	if len(apiKeys) == 0 {
+		writeError(w, http.StatusForbidden, "Changes require an API key with the admin scope")
+
+		return false
+	}
+
+	return authorizeRequest(w, r, []string{ScopeAdmin})
+}
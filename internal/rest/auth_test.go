@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+// Load the API keys of the -rest-api-key flag and a key file with the content, if not empty. The keys are removed
+// when the test ends.
+func setApiKeys(t *testing.T, apiKey string, keyFile string) error {
+	restApiKey := *global.RestApiKey
+	restApiKeyFile := *global.RestApiKeyFile
+
+	t.Cleanup(func() {
+		*global.RestApiKey = restApiKey
+		*global.RestApiKeyFile = restApiKeyFile
+		apiKeys = nil
+	})
+
+	*global.RestApiKey = apiKey
+	*global.RestApiKeyFile = ""
+
+	if keyFile != "" {
+		*global.RestApiKeyFile = filepath.Join(t.TempDir(), "keys.json")
+
+		if err := os.WriteFile(*global.RestApiKeyFile, []byte(keyFile), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return loadApiKeys()
+}
+
+func TestLoadApiKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		apiKey  string
+		keyFile string
+		keys    []string
+		err     string
+	}{
+		{"no keys", "", "", []string{}, ""},
+		{"flag", "secret", "", []string{"default"}, ""},
+		{"key file", "", `{"keys": [{"name": "reader", "key": "r", "scopes": ["gps:read"]}]}`, []string{"reader"}, ""},
+		{"flag and key file", "secret", `{"keys": [{"name": "reader", "key": "r"}]}`, []string{"default", "reader"}, ""},
+		{"invalid key file", "", `{"keys": `, nil, "cannot parse"},
+		{"key without name", "", `{"keys": [{"key": "r"}]}`, nil, "each key requires a name and a key"},
+		{"key without key", "", `{"keys": [{"name": "reader"}]}`, nil, "each key requires a name and a key"},
+		{"empty key file", "", `{"keys": []}`, nil, "no API keys found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := setApiKeys(t, test.apiKey, test.keyFile)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("loadApiKeys() error = %v, want %q", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("loadApiKeys() returned error %s", err)
+			}
+
+			names := []string{}
+
+			for _, key := range apiKeys {
+				names = append(names, key.Name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(test.keys, ",") {
+				t.Errorf("loadApiKeys() keys = %v, want %v", names, test.keys)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{"granted scope", []string{ScopeGpsRead}, ScopeGpsRead, true},
+		{"other scope", []string{ScopeGpsRead}, ScopeMotionRead, false},
+		{"admin grants all scopes", []string{ScopeAdmin}, ScopeMetricsRead, true},
+		{"no scopes", nil, ScopeGpsRead, false},
+		{"admin scope", []string{ScopeGpsRead, ScopeMotionRead}, ScopeAdmin, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := ApiKey{Scopes: test.scopes}
+
+			if got := key.HasScope(test.scope); got != test.want {
+				t.Errorf("HasScope(%s) with scopes %v = %v, want %v", test.scope, test.scopes, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRequest(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	valid := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	keyFile := `{"keys": [
+		{"name": "reader", "key": "r", "scopes": ["gps:read"]},
+		{"name": "expired", "key": "e", "scopes": ["gps:read"], "expires": "` + expired + `"},
+		{"name": "valid", "key": "v", "scopes": ["gps:read"], "expires": "` + valid + `"},
+		{"name": "admin", "key": "a", "scopes": ["admin"]}
+	]}`
+
+	tests := []struct {
+		name    string
+		keyFile string
+		headers map[string]string
+		scopes  []string
+		want    int
+	}{
+		{"no keys configured", "", nil, []string{ScopeGpsRead}, http.StatusOK},
+		{"missing key", keyFile, nil, []string{ScopeGpsRead}, http.StatusUnauthorized},
+		{"wrong key", keyFile, map[string]string{"X-API-Key": "wrong"}, []string{ScopeGpsRead}, http.StatusUnauthorized},
+		{"X-API-Key header", keyFile, map[string]string{"X-API-Key": "r"}, []string{ScopeGpsRead}, http.StatusOK},
+		{"bearer token", keyFile, map[string]string{"Authorization": "Bearer r"}, []string{ScopeGpsRead}, http.StatusOK},
+		{"lowercase bearer token", keyFile, map[string]string{"Authorization": "bearer r"}, []string{ScopeGpsRead}, http.StatusOK},
+		{"basic authorization", keyFile, map[string]string{"Authorization": "Basic r"}, []string{ScopeGpsRead}, http.StatusUnauthorized},
+		{"X-API-Key header takes precedence", keyFile, map[string]string{"X-API-Key": "wrong", "Authorization": "Bearer r"}, []string{ScopeGpsRead}, http.StatusUnauthorized},
+		{"expired key", keyFile, map[string]string{"X-API-Key": "e"}, []string{ScopeGpsRead}, http.StatusUnauthorized},
+		{"not yet expired key", keyFile, map[string]string{"X-API-Key": "v"}, []string{ScopeGpsRead}, http.StatusOK},
+		{"missing scope", keyFile, map[string]string{"X-API-Key": "r"}, []string{ScopeMotionRead}, http.StatusForbidden},
+		{"one of multiple scopes missing", keyFile, map[string]string{"X-API-Key": "r"}, []string{ScopeGpsRead, ScopeMotionRead}, http.StatusForbidden},
+		{"admin key", keyFile, map[string]string{"X-API-Key": "a"}, []string{ScopeGpsRead, ScopeMotionRead}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := setApiKeys(t, "", test.keyFile); err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/gps", nil)
+			w := httptest.NewRecorder()
+
+			for name, value := range test.headers {
+				r.Header.Set(name, value)
+			}
+
+			authorized := authorizeRequest(w, r, test.scopes)
+
+			if authorized != (test.want == http.StatusOK) || w.Code != test.want {
+				t.Errorf("authorizeRequest() = %v with status %d, want status %d", authorized, w.Code, test.want)
+			}
+		})
+	}
+}
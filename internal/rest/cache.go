@@ -0,0 +1,196 @@
+package rest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+const waitPollInterval = 50 * time.Millisecond
+
+// Get the expected period between GPS reports. The period is 0 if the frequency is not positive.
+func gpsPeriod() time.Duration {
+	global.Mutex.RLock()
+	defer global.Mutex.RUnlock()
+
+	if *global.GpsFrequency <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(time.Second) / *global.GpsFrequency)
+}
+
+// Get the expected period between motion sensor readings. The period is 0 if the frequency is not positive.
+func motionPeriod() time.Duration {
+	global.Mutex.RLock()
+	defer global.Mutex.RUnlock()
+
+	if *global.MotionFrequency <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(time.Second) / *global.MotionFrequency)
+}
+
+// Get the last update of GPS TPV and SKY report data.
+func lastGpsUpdate(gpsData *gps.Gps) time.Time {
+	lastTpvUpdate, _, _, _, _, _, _, _, _, _, _, _, _, _, _, _ := gpsData.GetTpv()
+	lastSkyUpdate, _, _, _, _, _, _, _, _, _, _, _ := gpsData.GetSky()
+
+	if lastSkyUpdate.After(lastTpvUpdate) {
+		return lastSkyUpdate
+	}
+
+	return lastTpvUpdate
+}
+
+// Get the last update of GPS TPV report data.
+func lastTpvUpdate(gpsData *gps.Gps) time.Time {
+	lastUpdate, _, _, _, _, _, _, _, _, _, _, _, _, _, _, _ := gpsData.GetTpv()
+
+	return lastUpdate
+}
+
+// Get the last update of GPS SKY report data.
+func lastSkyUpdate(gpsData *gps.Gps) time.Time {
+	lastUpdate, _, _, _, _, _, _, _, _, _, _, _ := gpsData.GetSky()
+
+	return lastUpdate
+}
+
+// Get the last update of motion sensor data.
+func lastMotionUpdate(motionData *motion.Motion) time.Time {
+	lastUpdate, _, _, _, _ := motionData.Get()
+
+	return lastUpdate
+}
+
+// Get the query of a request without the long-poll parameter.
+func representationQuery(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("wait")
+
+	return query.Encode()
+}
+
+// Create the ETag of a resource. The first part is the sample time, the second part identifies the representation.
+func createEtag(r *http.Request, lastUpdate time.Time) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(strings.TrimPrefix(r.URL.Path, apiVersionPrefix)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(representationQuery(r)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.Header.Get("Accept")))
+
+	return fmt.Sprintf("\"%s-%08x\"", strconv.FormatInt(lastUpdate.UnixNano(), 36), hash.Sum32())
+}
+
+// Get the sample time of an ETag, or the zero time if the ETag is invalid.
+func parseEtagTime(etag string) time.Time {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	etag = strings.Trim(etag, "\"")
+	sampleTime, _, found := strings.Cut(etag, "-")
+
+	if !found {
+		return time.Time{}
+	}
+
+	nanoseconds, err := strconv.ParseInt(sampleTime, 36, 64)
+
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanoseconds)
+}
+
+// Check if the client already has the current representation.
+func isNotModified(r *http.Request, etag string, lastUpdate time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" {
+		modifiedSince, err := http.ParseTime(ifModifiedSince)
+
+		return err == nil && !lastUpdate.Truncate(time.Second).After(modifiedSince)
+	}
+
+	return false
+}
+
+// Set the caching headers. The max-age is the time left until the next sample is expected.
+func setCacheHeaders(w http.ResponseWriter, etag string, lastUpdate time.Time, period time.Duration) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastUpdate.Format(http.TimeFormat))
+
+	if period <= 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+
+		return
+	}
+
+	maxAge := math.Max(0, math.Floor((period - time.Since(lastUpdate)).Seconds()))
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(maxAge)))
+}
+
+// Get the long-poll duration of a request, limited by the configured maximum.
+func getWaitDuration(query url.Values) time.Duration {
+	wait, err := time.ParseDuration(query.Get("wait"))
+
+	if err != nil || wait <= 0 {
+		return 0
+	}
+
+	maxWait := time.Duration(*global.RestMaxWait * float64(time.Second))
+
+	if wait > maxWait {
+		return maxWait
+	}
+
+	return wait
+}
+
+// Block a long-poll request until a newer sample than the client's ETag exists, the wait duration has passed,
+// or the client is gone. Only call this for authorized requests, as every waiting request holds a connection slot.
+func waitForUpdate(r *http.Request, getLastUpdate func() time.Time) {
+	wait := getWaitDuration(r.URL.Query())
+	etagTime := parseEtagTime(r.Header.Get("If-None-Match"))
+
+	if wait == 0 || etagTime.IsZero() {
+		return
+	}
+
+	timeout := time.NewTimer(wait)
+	ticker := time.NewTicker(waitPollInterval)
+
+	defer timeout.Stop()
+	defer ticker.Stop()
+
+	for !getLastUpdate().After(etagTime) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout.C:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Struct to store a motion sensor vector.
+type VectorJson struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Struct to store the motion sensor calibration status.
+type CalibrationJson struct {
+	Calibrated bool       `json:"calibrated"`
+	Method     string     `json:"method"`
+	Time       time.Time  `json:"time"`
+	Offset     VectorJson `json:"offset"`
+	Scale      VectorJson `json:"scale"`
+	Pending    []string   `json:"pending"`
+}
+
+// Struct to store a calibration request.
+type CalibrationRequestJson struct {
+	Method string `json:"method"`
+}
+
+// Write the motion sensor calibration status.
+func writeCalibration(w http.ResponseWriter, motionData *motion.Motion) {
+	calibration, pending := motionData.GetCalibration()
+	jsonData := CalibrationJson{
+		Scale:   VectorJson{X: 1, Y: 1, Z: 1},
+		Pending: pending,
+	}
+
+	if calibration != nil {
+		jsonData.Calibrated = true
+		jsonData.Method = calibration.Method
+		jsonData.Time = calibration.Time
+		jsonData.Offset = VectorJson{X: calibration.Offset[0], Y: calibration.Offset[1], Z: calibration.Offset[2]}
+		jsonData.Scale = VectorJson{X: calibration.Scale[0], Y: calibration.Scale[1], Z: calibration.Scale[2]}
+	}
+
+	writeJson(w, &jsonData)
+}
+
+// Handle sensors/motion/calibration request. GET returns the calibration status and requires the motion:read
+// scope. POST samples the stationary sensor for a level or six-orientation calibration, DELETE removes the
+// calibration. Both require the admin scope and configured API keys.
+func handleSensorsMotionCalibrationRequest(w http.ResponseWriter, r *http.Request, motionData *motion.Motion) {
+	setCorsHeaders(w)
+	w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, POST, DELETE")
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusNoContent)
+	case "GET":
+		if !authorizeRequest(w, r, []string{ScopeMotionRead}) {
+			return
+		}
+
+		writeCalibration(w, motionData)
+	case "POST":
+		if !authorizeChange(w, r) {
+			return
+		}
+
+		request := CalibrationRequestJson{}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+
+			return
+		}
+
+		var err error
+
+		switch request.Method {
+		case motion.CalibrationLevel:
+			err = motionData.CalibrateLevel()
+		case motion.CalibrationSixOrientation:
+			var orientation string
+			orientation, err = motionData.CalibrateOrientation()
+
+			if err == nil {
+				fmt.Printf("[%v] Motion sensor calibration orientation %s sampled\n", time.Now().UTC(), orientation)
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "Unknown calibration method "+request.Method)
+
+			return
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot calibrate motion sensor: %s\n", err)
+			writeError(w, http.StatusConflict, "Cannot calibrate motion sensor: "+err.Error())
+
+			return
+		}
+
+		writeCalibration(w, motionData)
+	case "DELETE":
+		if !authorizeChange(w, r) {
+			return
+		}
+
+		if err := motionData.ResetCalibration(); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot remove %s: %s\n", *global.MotionCalibrationFile, err)
+			writeError(w, http.StatusInternalServerError, "Cannot remove the motion sensor calibration")
+
+			return
+		}
+
+		writeCalibration(w, motionData)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/config"
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+// Struct to store the effective configuration.
+type ConfigJson struct {
+	Flags     map[string]interface{} `json:"flags"`
+	Runtime   []string               `json:"runtime"`
+	Persisted bool                   `json:"persisted"`
+}
+
+// Convert the JSON values of a configuration change to flag values.
+func parseConfigChanges(body map[string]interface{}) (map[string]string, error) {
+	changes := map[string]string{}
+
+	for name, value := range body {
+		switch value := value.(type) {
+		case bool:
+			changes[name] = strconv.FormatBool(value)
+		case json.Number:
+			changes[name] = value.String()
+		case string:
+			changes[name] = value
+		default:
+			return nil, fmt.Errorf("invalid value for %s", name)
+		}
+	}
+
+	return changes, nil
+}
+
+// Write the effective configuration.
+func writeConfig(w http.ResponseWriter, persisted bool) {
+	writeJson(w, &ConfigJson{
+		Flags:     config.Effective(),
+		Runtime:   config.RuntimeNames(),
+		Persisted: persisted,
+	})
+}
+
+// Handle config request. GET returns the effective configuration, PATCH changes runtime settings and optionally
+// persists them to the configuration file using ?persist=true. Both require the admin scope.
+func handleConfigRequest(w http.ResponseWriter, r *http.Request) {
+	setCorsHeaders(w)
+	w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, PATCH")
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusNoContent)
+	case "GET":
+		if !authorizeRequest(w, r, []string{ScopeAdmin}) {
+			return
+		}
+
+		writeConfig(w, false)
+	case "PATCH":
+		if !authorizeChange(w, r) {
+			return
+		}
+
+		body := map[string]interface{}{}
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+
+			return
+		}
+
+		changes, err := parseConfigChanges(body)
+
+		if err == nil {
+			err = config.Validate(changes)
+		}
+
+		if err != nil {
+			writeError(w, http.StatusBadRequest, strings.ToUpper(err.Error()[:1])+err.Error()[1:])
+
+			return
+		}
+
+		persist, _ := strconv.ParseBool(r.URL.Query().Get("persist"))
+
+		if persist {
+			if err := config.Persist(changes); err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot persist configuration to %s: %s\n", *global.ConfigFile, err)
+				writeError(w, http.StatusInternalServerError, "Cannot persist configuration to "+*global.ConfigFile)
+
+				return
+			}
+		}
+
+		if err := config.Apply(changes); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		key := findApiKey(r)
+		fmt.Printf("[%v] Configuration changed by key \"%s\": %v\n", time.Now().UTC(), key.Name, changes)
+
+		writeConfig(w, persist)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+)
+
+// Struct to store the GPS position in other coordinate systems. UTM and MGRS are omitted outside of the UTM range,
+// ENU is omitted if no ENU origin is configured.
+type CoordinatesJson struct {
+	Utm  *UtmJson `json:"utm,omitempty" proto:"1"`
+	Mgrs string   `json:"mgrs,omitempty" proto:"2"`
+	Ecef EcefJson `json:"ecef" proto:"3"`
+	Enu  *EnuJson `json:"enu,omitempty" proto:"4"`
+}
+
+// Struct to store a UTM position.
+type UtmJson struct {
+	Zone     uint8   `json:"zone" proto:"1"`
+	Band     string  `json:"band" proto:"2"`
+	Easting  float64 `json:"easting" proto:"3"`
+	Northing float64 `json:"northing" proto:"4"`
+}
+
+// Struct to store an ECEF position.
+type EcefJson struct {
+	X float64 `json:"x" proto:"1"`
+	Y float64 `json:"y" proto:"2"`
+	Z float64 `json:"z" proto:"3"`
+}
+
+// Struct to store an ENU position relative to the ENU origin.
+type EnuJson struct {
+	East  float64 `json:"east" proto:"1"`
+	North float64 `json:"north" proto:"2"`
+	Up    float64 `json:"up" proto:"3"`
+}
+
+// Handle sensors/gps/coordinates request. Use ?fields=utm,mgrs to select coordinate systems, and ?mgrsDigits=<0-5>
+// to set the MGRS precision, e.g. 5 digits for 1 m.
+func handleSensorsGpsCoordinatesRequest(w http.ResponseWriter, r *http.Request, data *gps.Gps, origin *geo.Origin) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	digits := 5
+
+	if value := r.URL.Query().Get("mgrsDigits"); value != "" {
+		number, err := strconv.Atoi(value)
+
+		if err != nil || number < 0 || number > 5 {
+			writeError(w, http.StatusBadRequest, "Invalid mgrsDigits "+value)
+
+			return
+		}
+
+		digits = number
+	}
+
+	waitForUpdate(r, func() time.Time { return lastTpvUpdate(data) })
+
+	lastUpdate, lat, lon, alt, _, mode, _, _, _, _, _, _, _, _, _, _ := data.GetTpv()
+	format, ok := negotiateFormat(w, r, CoordinatesJson{})
+
+	if !ok {
+		return
+	}
+
+	// Coordinates are only available with a fix.
+	if mode < 2 {
+		lastUpdate = time.Time{}
+	}
+
+	if !prepareRequest(w, r, lastUpdate, gpsPeriod()) {
+		return
+	}
+
+	// ECEF and ENU use the height above the ellipsoid, the GPS altitude is above mean sea level.
+	hae := alt + data.GetGeoidSeparation()
+	ecef := geo.ToEcef(lat, lon, hae)
+	jsonData := CoordinatesJson{
+		Ecef: EcefJson{X: ecef.X, Y: ecef.Y, Z: ecef.Z},
+	}
+
+	if utm, err := geo.ToUtm(lat, lon); err == nil {
+		jsonData.Utm = &UtmJson{Zone: utm.Zone, Band: string(utm.Band), Easting: utm.Easting, Northing: utm.Northing}
+		jsonData.Mgrs = utm.Mgrs(digits)
+	}
+
+	if origin != nil {
+		enu := origin.ToEnu(lat, lon, hae)
+		jsonData.Enu = &EnuJson{East: enu.East, North: enu.North, Up: enu.Up}
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/events"
+)
+
+// Struct to store detected events.
+type EventsJson struct {
+	Events []EventJson `json:"events"`
+}
+
+// Struct to store a detected event.
+type EventJson struct {
+	Id      uint64            `json:"id"`
+	Type    string            `json:"type"`
+	Time    time.Time         `json:"time"`
+	Peak    float64           `json:"peak"`
+	Lat     float64           `json:"lat"`
+	Lon     float64           `json:"lon"`
+	Speed   float64           `json:"speed"`
+	Samples []EventSampleJson `json:"samples"`
+}
+
+// Struct to store a motion sample of an event. The offset [ms] is relative to the event time.
+type EventSampleJson struct {
+	Offset float64 `json:"offset"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Z      float64 `json:"z"`
+}
+
+// Handle events request. Use ?since=<id> to only get events after a known event.
+func handleEventsRequest(w http.ResponseWriter, r *http.Request, detector *events.Detector) {
+	if !authorizeRead(w, r, ScopeMotionRead) {
+		return
+	}
+
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+
+	if err != nil && r.URL.Query().Get("since") != "" {
+		writeError(w, http.StatusBadRequest, "Invalid since "+r.URL.Query().Get("since"))
+
+		return
+	}
+
+	waitForUpdate(r, func() time.Time {
+		lastUpdate, _ := detector.GetSince(since)
+
+		return lastUpdate
+	})
+
+	lastUpdate, detected := detector.GetSince(since)
+	format, ok := negotiateFormat(w, r, EventsJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, 0) {
+		return
+	}
+
+	jsonData := EventsJson{
+		Events: []EventJson{},
+	}
+
+	for _, event := range detected {
+		eventJson := EventJson{
+			Id:      event.Id,
+			Type:    event.Type,
+			Time:    event.Time,
+			Peak:    event.Peak,
+			Lat:     event.Lat,
+			Lon:     event.Lon,
+			Speed:   event.Speed,
+			Samples: []EventSampleJson{},
+		}
+
+		for _, sample := range event.Samples {
+			eventJson.Samples = append(eventJson.Samples, EventSampleJson{
+				Offset: float64(sample.Time.Sub(event.Time)) / float64(time.Millisecond),
+				X:      sample.X,
+				Y:      sample.Y,
+				Z:      sample.Z,
+			})
+		}
+
+		jsonData.Events = append(jsonData.Events, eventJson)
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
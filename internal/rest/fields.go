@@ -0,0 +1,480 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Struct to store a single field of an object with a fixed field order.
+type objectField struct {
+	Name  string
+	Value interface{}
+}
+
+// Object with a fixed field order, used for sparse fieldsets.
+type object []objectField
+
+// Struct to store the satellite filters and sort order of a request.
+type satelliteFilter struct {
+	Gnssids    map[uint8]bool
+	Used       *bool
+	MinEl      *float64
+	MinSs      *float64
+	SortField  string
+	Descending bool
+}
+
+// Marshal the object as JSON, keeping the field order.
+func (data object) MarshalJSON() ([]byte, error) {
+	buffer := []byte{'{'}
+
+	for i, field := range data {
+		if i > 0 {
+			buffer = append(buffer, ',')
+		}
+
+		name, _ := json.Marshal(field.Name)
+		value, err := json.Marshal(field.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		buffer = append(buffer, name...)
+		buffer = append(buffer, ':')
+		buffer = append(buffer, value...)
+	}
+
+	return append(buffer, '}'), nil
+}
+
+// Parse the fields query parameter into selector paths, e.g. tpv.lat,motion.
+func parseFields(query url.Values) [][]string {
+	selectors := [][]string{}
+
+	for _, fields := range query["fields"] {
+		for _, field := range strings.Split(fields, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				selectors = append(selectors, strings.Split(field, "."))
+			}
+		}
+	}
+
+	return selectors
+}
+
+// Check if a selector is a suffix of a field path, so tpv.lat selects gps.tpv.lat.
+func matchesPath(selector []string, path []string) bool {
+	if len(selector) > len(path) {
+		return false
+	}
+
+	offset := len(path) - len(selector)
+
+	for i := range selector {
+		if selector[i] != path[offset+i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check if any selector selects a field path.
+func isSelected(selectors [][]string, path []string) bool {
+	for _, selector := range selectors {
+		if matchesPath(selector, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get the JSON name of a struct field, or an empty string if it is not serialized.
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+	if !field.IsExported() || name == "-" {
+		return ""
+	}
+
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// Get the struct type of a field, looking through slices and pointers.
+func structType(t reflect.Type) (reflect.Type, bool) {
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t, t.Kind() == reflect.Struct
+}
+
+// Collect the paths of all fields of a type.
+func collectPaths(t reflect.Type, path []string, paths *[][]string) {
+	t, ok := structType(t)
+
+	if !ok {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonFieldName(t.Field(i))
+
+		if name == "" {
+			continue
+		}
+
+		fieldPath := append(append([]string{}, path...), name)
+		*paths = append(*paths, fieldPath)
+		collectPaths(t.Field(i).Type, fieldPath, paths)
+	}
+}
+
+// Validate that each selector selects at least one field of the resource.
+func validateFields(selectors [][]string, data interface{}) error {
+	paths := [][]string{}
+	collectPaths(reflect.TypeOf(data), nil, &paths)
+
+	for _, selector := range selectors {
+		found := false
+
+		for _, path := range paths {
+			if matchesPath(selector, path) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return errors.New("Unknown field " + strings.Join(selector, "."))
+		}
+	}
+
+	return nil
+}
+
+// Select the fields of a value. Returns false if neither the value nor any nested field is selected.
+func selectValue(value reflect.Value, path []string, selectors [][]string) (interface{}, bool) {
+	if len(path) > 0 && isSelected(selectors, path) {
+		return value.Interface(), true
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil, false
+		}
+
+		return selectValue(value.Elem(), path, selectors)
+	case reflect.Slice:
+		if _, ok := structType(value.Type()); !ok {
+			return nil, false
+		}
+
+		items := []interface{}{}
+		selected := false
+
+		for i := 0; i < value.Len(); i++ {
+			item, ok := selectValue(value.Index(i), path, selectors)
+			items = append(items, item)
+			selected = selected || ok
+		}
+
+		// An empty slice is selected if its items would have selected fields.
+		if value.Len() == 0 {
+			selected = hasSelectedField(value.Type().Elem(), path, selectors)
+		}
+
+		return items, selected
+	case reflect.Struct:
+		fields := object{}
+
+		for i := 0; i < value.NumField(); i++ {
+			name := jsonFieldName(value.Type().Field(i))
+
+			if name == "" {
+				continue
+			}
+
+			fieldPath := append(append([]string{}, path...), name)
+
+			if fieldValue, ok := selectValue(value.Field(i), fieldPath, selectors); ok {
+				fields = append(fields, objectField{Name: name, Value: fieldValue})
+			}
+		}
+
+		return fields, len(fields) > 0
+	}
+
+	return nil, false
+}
+
+// Check if a type has any selected field.
+func hasSelectedField(t reflect.Type, path []string, selectors [][]string) bool {
+	paths := [][]string{}
+	collectPaths(t, path, &paths)
+
+	for _, fieldPath := range paths {
+		if isSelected(selectors, fieldPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Create a sparse fieldset of a resource for JSON and MessagePack.
+func selectFields(data interface{}, selectors [][]string) interface{} {
+	selected, ok := selectValue(reflect.ValueOf(data), nil, selectors)
+
+	if !ok {
+		return object{}
+	}
+
+	return selected
+}
+
+// Zero all fields that are not selected, for formats that omit zero values like Protocol Buffers.
+func zeroUnselectedFields(value reflect.Value, path []string, selectors [][]string) {
+	if len(path) > 0 && isSelected(selectors, path) {
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			zeroUnselectedFields(value.Elem(), path, selectors)
+		}
+	case reflect.Slice:
+		if !hasSelectedField(value.Type().Elem(), path, selectors) {
+			value.Set(reflect.Zero(value.Type()))
+
+			return
+		}
+
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		reflect.Copy(copied, value)
+		value.Set(copied)
+
+		for i := 0; i < value.Len(); i++ {
+			zeroUnselectedFields(value.Index(i), path, selectors)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			name := jsonFieldName(value.Type().Field(i))
+
+			if name != "" {
+				zeroUnselectedFields(value.Field(i), append(append([]string{}, path...), name), selectors)
+			}
+		}
+	default:
+		if value.CanSet() {
+			value.Set(reflect.Zero(value.Type()))
+		}
+	}
+}
+
+// Check if a selector selects a CSV column. The selector selects the field of the column, e.g. prn or
+// satellites.prn, or a field that contains it, e.g. satellites.
+func selectsCsvColumn(selector []string, column string, paths [][]string) bool {
+	for _, path := range paths {
+		if path[len(path)-1] != column {
+			continue
+		}
+
+		for i := 1; i <= len(path); i++ {
+			if matchesPath(selector, path[:i]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Validate that each selector selects at least one CSV column of the resource. Fields that are not written as CSV,
+// e.g. the dilution of precision of the SKY report, cannot be selected.
+func validateCsvColumns(selectors [][]string, data interface{}) error {
+	paths := [][]string{}
+	collectPaths(reflect.TypeOf(data), nil, &paths)
+	header := createCsvRecords(data)[0]
+
+	for _, selector := range selectors {
+		found := false
+
+		for _, column := range header {
+			if selectsCsvColumn(selector, column, paths) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return errors.New("Field " + strings.Join(selector, ".") + " is not a CSV column")
+		}
+	}
+
+	return nil
+}
+
+// Remove the CSV columns that are not selected.
+func selectCsvColumns(records [][]string, selectors [][]string, data interface{}) [][]string {
+	if len(records) == 0 {
+		return records
+	}
+
+	paths := [][]string{}
+	collectPaths(reflect.TypeOf(data), nil, &paths)
+	columns := []int{}
+
+	for i, name := range records[0] {
+		for _, selector := range selectors {
+			if selectsCsvColumn(selector, name, paths) {
+				columns = append(columns, i)
+				break
+			}
+		}
+	}
+
+	selected := [][]string{}
+
+	for _, record := range records {
+		selectedRecord := []string{}
+
+		for _, column := range columns {
+			selectedRecord = append(selectedRecord, record[column])
+		}
+
+		selected = append(selected, selectedRecord)
+	}
+
+	return selected
+}
+
+// Parse the satellite filters and sort order, e.g. ?gnssid=2&used=true&minEl=15&sort=-ss.
+func parseSatelliteFilter(query url.Values) (satelliteFilter, error) {
+	filter := satelliteFilter{}
+
+	for _, gnssids := range query["gnssid"] {
+		for _, gnssid := range strings.Split(gnssids, ",") {
+			value, err := strconv.ParseUint(strings.TrimSpace(gnssid), 10, 8)
+
+			if err != nil {
+				return filter, errors.New("Invalid gnssid " + gnssid)
+			}
+
+			if filter.Gnssids == nil {
+				filter.Gnssids = make(map[uint8]bool)
+			}
+
+			filter.Gnssids[uint8(value)] = true
+		}
+	}
+
+	if used := query.Get("used"); used != "" {
+		value, err := strconv.ParseBool(used)
+
+		if err != nil {
+			return filter, errors.New("Invalid used " + used)
+		}
+
+		filter.Used = &value
+	}
+
+	if minEl := query.Get("minEl"); minEl != "" {
+		value, err := strconv.ParseFloat(minEl, 64)
+
+		if err != nil {
+			return filter, errors.New("Invalid minEl " + minEl)
+		}
+
+		filter.MinEl = &value
+	}
+
+	if minSs := query.Get("minSs"); minSs != "" {
+		value, err := strconv.ParseFloat(minSs, 64)
+
+		if err != nil {
+			return filter, errors.New("Invalid minSs " + minSs)
+		}
+
+		filter.MinSs = &value
+	}
+
+	if sortField := query.Get("sort"); sortField != "" {
+		filter.Descending = strings.HasPrefix(sortField, "-")
+		filter.SortField = strings.TrimPrefix(sortField, "-")
+
+		switch filter.SortField {
+		case "prn", "az", "el", "ss", "gnssid":
+		default:
+			return filter, errors.New("Invalid sort " + sortField)
+		}
+	}
+
+	return filter, nil
+}
+
+// Get the value of a satellite field to sort on.
+func satelliteSortValue(satellite SatelliteJson, field string) float64 {
+	switch field {
+	case "az":
+		return satellite.Az
+	case "el":
+		return satellite.El
+	case "ss":
+		return satellite.Ss
+	case "gnssid":
+		return float64(satellite.Gnssid)
+	}
+
+	return satellite.Prn
+}
+
+// Filter and sort the satellites.
+func (filter *satelliteFilter) Apply(satellites []SatelliteJson) []SatelliteJson {
+	filtered := []SatelliteJson{}
+
+	for _, satellite := range satellites {
+		if filter.Gnssids != nil && !filter.Gnssids[satellite.Gnssid] {
+			continue
+		}
+
+		if filter.Used != nil && satellite.Used != *filter.Used {
+			continue
+		}
+
+		if filter.MinEl != nil && satellite.El < *filter.MinEl {
+			continue
+		}
+
+		if filter.MinSs != nil && satellite.Ss < *filter.MinSs {
+			continue
+		}
+
+		filtered = append(filtered, satellite)
+	}
+
+	if filter.SortField != "" {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			if filter.Descending {
+				return satelliteSortValue(filtered[i], filter.SortField) > satelliteSortValue(filtered[j], filter.SortField)
+			}
+
+			return satelliteSortValue(filtered[i], filter.SortField) < satelliteSortValue(filtered[j], filter.SortField)
+		})
+	}
+
+	return filtered
+}
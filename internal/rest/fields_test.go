@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectCsvColumns(t *testing.T) {
+	sky := SkyJson{
+		Qual:       2,
+		Satellites: []SatelliteJson{{Prn: 1, Ss: 40, Used: true}, {Prn: 2, Ss: 30}},
+	}
+
+	tests := []struct {
+		name   string
+		data   interface{}
+		fields string
+		want   string
+		err    string
+	}{
+		{"flat column", &TpvJson{Lat: 52, Lon: 5}, "lat,lon", "lat,lon|52,5", ""},
+		{"flat column order", &TpvJson{Lat: 52, Lon: 5}, "lon,lat", "lat,lon|52,5", ""},
+		{"nested column", &sky, "satellites.prn,ss", "prn,ss|1,40|2,30", ""},
+		{"containing field", &sky, "satellites", "prn,az,el,ss,gnssid,used|1,0,0,40,0,true|2,0,0,30,0,false", ""},
+		{"field that is not a column", &sky, "qual", "", "Field qual is not a CSV column"},
+		{"one of the fields is not a column", &sky, "prn,xdop", "", "Field xdop is not a CSV column"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selectors := [][]string{}
+
+			for _, field := range strings.Split(test.fields, ",") {
+				selectors = append(selectors, strings.Split(field, "."))
+			}
+
+			err := validateCsvColumns(selectors, test.data)
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("validateCsvColumns(%s) error = %v, want %q", test.fields, err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("validateCsvColumns(%s) returned error %s", test.fields, err)
+			}
+
+			rows := []string{}
+
+			for _, record := range selectCsvColumns(createCsvRecords(test.data), selectors, test.data) {
+				rows = append(rows, strings.Join(record, ","))
+			}
+
+			if got := strings.Join(rows, "|"); got != test.want {
+				t.Errorf("selectCsvColumns(%s) = %s, want %s", test.fields, got, test.want)
+			}
+		})
+	}
+}
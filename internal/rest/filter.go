@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/filter"
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+// Struct to store the Kalman filtered GPS data.
+type FilteredJson struct {
+	Model   string  `json:"model" proto:"1"`
+	Lat     float64 `json:"lat" proto:"2"`
+	Lon     float64 `json:"lon" proto:"3"`
+	Alt     float64 `json:"alt" proto:"4"`
+	Speed   float64 `json:"speed" proto:"5"`
+	Heading float64 `json:"heading" proto:"6"`
+	VelE    float64 `json:"velE" proto:"7"`
+	VelN    float64 `json:"velN" proto:"8"`
+	VelU    float64 `json:"velU" proto:"9"`
+	Eph     float64 `json:"eph" proto:"10"`
+	Epv     float64 `json:"epv" proto:"11"`
+	Eps     float64 `json:"eps" proto:"12"`
+}
+
+// Handle sensors/gps/filtered request.
+func handleSensorsGpsFilteredRequest(w http.ResponseWriter, r *http.Request, data *filter.Filter) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time {
+		lastUpdate, _, _, _, _, _, _, _, _, _, _ := data.Get()
+
+		return lastUpdate
+	})
+
+	lastUpdate, model, lat, lon, alt, velE, velN, velU, eph, epv, eps := data.Get()
+	format, ok := negotiateFormat(w, r, FilteredJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, time.Duration(float64(time.Second) / *global.FilterRate)) {
+		return
+	}
+
+	speed, heading := filter.SpeedHeading(velE, velN)
+	jsonData := FilteredJson{
+		Model:   model,
+		Lat:     lat,
+		Lon:     lon,
+		Alt:     alt,
+		Speed:   speed,
+		Heading: heading,
+		VelE:    velE,
+		VelN:    velN,
+		VelU:    velU,
+		Eph:     eph,
+		Epv:     epv,
+		Eps:     eps,
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
@@ -0,0 +1,285 @@
+package rest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Supported response formats.
+const (
+	FormatJson     = "json"
+	FormatCsv      = "csv"
+	FormatMsgpack  = "msgpack"
+	FormatProtobuf = "protobuf"
+)
+
+// Content types of the supported response formats.
+var formatContentTypes = map[string]string{
+	FormatJson:     "application/json",
+	FormatCsv:      "text/csv; charset=utf-8",
+	FormatMsgpack:  "application/msgpack",
+	FormatProtobuf: "application/x-protobuf",
+}
+
+// Formats accepted by media type in the Accept header.
+var mediaTypeFormats = map[string]string{
+	"application/json":        FormatJson,
+	"text/csv":                FormatCsv,
+	"application/msgpack":     FormatMsgpack,
+	"application/x-msgpack":   FormatMsgpack,
+	"application/vnd.msgpack": FormatMsgpack,
+	"application/protobuf":    FormatProtobuf,
+	"application/x-protobuf":  FormatProtobuf,
+}
+
+// Interface for resources that are not flat, but can still be written as CSV records.
+type csvRecorder interface {
+	CsvRecords() [][]string
+}
+
+// Check if a type is a struct with only scalar fields, which is written as a single CSV record.
+func isFlat(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		switch t.Field(i).Type.Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map, reflect.Pointer, reflect.Interface:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check if a resource can be written in a format.
+func supportsFormat(format string, data interface{}) bool {
+	switch format {
+	case FormatJson, FormatMsgpack:
+		return true
+	case FormatCsv:
+		_, ok := data.(csvRecorder)
+
+		return ok || isFlat(reflect.TypeOf(data))
+	case FormatProtobuf:
+		return supportsProtobuf(reflect.TypeOf(data))
+	}
+
+	return false
+}
+
+// Negotiate the response format using the format query parameter or the Accept header. Writes a not acceptable
+// response and returns false if the resource does not support any of the requested formats. Writes a bad request
+// response and returns false if the fields query parameter selects unknown fields, or fields that are not CSV columns
+// of a CSV response. Only call this for authorized requests, as the errors reveal the fields and formats of the
+// resource.
+func negotiateFormat(w http.ResponseWriter, r *http.Request, data interface{}) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+
+	selectors := parseFields(r.URL.Query())
+
+	if err := validateFields(selectors, data); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+
+		return "", false
+	}
+
+	format := r.URL.Query().Get("format")
+
+	if format != "" && !supportsFormat(format, data) {
+		writeError(w, http.StatusNotAcceptable, "Format "+format+" is not supported by this resource")
+
+		return "", false
+	} else if format == "" {
+		format = negotiateMediaType(r.Header.Get("Accept"), data)
+
+		if format == "" {
+			writeError(w, http.StatusNotAcceptable, "None of the accepted media types are supported by this resource")
+
+			return "", false
+		}
+	}
+
+	if format == FormatCsv {
+		if err := validateCsvColumns(selectors, data); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+
+			return "", false
+		}
+	}
+
+	w.Header().Set("Content-Type", formatContentTypes[format])
+
+	return format, true
+}
+
+// Get the supported format with the highest quality in an Accept header, JSON if the header is empty, or an empty
+// string if no format is supported.
+func negotiateMediaType(accept string, data interface{}) string {
+	if accept == "" {
+		return FormatJson
+	}
+
+	bestFormat := ""
+	bestQuality := 0.0
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+
+		if q, ok := params["q"]; ok {
+			quality, _ = strconv.ParseFloat(q, 64)
+		}
+
+		format, ok := mediaTypeFormats[mediaType]
+
+		if mediaType == "*/*" || mediaType == "application/*" {
+			format, ok = FormatJson, true
+		}
+
+		if ok && quality > bestQuality && supportsFormat(format, data) {
+			bestFormat = format
+			bestQuality = quality
+		}
+	}
+
+	return bestFormat
+}
+
+// Format a scalar value for CSV.
+func formatCsvValue(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value.Interface())
+}
+
+// Create the CSV header and records of a flat struct or slice of flat structs.
+func createCsvRecords(data interface{}) [][]string {
+	if recorder, ok := data.(csvRecorder); ok {
+		return recorder.CsvRecords()
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(data))
+	rows := []reflect.Value{value}
+
+	if value.Kind() == reflect.Slice {
+		rows = []reflect.Value{}
+
+		for i := 0; i < value.Len(); i++ {
+			rows = append(rows, reflect.Indirect(value.Index(i)))
+		}
+	}
+
+	t := value.Type()
+
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	header := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		header = append(header, name)
+	}
+
+	records := [][]string{header}
+
+	for _, row := range rows {
+		record := []string{}
+
+		for i := 0; i < row.NumField(); i++ {
+			record = append(record, formatCsvValue(row.Field(i)))
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// Write the response body in the negotiated format, limited to the fields selected by the fields query parameter.
+func writeFormat(w http.ResponseWriter, r *http.Request, format string, data interface{}) {
+	selectors := parseFields(r.URL.Query())
+
+	switch format {
+	case FormatCsv:
+		records := createCsvRecords(data)
+
+		if len(selectors) > 0 {
+			records = selectCsvColumns(records, selectors, data)
+		}
+
+		writer := csv.NewWriter(w)
+		_ = writer.WriteAll(records)
+	case FormatMsgpack:
+		if len(selectors) > 0 {
+			data = selectFields(data, selectors)
+		}
+
+		_, _ = w.Write(encodeMsgpack(data))
+	case FormatProtobuf:
+		if len(selectors) > 0 {
+			// Protocol Buffers omits zero values, so unselected fields are zeroed on a copy.
+			value := reflect.Indirect(reflect.ValueOf(data))
+			copied := reflect.New(value.Type())
+			copied.Elem().Set(value)
+			zeroUnselectedFields(copied.Elem(), nil, selectors)
+			data = copied.Interface()
+		}
+
+		_, _ = w.Write(encodeProtobuf(data))
+	default:
+		if len(selectors) > 0 {
+			data = selectFields(data, selectors)
+		}
+
+		jsonString, _ := json.Marshal(data)
+		fmt.Fprint(w, string(jsonString))
+	}
+}
+
+// Handle sensor.proto request. No API key is required, so clients can be generated from it.
+func handleProtobufSchemaRequest(w http.ResponseWriter, r *http.Request) {
+	setCorsHeaders(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	} else if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, createProtobufSchema())
+}
@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/geofence"
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+// Maximum size [bytes] of an uploaded GeoJSON document, unless the maximum request body size is larger.
+const maxGeoJsonSize int64 = 1 << 20
+
+// Get the maximum request body size [bytes] of the geofences route.
+func geoJsonBodySize() int64 {
+	return max(maxGeoJsonSize, int64(*global.RestMaxBodySize))
+}
+
+// Struct to store the geofence membership and the entry and exit events.
+type GeofenceEventsJson struct {
+	Zones  []GeofenceZoneJson  `json:"zones"`
+	Events []GeofenceEventJson `json:"events"`
+}
+
+// Struct to store the membership of a geofence.
+type GeofenceZoneJson struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Inside bool   `json:"inside"`
+}
+
+// Struct to store a geofence entry or exit.
+type GeofenceEventJson struct {
+	Id    uint64    `json:"id"`
+	Type  string    `json:"type"`
+	Zone  string    `json:"zone"`
+	Index int       `json:"index"`
+	Time  time.Time `json:"time"`
+	Lat   float64   `json:"lat"`
+	Lon   float64   `json:"lon"`
+}
+
+// Handle geofences request. GET returns the GeoJSON of the geofences and requires the gps:read scope. PUT replaces
+// the geofences and requires the admin scope and configured API keys.
+func handleGeofencesRequest(w http.ResponseWriter, r *http.Request, geofences *geofence.Geofences) {
+	setCorsHeaders(w)
+	w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, PUT")
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusNoContent)
+	case "GET":
+		if !authorizeRequest(w, r, []string{ScopeGpsRead}) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(geofences.GetGeoJson())
+	case "PUT":
+		if !authorizeChange(w, r) {
+			return
+		}
+
+		content, err := io.ReadAll(r.Body)
+		var maxBytesErr *http.MaxBytesError
+
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "GeoJSON is larger than "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+
+			return
+		} else if err != nil {
+			writeError(w, http.StatusBadRequest, "Cannot read body: "+err.Error())
+
+			return
+		}
+
+		zones, err := geofence.ParseZones(content)
+
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid GeoJSON: "+err.Error())
+
+			return
+		}
+
+		if err := geofences.Replace(content, zones); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot write %s: %s\n", *global.GeofenceFile, err)
+			writeError(w, http.StatusInternalServerError, "Cannot save the geofences")
+
+			return
+		}
+
+		fmt.Printf("[%v] Geofences replaced\n", time.Now().UTC())
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(geofences.GetGeoJson())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// Handle geofences/events request. Use ?since=<id> to only get events after a known event.
+func handleGeofenceEventsRequest(w http.ResponseWriter, r *http.Request, geofences *geofence.Geofences) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+
+	if err != nil && r.URL.Query().Get("since") != "" {
+		writeError(w, http.StatusBadRequest, "Invalid since "+r.URL.Query().Get("since"))
+
+		return
+	}
+
+	waitForUpdate(r, func() time.Time {
+		lastUpdate, _, _ := geofences.GetZones()
+
+		return lastUpdate
+	})
+
+	lastUpdate, zones, inside := geofences.GetZones()
+	format, ok := negotiateFormat(w, r, GeofenceEventsJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, 0) {
+		return
+	}
+
+	jsonData := GeofenceEventsJson{
+		Zones:  []GeofenceZoneJson{},
+		Events: []GeofenceEventJson{},
+	}
+
+	for index, zone := range zones {
+		jsonData.Zones = append(jsonData.Zones, GeofenceZoneJson{Index: index, Name: zone.Name, Inside: inside[index]})
+	}
+
+	for _, event := range geofences.GetSince(since) {
+		jsonData.Events = append(jsonData.Events, GeofenceEventJson{
+			Id:    event.Id,
+			Type:  event.Type,
+			Zone:  event.Zone,
+			Index: event.Index,
+			Time:  event.Time,
+			Lat:   event.Lat,
+			Lon:   event.Lon,
+		})
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
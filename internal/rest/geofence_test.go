@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vuhuy/tcg4-sensor/internal/geofence"
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+func TestGeofencesBodySize(t *testing.T) {
+	geofenceFile := *global.GeofenceFile
+
+	t.Cleanup(func() {
+		*global.GeofenceFile = geofenceFile
+	})
+
+	*global.GeofenceFile = filepath.Join(t.TempDir(), "geofences.json")
+
+	if err := setApiKeys(t, "", `{"keys": [{"name": "admin", "key": "a", "scopes": ["admin"]}]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	// A polygon with enough vertices to be larger than the default maximum request body size, but within the GeoJSON
+	// limit.
+	vertices := []string{}
+
+	for i := 0; i < 4000; i++ {
+		angle := 2 * math.Pi * float64(i) / 4000
+		vertices = append(vertices, fmt.Sprintf("[%.7f, %.7f]", 5+0.001*math.Cos(angle), 52+0.001*math.Sin(angle)))
+	}
+
+	vertices = append(vertices, vertices[0])
+	large := `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "depot"}, ` +
+		`"geometry": {"type": "Polygon", "coordinates": [[` + strings.Join(vertices, ", ") + `]]}}]}`
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"larger than the default body size", large, http.StatusOK},
+		{"larger than the GeoJSON limit", strings.Repeat(" ", int(maxGeoJsonSize)) + large, http.StatusRequestEntityTooLarge},
+	}
+
+	handler := wrapHandler(geoJsonBodySize(), func(w http.ResponseWriter, r *http.Request) {
+		handleGeofencesRequest(w, r, &geofence.Geofences{})
+	})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/geofences", strings.NewReader(test.body))
+			r.Header.Set("X-API-Key", "a")
+			w := httptest.NewRecorder()
+
+			handler(w, r)
+
+			if w.Code != test.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, test.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/can"
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/metrics"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+const healthStatusOk = "ok"
+const healthStatusFail = "fail"
+
+// Struct to store health data.
+type HealthJson struct {
+	Status  string           `json:"status"`
+	Version string           `json:"version"`
+	Uptime  float64          `json:"uptime"`
+	Gps     GpsHealthJson    `json:"gps"`
+	Can     CanHealthJson    `json:"can"`
+	Motion  MotionHealthJson `json:"motion"`
+}
+
+// Struct to store GPS subsystem health data.
+type GpsHealthJson struct {
+	Status        string  `json:"status"`
+	Connected     bool    `json:"connected"`
+	LastReportAge float64 `json:"lastReportAge"`
+	Mode          uint8   `json:"mode"`
+}
+
+// Struct to store CAN subsystem health data.
+type CanHealthJson struct {
+	Status    string `json:"status"`
+	Interface string `json:"interface"`
+	Up        bool   `json:"up"`
+	Errors    uint64 `json:"errors"`
+}
+
+// Struct to store motion subsystem health data.
+type MotionHealthJson struct {
+	Status      string  `json:"status"`
+	Readable    bool    `json:"readable"`
+	LastReadAge float64 `json:"lastReadAge"`
+}
+
+// Convert a health check result to a status.
+func healthStatus(healthy bool) string {
+	if healthy {
+		return healthStatusOk
+	}
+
+	return healthStatusFail
+}
+
+// Check if the age of sensor data is within the configured maximum.
+func isFresh(age float64) bool {
+	return age >= 0 && age <= *global.HealthMaxAge
+}
+
+// Handle health request. No API key is required, so load balancers and watchdogs can use it.
+func handleHealthRequest(w http.ResponseWriter, r *http.Request, data *Rest, gpsData *gps.Gps, motionData *motion.Motion, canData *can.Can) {
+	setCorsHeaders(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	} else if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+
+		return
+	}
+
+	if *global.Verbose {
+		fmt.Printf("[%v] HTTP request: %s \"%s %s\" \"%s\"\n", time.Now().UTC(), r.RemoteAddr, r.Method, r.URL.Path, r.UserAgent())
+	}
+
+	lastTpvUpdate, _, _, _, _, mode, _, _, _, _, _, _, _, _, _, _ := gpsData.GetTpv()
+	lastSkyUpdate, _, _, _, _, _, _, _, _, _, _, _ := gpsData.GetSky()
+	lastMotionUpdate, _, _, _, _ := motionData.Get()
+
+	lastReportUpdate := lastTpvUpdate
+
+	if lastSkyUpdate.After(lastReportUpdate) {
+		lastReportUpdate = lastSkyUpdate
+	}
+
+	gpsHealth := GpsHealthJson{
+		Connected:     gpsData.GetConnected(),
+		LastReportAge: dataAge(lastReportUpdate),
+		Mode:          mode,
+	}
+
+	gpsHealth.Status = healthStatus(gpsHealth.Connected && isFresh(gpsHealth.LastReportAge))
+
+	canHealth := CanHealthJson{
+		Interface: *global.CanInterface,
+		Up:        canData.IsUp(),
+		Errors:    metrics.CanFramesFailed.Get(),
+	}
+
+	canHealth.Status = healthStatus(canHealth.Up)
+
+	motionHealth := MotionHealthJson{
+		Readable:    motionData.GetReadable(),
+		LastReadAge: dataAge(lastMotionUpdate),
+	}
+
+	motionHealth.Status = healthStatus(motionHealth.Readable && isFresh(motionHealth.LastReadAge))
+
+	jsonData := HealthJson{
+		Status:  healthStatus(gpsHealth.Status == healthStatusOk && canHealth.Status == healthStatusOk && motionHealth.Status == healthStatusOk),
+		Version: data.Version,
+		Uptime:  time.Since(data.StartTime).Seconds(),
+		Gps:     gpsHealth,
+		Can:     canHealth,
+		Motion:  motionHealth,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if jsonData.Status == healthStatusOk {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	jsonString, _ := json.Marshal(&jsonData)
+	fmt.Fprint(w, string(jsonString))
+}
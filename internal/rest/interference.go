@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/interference"
+)
+
+// Struct to store the interference status and the interference events.
+type InterferenceJson struct {
+	Status      string                  `json:"status"`
+	Indicators  []string                `json:"indicators"`
+	Cn0         float64                 `json:"cn0"`
+	Cn0Baseline float64                 `json:"cn0Baseline"`
+	Cn0Spread   float64                 `json:"cn0Spread"`
+	Satellites  int                     `json:"satellites"`
+	Events      []InterferenceEventJson `json:"events"`
+}
+
+// Struct to store a change of the interference status or the active indicators.
+type InterferenceEventJson struct {
+	Id         uint64    `json:"id"`
+	Time       time.Time `json:"time"`
+	Status     string    `json:"status"`
+	Indicators []string  `json:"indicators"`
+	Details    []string  `json:"details"`
+	Cn0        float64   `json:"cn0"`
+	Satellites int       `json:"satellites"`
+	Lat        float64   `json:"lat"`
+	Lon        float64   `json:"lon"`
+}
+
+// Handle interference request. Use ?since=<id> to only get events after a known event.
+func handleInterferenceRequest(w http.ResponseWriter, r *http.Request, detector *interference.Detector) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+
+	if err != nil && r.URL.Query().Get("since") != "" {
+		writeError(w, http.StatusBadRequest, "Invalid since "+r.URL.Query().Get("since"))
+
+		return
+	}
+
+	waitForUpdate(r, func() time.Time {
+		lastUpdate, _ := detector.Get()
+
+		return lastUpdate
+	})
+
+	lastUpdate, status := detector.Get()
+	format, ok := negotiateFormat(w, r, InterferenceJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, 0) {
+		return
+	}
+
+	jsonData := InterferenceJson{
+		Status:      status.Status,
+		Indicators:  status.Indicators,
+		Cn0:         status.Cn0,
+		Cn0Baseline: status.Cn0Baseline,
+		Cn0Spread:   status.Cn0Spread,
+		Satellites:  status.Satellites,
+		Events:      []InterferenceEventJson{},
+	}
+
+	for _, event := range detector.GetSince(since) {
+		jsonData.Events = append(jsonData.Events, InterferenceEventJson{
+			Id:         event.Id,
+			Time:       event.Time,
+			Status:     event.Status,
+			Indicators: event.Indicators,
+			Details:    event.Details,
+			Cn0:        event.Cn0,
+			Satellites: event.Satellites,
+			Lat:        event.Lat,
+			Lon:        event.Lon,
+		})
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
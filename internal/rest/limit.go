@@ -0,0 +1,269 @@
+package rest
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+const limiterPruneInterval = time.Minute
+
+// Struct to store a token bucket of a single client.
+type tokenBucket struct {
+	Tokens     float64
+	LastRefill time.Time
+}
+
+// Struct to store the token buckets of all clients.
+type rateLimiter struct {
+	Mutex     sync.Mutex
+	Buckets   map[string]*tokenBucket
+	LastPrune time.Time
+}
+
+// Struct to store failed authentication attempts of a single client.
+type authFailures struct {
+	Count       uint64
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Struct to store failed authentication attempts of all clients.
+type authLockout struct {
+	Mutex     sync.Mutex
+	Clients   map[string]*authFailures
+	LastPrune time.Time
+}
+
+// Struct to limit the number of concurrent connections of a listener.
+type limitListener struct {
+	net.Listener
+	semaphore chan struct{}
+	closed    chan struct{}
+	close     sync.Once
+}
+
+// Struct to release the connection slot once a connection is closed.
+type limitListenerConn struct {
+	net.Conn
+	release   sync.Once
+	semaphore chan struct{}
+}
+
+var clientLimiter = rateLimiter{}
+var keyLimiter = rateLimiter{}
+var lockout = authLockout{}
+
+// Take a token from the bucket of a client with mutex lock. Returns the time to wait if no token is available.
+func (limiter *rateLimiter) Allow(client string, rate float64, burst float64) (bool, time.Duration) {
+	limiter.Mutex.Lock()
+	defer limiter.Mutex.Unlock()
+
+	now := time.Now()
+
+	if limiter.Buckets == nil {
+		limiter.Buckets = make(map[string]*tokenBucket)
+	}
+
+	if now.Sub(limiter.LastPrune) > limiterPruneInterval {
+		for key, bucket := range limiter.Buckets {
+			if bucket.Tokens+now.Sub(bucket.LastRefill).Seconds()*rate >= burst {
+				delete(limiter.Buckets, key)
+			}
+		}
+
+		limiter.LastPrune = now
+	}
+
+	bucket, ok := limiter.Buckets[client]
+
+	if !ok {
+		bucket = &tokenBucket{Tokens: burst, LastRefill: now}
+		limiter.Buckets[client] = bucket
+	}
+
+	bucket.Tokens = math.Min(burst, bucket.Tokens+now.Sub(bucket.LastRefill).Seconds()*rate)
+	bucket.LastRefill = now
+
+	if bucket.Tokens < 1 {
+		return false, time.Duration((1 - bucket.Tokens) / rate * float64(time.Second))
+	}
+
+	bucket.Tokens--
+
+	return true, 0
+}
+
+// Check if a client is locked out with mutex lock. Returns the remaining lockout time.
+func (lockout *authLockout) IsLocked(client string) (bool, time.Duration) {
+	lockout.Mutex.Lock()
+	defer lockout.Mutex.Unlock()
+
+	failures, ok := lockout.Clients[client]
+
+	if !ok || time.Now().After(failures.LockedUntil) {
+		return false, 0
+	}
+
+	return true, time.Until(failures.LockedUntil)
+}
+
+// Register a failed authentication attempt of a client with mutex lock.
+func (lockout *authLockout) Fail(client string) {
+	if *global.RestLockoutFailures == 0 {
+		return
+	}
+
+	lockout.Mutex.Lock()
+	defer lockout.Mutex.Unlock()
+
+	now := time.Now()
+	duration := time.Duration(*global.RestLockoutDuration * float64(time.Second))
+
+	if lockout.Clients == nil {
+		lockout.Clients = make(map[string]*authFailures)
+	}
+
+	if now.Sub(lockout.LastPrune) > limiterPruneInterval {
+		for key, failures := range lockout.Clients {
+			if now.Sub(failures.LastFailure) > duration && now.After(failures.LockedUntil) {
+				delete(lockout.Clients, key)
+			}
+		}
+
+		lockout.LastPrune = now
+	}
+
+	failures, ok := lockout.Clients[client]
+
+	if !ok || now.Sub(failures.LastFailure) > duration {
+		failures = &authFailures{}
+		lockout.Clients[client] = failures
+	}
+
+	failures.Count++
+	failures.LastFailure = now
+
+	if failures.Count >= *global.RestLockoutFailures {
+		failures.Count = 0
+		failures.LockedUntil = now.Add(duration)
+
+		fmt.Printf("[%v] Locked out HTTP client %s for %s after repeated unauthorized requests\n", now.UTC(), client, duration)
+	}
+}
+
+// Reset the failed authentication attempts of a client with mutex lock.
+func (lockout *authLockout) Reset(client string) {
+	lockout.Mutex.Lock()
+	defer lockout.Mutex.Unlock()
+
+	delete(lockout.Clients, client)
+}
+
+// Get the IP address of the client.
+func getClientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Write a too many requests response.
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many requests, retry after %d seconds", seconds))
+}
+
+// Check the lockout and rate limits of the client. Writes the error response if limited.
+func limitRequest(w http.ResponseWriter, r *http.Request) bool {
+	client := getClientIp(r)
+
+	if locked, wait := lockout.IsLocked(client); locked {
+		writeTooManyRequests(w, wait)
+
+		return false
+	}
+
+	if *global.RestRateLimit <= 0 {
+		return true
+	}
+
+	if allowed, wait := clientLimiter.Allow(client, *global.RestRateLimit, *global.RestRateBurst); !allowed {
+		writeTooManyRequests(w, wait)
+
+		return false
+	}
+
+	if key := findApiKey(r); key != nil {
+		if allowed, wait := keyLimiter.Allow(key.Name, *global.RestRateLimit, *global.RestRateBurst); !allowed {
+			writeTooManyRequests(w, wait)
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// Create a listener that accepts at most the configured number of concurrent connections.
+func createLimitListener(listen net.Listener) net.Listener {
+	if *global.RestMaxConnections == 0 {
+		return listen
+	}
+
+	return &limitListener{
+		Listener:  listen,
+		semaphore: make(chan struct{}, *global.RestMaxConnections),
+		closed:    make(chan struct{}),
+	}
+}
+
+// Wait for a free connection slot and accept a connection.
+func (listener *limitListener) Accept() (net.Conn, error) {
+	select {
+	case listener.semaphore <- struct{}{}:
+	case <-listener.closed:
+		return nil, net.ErrClosed
+	}
+
+	conn, err := listener.Listener.Accept()
+
+	if err != nil {
+		<-listener.semaphore
+
+		return nil, err
+	}
+
+	return &limitListenerConn{Conn: conn, semaphore: listener.semaphore}, nil
+}
+
+// Close the listener, including any Accept waiting for a free connection slot.
+func (listener *limitListener) Close() error {
+	listener.close.Do(func() {
+		close(listener.closed)
+	})
+
+	return listener.Listener.Close()
+}
+
+// Close the connection and release its slot.
+func (conn *limitListenerConn) Close() error {
+	err := conn.Conn.Close()
+
+	conn.release.Do(func() {
+		<-conn.semaphore
+	})
+
+	return err
+}
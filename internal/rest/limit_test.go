@@ -0,0 +1,245 @@
+package rest
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+// Set the lockout flags. The flags are restored when the test ends.
+func setLockout(t *testing.T, failures uint64, duration float64) {
+	restLockoutFailures := *global.RestLockoutFailures
+	restLockoutDuration := *global.RestLockoutDuration
+
+	t.Cleanup(func() {
+		*global.RestLockoutFailures = restLockoutFailures
+		*global.RestLockoutDuration = restLockoutDuration
+	})
+
+	*global.RestLockoutFailures = failures
+	*global.RestLockoutDuration = duration
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    float64
+		requests int
+		elapsed  time.Duration
+		allowed  int
+	}{
+		{"burst", 10, 3, 5, 0, 3},
+		{"fractional burst", 1, 2.5, 4, 0, 2},
+		{"refill", 10, 3, 5, 200 * time.Millisecond, 2},
+		{"refill is capped at the burst", 10, 3, 5, time.Hour, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := rateLimiter{}
+
+			// Empty the bucket and let it refill for the elapsed time.
+			if test.elapsed != 0 {
+				for ok := true; ok; ok, _ = limiter.Allow("client", test.rate, test.burst) {
+				}
+
+				limiter.Buckets["client"].LastRefill = time.Now().Add(-test.elapsed)
+			}
+
+			allowed := 0
+
+			for i := 0; i < test.requests; i++ {
+				if ok, _ := limiter.Allow("client", test.rate, test.burst); ok {
+					allowed++
+				}
+			}
+
+			if allowed != test.allowed {
+				t.Errorf("Allow() allowed %d of %d requests, want %d", allowed, test.requests, test.allowed)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := rateLimiter{}
+
+	if ok, _ := limiter.Allow("client", 2, 1); !ok {
+		t.Fatal("Allow() denied the first request")
+	}
+
+	ok, wait := limiter.Allow("client", 2, 1)
+
+	if ok || wait <= 0 || wait > 500*time.Millisecond {
+		t.Errorf("Allow() = %v, %v, want false, at most 500ms", ok, wait)
+	}
+
+	if ok, _ := limiter.Allow("other", 2, 1); !ok {
+		t.Error("Allow() denied the first request of another client")
+	}
+}
+
+func TestAuthLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures uint64
+		fails    int
+		reset    bool
+		elapsed  time.Duration
+		locked   bool
+	}{
+		{"below the limit", 3, 1, false, 0, false},
+		{"at the limit", 3, 2, false, 0, true},
+		{"disabled", 0, 10, false, 0, false},
+		{"reset after success", 3, 2, true, 0, false},
+		{"failures expire", 3, 2, false, 2 * time.Minute, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setLockout(t, test.failures, 60)
+			lockout := authLockout{}
+
+			for i := 0; i < test.fails; i++ {
+				lockout.Fail("client")
+			}
+
+			if test.reset {
+				lockout.Reset("client")
+			}
+
+			if test.elapsed != 0 {
+				lockout.Clients["client"].LastFailure = time.Now().Add(-test.elapsed)
+			}
+
+			lockout.Fail("client")
+			locked, wait := lockout.IsLocked("client")
+
+			if locked != test.locked {
+				t.Fatalf("IsLocked() = %v after %d failures, want %v", locked, test.fails+1, test.locked)
+			}
+
+			if locked && (wait <= 0 || wait > time.Minute) {
+				t.Errorf("IsLocked() wait = %v, want at most 1m", wait)
+			}
+
+			if other, _ := lockout.IsLocked("other"); other {
+				t.Error("IsLocked() locked out another client")
+			}
+		})
+	}
+}
+
+func TestLimitRequest(t *testing.T) {
+	setLockout(t, 1, 60)
+	client := "198.51.100.1"
+	lockout.Reset(client)
+
+	t.Cleanup(func() {
+		lockout.Reset(client)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/gps", nil)
+	r.RemoteAddr = client + ":1234"
+	w := httptest.NewRecorder()
+
+	if !limitRequest(w, r) {
+		t.Fatalf("limitRequest() limited the first request with status %d", w.Code)
+	}
+
+	lockout.Fail(client)
+	w = httptest.NewRecorder()
+
+	if limitRequest(w, r) || w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("limitRequest() of a locked out client has status %d and Retry-After %q, want %d and 60", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+}
+
+func TestLimitListener(t *testing.T) {
+	restMaxConnections := *global.RestMaxConnections
+
+	t.Cleanup(func() {
+		*global.RestMaxConnections = restMaxConnections
+	})
+
+	*global.RestMaxConnections = 1
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := createLimitListener(listen)
+	defer listener.Close()
+
+	for i := 0; i < 3; i++ {
+		client, err := net.Dial("tcp", listen.Addr().String())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer client.Close()
+	}
+
+	first, err := listener.Accept()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted := make(chan net.Conn)
+	failed := make(chan error)
+
+	accept := func() {
+		conn, err := listener.Accept()
+
+		if err != nil {
+			failed <- err
+		} else {
+			accepted <- conn
+		}
+	}
+
+	go accept()
+
+	select {
+	case <-accepted:
+		t.Fatal("Accept() did not wait for a free connection slot")
+	case err := <-failed:
+		t.Fatal(err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Close()
+
+	select {
+	case second := <-accepted:
+		defer second.Close()
+	case err := <-failed:
+		t.Fatalf("Accept() returned error %s after a slot was released", err)
+	case <-time.After(time.Second):
+		t.Fatal("Accept() did not accept after a slot was released")
+	}
+
+	go accept()
+	listener.Close()
+
+	select {
+	case conn := <-accepted:
+		conn.Close()
+		t.Error("Accept() accepted a connection after close")
+	case err := <-failed:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Accept() returned error %v after close, want %v", err, net.ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Accept() did not return after close")
+	}
+}
@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/metrics"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Write a single gauge in the Prometheus text exposition format.
+func writeGauge(w io.Writer, name string, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "%s %g\n", name, value)
+}
+
+// Write a single counter in the Prometheus text exposition format.
+func writeCounter(w io.Writer, name string, help string, value uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+// Write a counter with a single label in the Prometheus text exposition format.
+func writeCounterVec(w io.Writer, name string, help string, label string, values map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+
+	labelValues := make([]string, 0, len(values))
+
+	for labelValue := range values {
+		labelValues = append(labelValues, labelValue)
+	}
+
+	sort.Strings(labelValues)
+
+	for _, labelValue := range labelValues {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, labelValue, values[labelValue])
+	}
+}
+
+// Get the age of sensor data in seconds, or -1 if no data was received yet.
+func dataAge(lastUpdate time.Time) float64 {
+	if lastUpdate.IsZero() {
+		return -1
+	}
+
+	return time.Since(lastUpdate).Seconds()
+}
+
+// Handle metrics request.
+func handleMetricsRequest(w http.ResponseWriter, r *http.Request, gpsData *gps.Gps, motionData *motion.Motion) {
+	if !authorizeRead(w, r, ScopeMetricsRead) {
+		return
+	}
+
+	lastTpvUpdate, _, _, _, _, mode, _, _, _, eph, _, _, _, _, epv, _ := gpsData.GetTpv()
+	lastSkyUpdate, _, xdop, ydop, vdop, tdop, hdop, pdop, gdop, nsat, usat, _ := gpsData.GetSky()
+	lastMotionUpdate, x, y, z, _ := motionData.Get()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	if !prepareRequest(w, r, time.Now(), 0) {
+		return
+	}
+
+	writeGauge(w, "sensor_gps_mode", "GPS fix mode (0: unknown, 1: no fix, 2: 2D, 3: 3D).", float64(mode))
+	writeGauge(w, "sensor_gps_satellites_visible", "Number of visible GPS satellites.", float64(nsat))
+	writeGauge(w, "sensor_gps_satellites_used", "Number of GPS satellites used in the solution.", float64(usat))
+	writeGauge(w, "sensor_gps_xdop", "GPS longitudinal dilution of precision.", xdop)
+	writeGauge(w, "sensor_gps_ydop", "GPS latitudinal dilution of precision.", ydop)
+	writeGauge(w, "sensor_gps_vdop", "GPS vertical dilution of precision.", vdop)
+	writeGauge(w, "sensor_gps_tdop", "GPS time dilution of precision.", tdop)
+	writeGauge(w, "sensor_gps_hdop", "GPS horizontal dilution of precision.", hdop)
+	writeGauge(w, "sensor_gps_pdop", "GPS position dilution of precision.", pdop)
+	writeGauge(w, "sensor_gps_gdop", "GPS geometric dilution of precision.", gdop)
+	writeGauge(w, "sensor_gps_eph_meters", "GPS estimated horizontal position error.", eph)
+	writeGauge(w, "sensor_gps_epv_meters", "GPS estimated vertical position error.", epv)
+	writeGauge(w, "sensor_gps_tpv_age_seconds", "Seconds since the last GPS TPV report, or -1 if none was received.", dataAge(lastTpvUpdate))
+	writeGauge(w, "sensor_gps_sky_age_seconds", "Seconds since the last GPS SKY report, or -1 if none was received.", dataAge(lastSkyUpdate))
+	writeGauge(w, "sensor_motion_x_mg", "Motion sensor X acceleration.", float64(x))
+	writeGauge(w, "sensor_motion_y_mg", "Motion sensor Y acceleration.", float64(y))
+	writeGauge(w, "sensor_motion_z_mg", "Motion sensor Z acceleration.", float64(z))
+	writeGauge(w, "sensor_motion_age_seconds", "Seconds since the last motion sensor reading, or -1 if none was read.", dataAge(lastMotionUpdate))
+	writeCounterVec(w, "sensor_gpsd_reports_total", "Number of GPSd reports received per class.", "class", metrics.GpsdReports.Get())
+	writeCounter(w, "sensor_gpsd_parse_errors_total", "Number of GPSd reports that could not be parsed.", metrics.GpsdParseErrors.Get())
+	writeCounter(w, "sensor_can_frames_sent_total", "Number of CAN frames sent.", metrics.CanFramesSent.Get())
+	writeCounter(w, "sensor_can_frames_failed_total", "Number of CAN frames that failed to send.", metrics.CanFramesFailed.Get())
+	writeCounter(w, "sensor_motion_read_failures_total", "Number of failed motion sensor reads.", metrics.MotionReadFailures.Get())
+	writeCounter(w, "sensor_motion_parse_failures_total", "Number of motion sensor readings that could not be parsed.", metrics.MotionParseFailures.Get())
+	writeCounterVec(w, "sensor_http_requests_total", "Number of HTTP requests per status code.", "code", metrics.HttpRequests.Get())
+	writeCounterVec(w, "sensor_http_requests_by_key_total", "Number of authenticated HTTP requests per API key name.", "key", metrics.HttpRequestsByKey.Get())
+}
@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/movement"
+)
+
+// Struct to store the movement state.
+type MovementJson struct {
+	State          string  `json:"state" proto:"1"`
+	Duration       float64 `json:"duration" proto:"2"`
+	Variance       float64 `json:"variance" proto:"3"`
+	Speed          float64 `json:"speed" proto:"4"`
+	PositionFrozen bool    `json:"positionFrozen" proto:"5"`
+}
+
+// Handle movement request.
+func handleMovementRequest(w http.ResponseWriter, r *http.Request, data *movement.Movement, gpsData *gps.Gps) {
+	if !authorizeRead(w, r, ScopeMotionRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time {
+		lastUpdate, _, _, _, _ := data.Get()
+
+		return lastUpdate
+	})
+
+	lastUpdate, state, since, variance, speed := data.Get()
+	format, ok := negotiateFormat(w, r, MovementJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, time.Duration(*global.MovementWindow*float64(time.Second))) {
+		return
+	}
+
+	jsonData := MovementJson{
+		State:          state,
+		Duration:       time.Since(since).Seconds(),
+		Variance:       variance,
+		Speed:          speed,
+		PositionFrozen: gpsData.GetFrozen(),
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
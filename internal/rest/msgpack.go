@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Encode a value as MessagePack. Structs are encoded as maps using their JSON field names.
+func encodeMsgpack(value interface{}) []byte {
+	return appendMsgpack(nil, reflect.ValueOf(value))
+}
+
+// Append the MessagePack encoding of a value.
+func appendMsgpack(buffer []byte, value reflect.Value) []byte {
+	if !value.IsValid() {
+		return append(buffer, 0xc0)
+	}
+
+	if value.Type() == reflect.TypeOf(time.Time{}) {
+		return appendMsgpackString(buffer, value.Interface().(time.Time).Format(time.RFC3339Nano))
+	}
+
+	if fields, ok := value.Interface().(object); ok {
+		buffer = appendMsgpackHeader(buffer, len(fields), 0x80, 0xde, 0xdf)
+
+		for _, field := range fields {
+			buffer = appendMsgpackString(buffer, field.Name)
+			buffer = appendMsgpack(buffer, reflect.ValueOf(field.Value))
+		}
+
+		return buffer
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return append(buffer, 0xc0)
+		}
+
+		return appendMsgpack(buffer, value.Elem())
+	case reflect.Bool:
+		if value.Bool() {
+			return append(buffer, 0xc3)
+		}
+
+		return append(buffer, 0xc2)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(buffer, value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendMsgpackUint(buffer, value.Uint())
+	case reflect.Float32, reflect.Float64:
+		// Use the smaller float32 encoding if it represents the value exactly.
+		if float64(float32(value.Float())) == value.Float() {
+			return binary.BigEndian.AppendUint32(append(buffer, 0xca), math.Float32bits(float32(value.Float())))
+		}
+
+		return binary.BigEndian.AppendUint64(append(buffer, 0xcb), math.Float64bits(value.Float()))
+	case reflect.String:
+		return appendMsgpackString(buffer, value.String())
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return append(buffer, 0xc0)
+		}
+
+		buffer = appendMsgpackHeader(buffer, value.Len(), 0x90, 0xdc, 0xdd)
+
+		for i := 0; i < value.Len(); i++ {
+			buffer = appendMsgpack(buffer, value.Index(i))
+		}
+
+		return buffer
+	case reflect.Map:
+		if value.IsNil() {
+			return append(buffer, 0xc0)
+		}
+
+		keys := value.MapKeys()
+
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		buffer = appendMsgpackHeader(buffer, len(keys), 0x80, 0xde, 0xdf)
+
+		for _, key := range keys {
+			buffer = appendMsgpackString(buffer, key.String())
+			buffer = appendMsgpack(buffer, value.MapIndex(key))
+		}
+
+		return buffer
+	case reflect.Struct:
+		names := []string{}
+		fields := []reflect.Value{}
+
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+
+			if strings.Contains(options, "omitempty") && value.Field(i).IsZero() {
+				continue
+			}
+
+			if name == "" {
+				name = field.Name
+			}
+
+			names = append(names, name)
+			fields = append(fields, value.Field(i))
+		}
+
+		buffer = appendMsgpackHeader(buffer, len(names), 0x80, 0xde, 0xdf)
+
+		for i := range names {
+			buffer = appendMsgpackString(buffer, names[i])
+			buffer = appendMsgpack(buffer, fields[i])
+		}
+
+		return buffer
+	}
+
+	return append(buffer, 0xc0)
+}
+
+// Append a MessagePack array or map header.
+func appendMsgpackHeader(buffer []byte, length int, fix byte, prefix16 byte, prefix32 byte) []byte {
+	if length < 16 {
+		return append(buffer, fix|byte(length))
+	} else if length <= math.MaxUint16 {
+		return binary.BigEndian.AppendUint16(append(buffer, prefix16), uint16(length))
+	}
+
+	return binary.BigEndian.AppendUint32(append(buffer, prefix32), uint32(length))
+}
+
+// Append a MessagePack string.
+func appendMsgpackString(buffer []byte, value string) []byte {
+	length := len(value)
+
+	if length < 32 {
+		buffer = append(buffer, 0xa0|byte(length))
+	} else if length <= math.MaxUint8 {
+		buffer = append(buffer, 0xd9, byte(length))
+	} else if length <= math.MaxUint16 {
+		buffer = binary.BigEndian.AppendUint16(append(buffer, 0xda), uint16(length))
+	} else {
+		buffer = binary.BigEndian.AppendUint32(append(buffer, 0xdb), uint32(length))
+	}
+
+	return append(buffer, value...)
+}
+
+// Append a MessagePack signed integer using the smallest encoding.
+func appendMsgpackInt(buffer []byte, value int64) []byte {
+	if value >= 0 {
+		return appendMsgpackUint(buffer, uint64(value))
+	} else if value >= -32 {
+		return append(buffer, byte(value))
+	} else if value >= math.MinInt8 {
+		return append(buffer, 0xd0, byte(value))
+	} else if value >= math.MinInt16 {
+		return binary.BigEndian.AppendUint16(append(buffer, 0xd1), uint16(value))
+	} else if value >= math.MinInt32 {
+		return binary.BigEndian.AppendUint32(append(buffer, 0xd2), uint32(value))
+	}
+
+	return binary.BigEndian.AppendUint64(append(buffer, 0xd3), uint64(value))
+}
+
+// Append a MessagePack unsigned integer using the smallest encoding.
+func appendMsgpackUint(buffer []byte, value uint64) []byte {
+	if value < 128 {
+		return append(buffer, byte(value))
+	} else if value <= math.MaxUint8 {
+		return append(buffer, 0xcc, byte(value))
+	} else if value <= math.MaxUint16 {
+		return binary.BigEndian.AppendUint16(append(buffer, 0xcd), uint16(value))
+	} else if value <= math.MaxUint32 {
+		return binary.BigEndian.AppendUint32(append(buffer, 0xce), uint32(value))
+	}
+
+	return binary.BigEndian.AppendUint64(append(buffer, 0xcf), value)
+}
@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeMsgpack(t *testing.T) {
+	type message struct {
+		Lat      float64 `json:"lat"`
+		Name     string  `json:"name,omitempty"`
+		Ignored  int     `json:"-"`
+		internal int
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []byte
+	}{
+		{"nil", nil, []byte{0xc0}},
+		{"nil pointer", (*int)(nil), []byte{0xc0}},
+		{"true", true, []byte{0xc3}},
+		{"false", false, []byte{0xc2}},
+		{"positive fixint", 5, []byte{0x05}},
+		{"negative fixint", -1, []byte{0xff}},
+		{"int8", -33, []byte{0xd0, 0xdf}},
+		{"int16", -200, []byte{0xd1, 0xff, 0x38}},
+		{"int32", -70000, []byte{0xd2, 0xff, 0xfe, 0xee, 0x90}},
+		{"uint8", uint8(200), []byte{0xcc, 0xc8}},
+		{"uint16", uint16(1000), []byte{0xcd, 0x03, 0xe8}},
+		{"uint32", uint32(70000), []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{"uint64", uint64(1 << 40), []byte{0xcf, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"exact float32", 1.5, []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{"float64", 0.1, []byte{0xcb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{"fixstr", "abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{"str8", strings.Repeat("a", 32), append([]byte{0xd9, 0x20}, strings.Repeat("a", 32)...)},
+		{"time", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), append([]byte{0xb4}, "2024-01-02T03:04:05Z"...)},
+		{"fixarray", []int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{"array16", make([]int, 16), append([]byte{0xdc, 0x00, 0x10}, make([]byte, 16)...)},
+		{"nil slice", []int(nil), []byte{0xc0}},
+		{"map with sorted keys", map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+		{"struct with omitted fields", message{Lat: 1.5, Ignored: 1, internal: 1}, []byte{0x81, 0xa3, 'l', 'a', 't', 0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{"struct", message{Lat: 1.5, Name: "x"}, []byte{0x82, 0xa3, 'l', 'a', 't', 0xca, 0x3f, 0xc0, 0x00, 0x00, 0xa4, 'n', 'a', 'm', 'e', 0xa1, 'x'}},
+		{"ordered object", object{{"b", 2}, {"a", 1}}, []byte{0x82, 0xa1, 'b', 0x02, 0xa1, 'a', 0x01}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := encodeMsgpack(test.value); !bytes.Equal(got, test.want) {
+				t.Errorf("encodeMsgpack(%v) = % x, want % x", test.value, got, test.want)
+			}
+		})
+	}
+}
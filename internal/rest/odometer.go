@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+)
+
+// Struct to store the odometer counters. Distances are in kilometers and moving times in hours. The trip counter is
+// reset manually, the current trip when the next trip starts.
+type OdometerJson struct {
+	Total                 float64    `json:"total"`
+	MovingTime            float64    `json:"movingTime"`
+	Trip                  float64    `json:"trip"`
+	TripMovingTime        float64    `json:"tripMovingTime"`
+	TripReset             *time.Time `json:"tripReset,omitempty"`
+	CurrentTrip           float64    `json:"currentTrip"`
+	CurrentTripMovingTime float64    `json:"currentTripMovingTime"`
+	TripActive            bool       `json:"tripActive"`
+	TripStart             *time.Time `json:"tripStart,omitempty"`
+	TripEnd               *time.Time `json:"tripEnd,omitempty"`
+	Rejected              uint64     `json:"rejected"`
+}
+
+// Create the odometer resource of the odometer counters.
+func createOdometerJson(counters gps.Counters, rejected uint64) OdometerJson {
+	jsonData := OdometerJson{
+		Total:                 counters.Total / 1000,
+		MovingTime:            counters.MovingTime / 3600,
+		Trip:                  counters.Trip / 1000,
+		TripMovingTime:        counters.TripMovingTime / 3600,
+		CurrentTrip:           counters.CurrentTrip / 1000,
+		CurrentTripMovingTime: counters.CurrentTripMovingTime / 3600,
+		TripActive:            counters.TripActive,
+		Rejected:              rejected,
+	}
+
+	if !counters.TripReset.IsZero() {
+		jsonData.TripReset = &counters.TripReset
+	}
+
+	if !counters.TripStart.IsZero() {
+		jsonData.TripStart = &counters.TripStart
+	}
+
+	if !counters.TripEnd.IsZero() {
+		jsonData.TripEnd = &counters.TripEnd
+	}
+
+	return jsonData
+}
+
+// Handle odometer request. GET returns the odometer counters and requires the gps:read scope. DELETE resets the
+// trip counter and requires the admin scope and configured API keys.
+func handleOdometerRequest(w http.ResponseWriter, r *http.Request, odometer *gps.Odometer) {
+	switch r.Method {
+	case "OPTIONS":
+		setCorsHeaders(w)
+		w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, DELETE")
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		setCorsHeaders(w)
+		w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, DELETE")
+
+		if !authorizeChange(w, r) {
+			return
+		}
+
+		if err := odometer.ResetTrip(); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot save odometer: %s\n", err)
+			writeError(w, http.StatusInternalServerError, "Cannot save the odometer")
+
+			return
+		}
+
+		fmt.Printf("[%v] Trip counter reset\n", time.Now().UTC())
+
+		_, counters, rejected := odometer.Get()
+		jsonData := createOdometerJson(counters, rejected)
+
+		writeJson(w, &jsonData)
+	default:
+		if !authorizeRead(w, r, ScopeGpsRead) {
+			return
+		}
+
+		waitForUpdate(r, func() time.Time {
+			lastUpdate, _, _ := odometer.Get()
+
+			return lastUpdate
+		})
+
+		lastUpdate, counters, rejected := odometer.Get()
+		format, ok := negotiateFormat(w, r, OdometerJson{})
+
+		if !ok {
+			return
+		}
+
+		if !prepareRequest(w, r, lastUpdate, 0) {
+			return
+		}
+
+		jsonData := createOdometerJson(counters, rejected)
+
+		writeFormat(w, r, format, &jsonData)
+	}
+}
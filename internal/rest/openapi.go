@@ -0,0 +1,435 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const apiVersionPrefix = "/v1"
+
+// Struct to store the documentation of a REST route.
+type routeDoc struct {
+	Path        string
+	Summary     string
+	Scopes      []string
+	Response    interface{}
+	ContentType string
+	Changes     []changeDoc
+}
+
+// Struct to store the documentation of a method that changes a REST resource. Changes require the admin scope and
+// return the changed resource.
+type changeDoc struct {
+	Method      string
+	Summary     string
+	Request     interface{}
+	ContentType string
+	Parameters  []interface{}
+	Errors      map[string]string
+}
+
+// Documentation of all REST routes. Response schemas are generated from the response structs.
+var routeDocs = []routeDoc{
+	{Path: "/sensors", Summary: "Get all GPS and motion sensor data.", Scopes: []string{ScopeGpsRead, ScopeMotionRead}, Response: SensorsJson{}},
+	{Path: "/sensors/gps", Summary: "Get GPS TPV and SKY report data.", Scopes: []string{ScopeGpsRead}, Response: GpsJson{}},
+	{Path: "/sensors/gps/tpv", Summary: "Get GPS TPV (time, position, velocity) report data.", Scopes: []string{ScopeGpsRead}, Response: TpvJson{}},
+	{Path: "/sensors/gps/sky", Summary: "Get GPS SKY report data, including satellites.", Scopes: []string{ScopeGpsRead}, Response: SkyJson{}},
+	{Path: "/sensors/gps/antenna", Summary: "Get the GPS antenna position [°, m] before the lever arm correction, whether the lever arm is applied, the heading, pitch, and roll [°] used to rotate it, and the lever arm [m] rotated to east, north, and up. The other endpoints report the position of the control point once the lever arm is applied. Only available if a lever arm is configured.", Scopes: []string{ScopeGpsRead}, Response: AntennaJson{}},
+	{Path: "/sensors/gps/coordinates", Summary: "Get the GPS position as UTM zone, band, easting, and northing [m], MGRS grid reference, ECEF X, Y, and Z [m], and ENU east, north, and up [m] relative to the ENU origin. Use ?fields=utm,mgrs to select coordinate systems, and ?mgrsDigits=<0-5> to set the MGRS precision (default 5 for 1 m). UTM and MGRS are omitted outside of 80°S to 84°N, and ENU is omitted if no ENU origin is configured.", Scopes: []string{ScopeGpsRead}, Response: CoordinatesJson{}},
+	{Path: "/sensors/gps/filtered", Summary: "Get the Kalman filtered position [°, m], speed [m/s], heading [°], velocity [m/s] east, north, and up, and the estimated errors at 95% confidence. The raw GPS data is not changed. Only available if the Kalman filter is enabled.", Scopes: []string{ScopeGpsRead}, Response: FilteredJson{}},
+	{Path: "/sensors/motion", Summary: "Get motion sensor data.", Scopes: []string{ScopeMotionRead}, Response: MotionJson{}},
+	{Path: "/sensors/motion/orientation", Summary: "Get the static pitch and roll [°] computed from gravity. Pitch is positive nose up, roll is positive right side down.", Scopes: []string{ScopeMotionRead}, Response: OrientationJson{}},
+	{Path: "/sensors/motion/calibration", Summary: "Get the motion sensor calibration status.", Scopes: []string{ScopeMotionRead}, Response: CalibrationJson{}, Changes: []changeDoc{
+		{
+			Method:  "POST",
+			Summary: "Calibrate the stationary motion sensor. The level method computes the offsets on a level surface. The six-orientation method samples one orientation per request and computes the offsets and scales once all six are sampled.",
+			Request: CalibrationRequestJson{},
+			Errors:  map[string]string{"409": "Motion sensor cannot be read, is not stationary, or is not aligned with an axis"},
+		},
+		{
+			Method:  "DELETE",
+			Summary: "Remove the motion sensor calibration.",
+			Errors:  map[string]string{"500": "Cannot remove the calibration"},
+		},
+	}},
+	{Path: "/sensors/motion/vibration", Summary: "Get the vibration analysis of the last window of motion samples in vehicle axes: RMS, peak-to-peak [mg], crest factor, dominant frequency [Hz], and the amplitude spectrum [mg] from 0 Hz to half the sample rate in steps of the resolution [Hz]. Only available if vibration analysis is enabled.", Scopes: []string{ScopeMotionRead}, Response: VibrationJson{}},
+	{Path: "/geofences", Summary: "Get the geofences as a GeoJSON FeatureCollection. Only available if geofencing is enabled.", Scopes: []string{ScopeGpsRead}, ContentType: "application/geo+json", Changes: []changeDoc{
+		{
+			Method:      "PUT",
+			Summary:     "Replace the geofences with a GeoJSON FeatureCollection of Polygon, MultiPolygon, and Point features. Points are circles with a radius [m] property. The name property names a geofence. The geofences are saved to the geofence file.",
+			ContentType: "application/geo+json",
+			Errors:      map[string]string{"400": "Invalid GeoJSON", "413": "GeoJSON is too large", "500": "Cannot save the geofences"},
+		},
+	}},
+	{Path: "/geofences/events", Summary: "Get whether the vehicle is inside each geofence and the geofence entry and exit events. Use ?since=<id> to only get newer events. Only available if geofencing is enabled.", Scopes: []string{ScopeGpsRead}, Response: GeofenceEventsJson{}},
+	{Path: "/interference", Summary: "Get the GNSS interference status (none, suspected, or likely), the active indicators (cn0Drop, uniformCn0, jump, or timeDiscontinuity), the mean, baseline, and standard deviation of the C/N0 [dB-Hz], and the interference events with the details of the active indicators as evidence. Use ?since=<id> to only get newer events. Only available if interference detection is enabled.", Scopes: []string{ScopeGpsRead}, Response: InterferenceJson{}},
+	{Path: "/overspeed", Summary: "Get whether the vehicle is speeding, the speed [m/s], the active speed limit [m/s] and the geofence that sets it, the number and total duration [s] of overspeeds, and the overspeed start and end events with the maximum speed [m/s] and duration [s]. Use ?since=<id> to only get newer events. Only available if overspeed detection is enabled.", Scopes: []string{ScopeGpsRead}, Response: OverspeedJson{}},
+	{Path: "/odometer", Summary: "Get the total odometer, trip counter, and current trip distance [km], moving times [h], trip status, and the number of rejected GPS position jumps. The trip counter is only reset manually, the current trip is reset when the next trip starts. Only available if the odometer is enabled.", Scopes: []string{ScopeGpsRead}, Response: OdometerJson{}, Changes: []changeDoc{
+		{
+			Method:  "DELETE",
+			Summary: "Reset the trip counter. The total odometer and the current trip cannot be reset.",
+			Errors:  map[string]string{"500": "Cannot save the odometer"},
+		},
+	}},
+	{Path: "/movement", Summary: "Get whether the vehicle is stationary or moving, the duration [s] since the last state change, the total variance [mg²] of the last window of motion samples, the GPS speed [m/s], and whether the reported position is frozen. Only available if movement detection is enabled.", Scopes: []string{ScopeMotionRead}, Response: MovementJson{}},
+	{Path: "/events", Summary: "Get the detected impacts and harsh driving events with the GPS position and motion samples [mg] around the event. Use ?since=<id> to only get newer events. Only available if event detection is enabled.", Scopes: []string{ScopeMotionRead}, Response: EventsJson{}},
+	{Path: "/config", Summary: "Get the effective configuration. Secrets are redacted.", Scopes: []string{ScopeAdmin}, Response: ConfigJson{}, Changes: []changeDoc{
+		{
+			Method:  "PATCH",
+			Summary: "Change runtime settings, e.g. {\"can-frequency\": 2, \"lat-frame-id\": 300}. Only the runtime settings can be changed.",
+			Request: map[string]interface{}{},
+			Parameters: []interface{}{
+				map[string]interface{}{
+					"name":        "persist",
+					"in":          "query",
+					"description": "Persist the changes to the configuration file.",
+					"schema":      map[string]interface{}{"type": "boolean"},
+				},
+			},
+			Errors: map[string]string{"500": "Cannot persist the configuration"},
+		},
+	}},
+	{Path: "/health", Summary: "Get the health of all subsystems. No API key is required.", Response: HealthJson{}},
+	{Path: "/metrics", Summary: "Get operational metrics in the Prometheus text format.", Scopes: []string{ScopeMetricsRead}, ContentType: "text/plain"},
+	{Path: "/openapi.json", Summary: "Get this OpenAPI document. No API key is required.", ContentType: "application/json"},
+	{Path: "/sensor.proto", Summary: "Get the Protocol Buffers schema of all resources that support Protocol Buffers. No API key is required.", ContentType: "text/plain"},
+}
+
+// Create the JSON schema of a type. Structs are added to the schema components and referenced.
+func createSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32", "minimum": 0}
+	case reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": createSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": createSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			properties := map[string]interface{}{}
+			required := []string{}
+
+			// Reserve the name first to support recursive structs.
+			schemas[t.Name()] = nil
+
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				tag := field.Tag.Get("json")
+				name, options, _ := strings.Cut(tag, ",")
+
+				if !field.IsExported() || name == "-" {
+					continue
+				}
+
+				if name == "" {
+					name = field.Name
+				}
+
+				properties[name] = createSchema(field.Type, schemas)
+
+				if !strings.Contains(options, "omitempty") {
+					required = append(required, name)
+				}
+			}
+
+			schemas[t.Name()] = map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+				"required":   required,
+			}
+		}
+
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+
+	return map[string]interface{}{}
+}
+
+// Create the response documentation of an error status.
+func createErrorResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorJson"},
+			},
+		},
+	}
+}
+
+// Create the operation ID of a route, e.g. getSensorsGpsTpv for /sensors/gps/tpv.
+func createOperationId(path string) string {
+	operationId := "get"
+
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '.' }) {
+		operationId += strings.ToUpper(part[:1]) + part[1:]
+	}
+
+	return operationId
+}
+
+// Check if a response type contains satellites, which can be filtered and sorted.
+func hasSatellites(t reflect.Type) bool {
+	return hasSelectedField(t, nil, [][]string{{"satellites"}})
+}
+
+// Create the documentation of the satellite filter and sort query parameters.
+func createSatelliteParameters() []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"name":        "gnssid",
+			"in":          "query",
+			"description": "Only include satellites of these comma separated GNSS IDs, e.g. 2 for Galileo.",
+			"schema":      map[string]interface{}{"type": "string"},
+		},
+		map[string]interface{}{
+			"name":        "used",
+			"in":          "query",
+			"description": "Only include satellites that are used or not used in the solution.",
+			"schema":      map[string]interface{}{"type": "boolean"},
+		},
+		map[string]interface{}{
+			"name":        "minEl",
+			"in":          "query",
+			"description": "Only include satellites with at least this elevation in degrees.",
+			"schema":      map[string]interface{}{"type": "number"},
+		},
+		map[string]interface{}{
+			"name":        "minSs",
+			"in":          "query",
+			"description": "Only include satellites with at least this signal strength in dBHz.",
+			"schema":      map[string]interface{}{"type": "number"},
+		},
+		map[string]interface{}{
+			"name":        "sort",
+			"in":          "query",
+			"description": "Sort satellites by field, prefix with - for descending, e.g. -ss for strongest signal first.",
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"prn", "-prn", "az", "-az", "el", "-el", "ss", "-ss", "gnssid", "-gnssid"}},
+		},
+	}
+}
+
+// Create the documentation of an operation that changes a resource.
+func createChangeOperation(route routeDoc, change changeDoc, content map[string]interface{}, schemas map[string]interface{}) map[string]interface{} {
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "OK",
+			"content":     content,
+		},
+		"401": createErrorResponse("Missing, wrong, or expired API key"),
+		"403": createErrorResponse("API key lacks the admin scope, or no API keys are configured"),
+		"405": createErrorResponse("Method not allowed"),
+		"429": createErrorResponse("Too many requests"),
+	}
+
+	for status, description := range change.Errors {
+		responses[status] = createErrorResponse(description)
+	}
+
+	operation := map[string]interface{}{
+		"summary":     change.Summary,
+		"description": "Required API key scopes: " + ScopeAdmin + ".",
+		"operationId": strings.ToLower(change.Method) + strings.TrimPrefix(createOperationId(route.Path), "get"),
+		"security": []interface{}{
+			map[string]interface{}{"ApiKeyHeader": []string{}},
+			map[string]interface{}{"BearerAuth": []string{}},
+		},
+		"responses": responses,
+	}
+
+	if change.Parameters != nil {
+		operation["parameters"] = change.Parameters
+	}
+
+	if change.Request != nil {
+		responses["400"] = createErrorResponse("Invalid JSON body")
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": createSchema(reflect.TypeOf(change.Request), schemas),
+				},
+			},
+		}
+	} else if change.ContentType != "" {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				change.ContentType: map[string]interface{}{},
+			},
+		}
+	}
+
+	return operation
+}
+
+// Create the OpenAPI 3 document of all REST routes.
+func createOpenApi(version string) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+
+	createSchema(reflect.TypeOf(ErrorJson{}), schemas)
+
+	for _, route := range routeDocs {
+		content := map[string]interface{}{}
+
+		if route.Response != nil {
+			content["application/json"] = map[string]interface{}{
+				"schema": createSchema(reflect.TypeOf(route.Response), schemas),
+			}
+
+			for _, format := range []string{FormatCsv, FormatMsgpack, FormatProtobuf} {
+				if len(route.Scopes) > 0 && supportsFormat(format, route.Response) {
+					content[formatContentTypes[format]] = map[string]interface{}{}
+				}
+			}
+		} else {
+			content[route.ContentType] = map[string]interface{}{}
+		}
+
+		responses := map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "OK",
+				"content":     content,
+			},
+			"405": createErrorResponse("Method not allowed"),
+			"429": createErrorResponse("Too many requests"),
+		}
+
+		operation := map[string]interface{}{
+			"summary":   route.Summary,
+			"responses": responses,
+		}
+
+		if len(route.Scopes) > 0 {
+			if route.Changes == nil {
+				responses["204"] = map[string]interface{}{"description": "No sensor data available yet"}
+			}
+
+			responses["401"] = createErrorResponse("Missing, wrong, or expired API key")
+			responses["403"] = createErrorResponse("API key lacks a required scope")
+			operation["description"] = "Required API key scopes: " + strings.Join(route.Scopes, ", ") + "."
+			operation["security"] = []interface{}{
+				map[string]interface{}{"ApiKeyHeader": []string{}},
+				map[string]interface{}{"BearerAuth": []string{}},
+			}
+		} else {
+			operation["security"] = []interface{}{}
+		}
+
+		if len(route.Scopes) > 0 && route.Response != nil && route.Changes == nil {
+			responses["406"] = createErrorResponse("Requested format is not supported by this resource")
+			responses["304"] = map[string]interface{}{"description": "Not modified since the sample of the If-None-Match ETag or the If-Modified-Since time"}
+			responses["400"] = createErrorResponse("Unknown field or invalid filter")
+			parameters := []interface{}{
+				map[string]interface{}{
+					"name":        "fields",
+					"in":          "query",
+					"description": "Comma separated fields to include, e.g. tpv.lat,tpv.lon,motion. A field matches by its path suffix.",
+					"schema":      map[string]interface{}{"type": "string"},
+				},
+				map[string]interface{}{
+					"name":        "format",
+					"in":          "query",
+					"description": "Response format, overriding the Accept header. Not every resource supports every format.",
+					"schema":      map[string]interface{}{"type": "string", "enum": []string{FormatJson, FormatCsv, FormatMsgpack, FormatProtobuf}},
+				},
+				map[string]interface{}{
+					"name":        "wait",
+					"in":          "query",
+					"description": "Long-poll duration, e.g. 5s. Blocks until a newer sample than the If-None-Match ETag exists.",
+					"schema":      map[string]interface{}{"type": "string"},
+				},
+				map[string]interface{}{
+					"name":   "If-None-Match",
+					"in":     "header",
+					"schema": map[string]interface{}{"type": "string"},
+				},
+			}
+
+			if hasSatellites(reflect.TypeOf(route.Response)) {
+				parameters = append(parameters, createSatelliteParameters()...)
+			}
+
+			operation["parameters"] = parameters
+		}
+
+		if route.Path == "/health" {
+			responses["503"] = map[string]interface{}{
+				"description": "One or more subsystems are unhealthy",
+				"content":     content,
+			}
+		}
+
+		operation["operationId"] = createOperationId(route.Path)
+		paths[apiVersionPrefix+route.Path] = map[string]interface{}{"get": operation}
+
+		for _, change := range route.Changes {
+			paths[apiVersionPrefix+route.Path].(map[string]interface{})[strings.ToLower(change.Method)] = createChangeOperation(route, change, content, schemas)
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "TCG4 sensor REST API",
+			"description": "GPS and motion sensor data of the TCG4. Unversioned routes are kept as aliases of the v1 routes.",
+			"version":     version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"ApiKeyHeader": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"BearerAuth":   map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// Handle openapi.json request. No API key is required, so clients can be generated from it.
+func handleOpenApiRequest(w http.ResponseWriter, r *http.Request, version string) {
+	setCorsHeaders(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	} else if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	jsonString, _ := json.Marshal(createOpenApi(version))
+	fmt.Fprint(w, string(jsonString))
+}
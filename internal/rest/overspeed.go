@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/overspeed"
+)
+
+// Struct to store the overspeed summary and the overspeed start and end events.
+type OverspeedJson struct {
+	Active        bool                 `json:"active"`
+	Speed         float64              `json:"speed"`
+	Limit         float64              `json:"limit"`
+	Zone          string               `json:"zone"`
+	Start         *time.Time           `json:"start,omitempty"`
+	MaxSpeed      float64              `json:"maxSpeed"`
+	Count         uint64               `json:"count"`
+	TotalDuration float64              `json:"totalDuration"`
+	Events        []OverspeedEventJson `json:"events"`
+}
+
+// Struct to store an overspeed start or end.
+type OverspeedEventJson struct {
+	Id       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	MaxSpeed float64   `json:"maxSpeed"`
+	Limit    float64   `json:"limit"`
+	Zone     string    `json:"zone"`
+	Duration float64   `json:"duration"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+}
+
+// Handle overspeed request. Use ?since=<id> to only get events after a known event.
+func handleOverspeedRequest(w http.ResponseWriter, r *http.Request, detector *overspeed.Detector) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+
+	if err != nil && r.URL.Query().Get("since") != "" {
+		writeError(w, http.StatusBadRequest, "Invalid since "+r.URL.Query().Get("since"))
+
+		return
+	}
+
+	waitForUpdate(r, func() time.Time {
+		lastUpdate, _ := detector.Get()
+
+		return lastUpdate
+	})
+
+	lastUpdate, status := detector.Get()
+	format, ok := negotiateFormat(w, r, OverspeedJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, 0) {
+		return
+	}
+
+	jsonData := OverspeedJson{
+		Active:        status.Active,
+		Speed:         status.Speed,
+		Limit:         status.Limit,
+		Zone:          status.Zone,
+		MaxSpeed:      status.MaxSpeed,
+		Count:         status.Count,
+		TotalDuration: status.TotalDuration,
+		Events:        []OverspeedEventJson{},
+	}
+
+	if status.Active {
+		jsonData.Start = &status.Start
+	}
+
+	for _, event := range detector.GetSince(since) {
+		jsonData.Events = append(jsonData.Events, OverspeedEventJson{
+			Id:       event.Id,
+			Type:     event.Type,
+			Time:     event.Time,
+			MaxSpeed: event.MaxSpeed,
+			Limit:    event.Limit,
+			Zone:     event.Zone,
+			Duration: event.Duration,
+			Lat:      event.Lat,
+			Lon:      event.Lon,
+		})
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
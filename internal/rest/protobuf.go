@@ -0,0 +1,231 @@
+package rest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const protobufPackage = "tcg4.sensor.v1"
+
+// Protocol Buffers wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// Get the Protocol Buffers message name of a struct, e.g. Tpv for TpvJson.
+func protobufMessageName(t reflect.Type) string {
+	return strings.TrimSuffix(t.Name(), "Json")
+}
+
+// Get the Protocol Buffers field number from the proto struct tag, or 0 if not set.
+func protobufFieldNumber(field reflect.StructField) int {
+	number, err := strconv.Atoi(field.Tag.Get("proto"))
+
+	if err != nil {
+		return 0
+	}
+
+	return number
+}
+
+// Check if a type can be encoded as Protocol Buffers. All exported struct fields need a proto tag.
+func supportsProtobuf(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if protobufFieldNumber(field) == 0 {
+			return false
+		}
+
+		fieldType := field.Type
+
+		if fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if fieldType.Kind() == reflect.Struct && !supportsProtobuf(fieldType) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Encode a struct as a Protocol Buffers message.
+func encodeProtobuf(value interface{}) []byte {
+	return appendProtobufMessage(nil, reflect.Indirect(reflect.ValueOf(value)))
+}
+
+// Append the fields of a struct as a Protocol Buffers message. Zero values are omitted as in proto3.
+func appendProtobufMessage(buffer []byte, value reflect.Value) []byte {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		number := protobufFieldNumber(field)
+
+		if !field.IsExported() || number == 0 {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Slice {
+			for j := 0; j < value.Field(i).Len(); j++ {
+				buffer = appendProtobufField(buffer, number, value.Field(i).Index(j), true)
+			}
+		} else {
+			buffer = appendProtobufField(buffer, number, value.Field(i), false)
+		}
+	}
+
+	return buffer
+}
+
+// Append a single Protocol Buffers field. Repeated fields and set pointers are always written, nil pointers are
+// omitted.
+func appendProtobufField(buffer []byte, number int, value reflect.Value, repeated bool) []byte {
+	if !repeated && value.IsZero() {
+		return buffer
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		buffer = binary.AppendUvarint(buffer, uint64(number<<3|wireVarint))
+
+		if value.Bool() {
+			return append(buffer, 1)
+		}
+
+		return append(buffer, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buffer = binary.AppendUvarint(buffer, uint64(number<<3|wireVarint))
+
+		return binary.AppendVarint(buffer, value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buffer = binary.AppendUvarint(buffer, uint64(number<<3|wireVarint))
+
+		return binary.AppendUvarint(buffer, value.Uint())
+	case reflect.Float32, reflect.Float64:
+		buffer = binary.AppendUvarint(buffer, uint64(number<<3|wireFixed64))
+
+		return binary.LittleEndian.AppendUint64(buffer, math.Float64bits(value.Float()))
+	case reflect.String:
+		buffer = binary.AppendUvarint(buffer, uint64(number<<3|wireBytes))
+		buffer = binary.AppendUvarint(buffer, uint64(value.Len()))
+
+		return append(buffer, value.String()...)
+	case reflect.Pointer:
+		return appendProtobufField(buffer, number, value.Elem(), true)
+	case reflect.Struct:
+		message := appendProtobufMessage(nil, value)
+		buffer = binary.AppendUvarint(buffer, uint64(number<<3|wireBytes))
+		buffer = binary.AppendUvarint(buffer, uint64(len(message)))
+
+		return append(buffer, message...)
+	}
+
+	return buffer
+}
+
+// Get the Protocol Buffers scalar type of a Go type. Signed integers use the zigzag encoded sint types.
+func protobufTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return "sint32"
+	case reflect.Int, reflect.Int64:
+		return "sint64"
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "uint32"
+	case reflect.Uint, reflect.Uint64:
+		return "uint64"
+	case reflect.Float32, reflect.Float64:
+		return "double"
+	case reflect.String:
+		return "string"
+	case reflect.Struct:
+		return protobufMessageName(t)
+	}
+
+	return "bytes"
+}
+
+// Append the Protocol Buffers message definition of a struct and all nested structs.
+func appendProtobufSchema(builder *strings.Builder, t reflect.Type, defined map[string]bool) {
+	name := protobufMessageName(t)
+
+	if defined[name] {
+		return
+	}
+
+	defined[name] = true
+	nested := []reflect.Type{}
+
+	fmt.Fprintf(builder, "\nmessage %s {\n", name)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		number := protobufFieldNumber(field)
+
+		if !field.IsExported() || number == 0 {
+			continue
+		}
+
+		fieldName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		fieldType := field.Type
+		label := ""
+
+		if fieldType.Kind() == reflect.Slice {
+			fieldType = fieldType.Elem()
+			label = "repeated "
+		} else if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if fieldType.Kind() == reflect.Struct {
+			nested = append(nested, fieldType)
+		}
+
+		fmt.Fprintf(builder, "  %s%s %s = %d;\n", label, protobufTypeName(fieldType), fieldName, number)
+	}
+
+	builder.WriteString("}\n")
+
+	for _, nestedType := range nested {
+		appendProtobufSchema(builder, nestedType, defined)
+	}
+}
+
+// Create the Protocol Buffers schema of all resources that support Protocol Buffers.
+func createProtobufSchema() string {
+	builder := &strings.Builder{}
+	defined := map[string]bool{}
+
+	builder.WriteString("// Protocol Buffers schema of the TCG4 sensor REST API, generated from the REST response structs.\n")
+	builder.WriteString("syntax = \"proto3\";\n\n")
+	fmt.Fprintf(builder, "package %s;\n", protobufPackage)
+
+	for _, route := range routeDocs {
+		if route.Response != nil && supportsProtobuf(reflect.TypeOf(route.Response)) {
+			appendProtobufSchema(builder, reflect.TypeOf(route.Response), defined)
+		}
+	}
+
+	return builder.String()
+}
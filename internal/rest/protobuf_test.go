@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type testChildJson struct {
+	Value uint `proto:"1"`
+}
+
+type testMessageJson struct {
+	Flag     bool           `proto:"1"`
+	Count    int            `proto:"2"`
+	Size     uint           `proto:"3"`
+	Value    float64        `proto:"4"`
+	Name     string         `proto:"5"`
+	Items    []uint         `proto:"6"`
+	Child    *testChildJson `proto:"7"`
+	internal int
+}
+
+func TestEncodeProtobuf(t *testing.T) {
+	tests := []struct {
+		name  string
+		value testMessageJson
+		want  []byte
+	}{
+		{"zero values are omitted", testMessageJson{internal: 1}, []byte{}},
+		{"bool", testMessageJson{Flag: true}, []byte{0x08, 0x01}},
+		{"zigzag int", testMessageJson{Count: -2}, []byte{0x10, 0x03}},
+		{"varint uint", testMessageJson{Size: 300}, []byte{0x18, 0xac, 0x02}},
+		{"double", testMessageJson{Value: 1}, []byte{0x21, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f}},
+		{"string", testMessageJson{Name: "hi"}, []byte{0x2a, 0x02, 'h', 'i'}},
+		{"repeated with zero", testMessageJson{Items: []uint{0, 1}}, []byte{0x30, 0x00, 0x30, 0x01}},
+		{"sub-message", testMessageJson{Child: &testChildJson{Value: 1}}, []byte{0x3a, 0x02, 0x08, 0x01}},
+		{"empty sub-message", testMessageJson{Child: &testChildJson{}}, []byte{0x3a, 0x00}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := encodeProtobuf(&test.value); !bytes.Equal(got, test.want) {
+				t.Errorf("encodeProtobuf(%+v) = % x, want % x", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSupportsProtobuf(t *testing.T) {
+	type untagged struct {
+		Value int
+	}
+
+	type untaggedChild struct {
+		Child *untagged `proto:"1"`
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  bool
+	}{
+		{"tagged struct", testMessageJson{}, true},
+		{"pointer to tagged struct", &testMessageJson{}, true},
+		{"untagged field", untagged{}, false},
+		{"untagged sub-message", untaggedChild{}, false},
+		{"not a struct", []int{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := supportsProtobuf(reflect.TypeOf(test.value)); got != test.want {
+				t.Errorf("supportsProtobuf(%T) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+}
@@ -2,135 +2,262 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/vuhuy/tcg4-sensor/internal/can"
+	"github.com/vuhuy/tcg4-sensor/internal/events"
+	"github.com/vuhuy/tcg4-sensor/internal/filter"
+	"github.com/vuhuy/tcg4-sensor/internal/geo"
+	"github.com/vuhuy/tcg4-sensor/internal/geofence"
 	"github.com/vuhuy/tcg4-sensor/internal/global"
 	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/interference"
+	"github.com/vuhuy/tcg4-sensor/internal/leverarm"
+	"github.com/vuhuy/tcg4-sensor/internal/metrics"
 	"github.com/vuhuy/tcg4-sensor/internal/motion"
+	"github.com/vuhuy/tcg4-sensor/internal/movement"
+	"github.com/vuhuy/tcg4-sensor/internal/overspeed"
 	"github.com/vuhuy/tcg4-sensor/pkg/gpsd"
 )
 
-// Struct to store REST server data.
-type Rest struct{}
+// Struct to store REST server data. Optional subsystems are nil when disabled.
+type Rest struct {
+	Version      string
+	StartTime    time.Time
+	Events       *events.Detector
+	Vibration    *motion.Vibration
+	Movement     *movement.Movement
+	Filter       *filter.Filter
+	Odometer     *gps.Odometer
+	Geofences    *geofence.Geofences
+	Overspeed    *overspeed.Detector
+	Origin       *geo.Origin
+	Interference *interference.Detector
+	LeverArm     *leverarm.LeverArm
+}
 
 // Struct to store sensor data.
 type SensorsJson struct {
-	Gps    GpsJson    `json:"gps"`
-	Motion MotionJson `json:"motion"`
+	Gps    GpsJson    `json:"gps" proto:"1"`
+	Motion MotionJson `json:"motion" proto:"2"`
 }
 
 // Struct to store GPS data.
 type GpsJson struct {
-	Tpv TpvJson `json:"tpv"`
-	Sky SkyJson `json:"sky"`
+	Tpv TpvJson `json:"tpv" proto:"1"`
+	Sky SkyJson `json:"sky" proto:"2"`
 }
 
 // Struct store GPS TPV report data.
 type TpvJson struct {
-	Lat    float64 `json:"lat"`
-	Lon    float64 `json:"lon"`
-	Alt    float64 `json:"alt"`
-	Speed  float64 `json:"speed"`
-	Mode   uint8   `json:"mode"`
-	Status uint8   `json:"status"`
-	Epc    float64 `json:"epc"`
-	Epd    float64 `json:"epd"`
-	Eph    float64 `json:"eph"`
-	Eps    float64 `json:"eps"`
-	Ept    float64 `json:"ept"`
-	Epx    float64 `json:"epx"`
-	Epy    float64 `json:"epy"`
-	Epv    float64 `json:"epv"`
-	Sep    float64 `json:"sep"`
+	Lat    float64 `json:"lat" proto:"1"`
+	Lon    float64 `json:"lon" proto:"2"`
+	Alt    float64 `json:"alt" proto:"3"`
+	Speed  float64 `json:"speed" proto:"4"`
+	Mode   uint8   `json:"mode" proto:"5"`
+	Status uint8   `json:"status" proto:"6"`
+	Epc    float64 `json:"epc" proto:"7"`
+	Epd    float64 `json:"epd" proto:"8"`
+	Eph    float64 `json:"eph" proto:"9"`
+	Eps    float64 `json:"eps" proto:"10"`
+	Ept    float64 `json:"ept" proto:"11"`
+	Epx    float64 `json:"epx" proto:"12"`
+	Epy    float64 `json:"epy" proto:"13"`
+	Epv    float64 `json:"epv" proto:"14"`
+	Sep    float64 `json:"sep" proto:"15"`
 }
 
 // Struct to store GPS SKY report data.
 type SkyJson struct {
-	Qual       uint8           `json:"qual"`
-	Xdop       float64         `json:"xdop"`
-	Ydop       float64         `json:"ydop"`
-	Vdop       float64         `json:"vdop"`
-	Tdop       float64         `json:"tdop"`
-	Hdop       float64         `json:"hdop"`
-	Pdop       float64         `json:"pdop"`
-	Gdop       float64         `json:"gdop"`
-	Nsat       uint16          `json:"nsat"`
-	Usat       uint16          `json:"usat"`
-	Satellites []SatelliteJson `json:"satellites"`
+	Qual       uint8           `json:"qual" proto:"1"`
+	Xdop       float64         `json:"xdop" proto:"2"`
+	Ydop       float64         `json:"ydop" proto:"3"`
+	Vdop       float64         `json:"vdop" proto:"4"`
+	Tdop       float64         `json:"tdop" proto:"5"`
+	Hdop       float64         `json:"hdop" proto:"6"`
+	Pdop       float64         `json:"pdop" proto:"7"`
+	Gdop       float64         `json:"gdop" proto:"8"`
+	Nsat       uint16          `json:"nsat" proto:"9"`
+	Usat       uint16          `json:"usat" proto:"10"`
+	Satellites []SatelliteJson `json:"satellites" proto:"11"`
 }
 
 // Struct to store GPS satellite data.
 type SatelliteJson struct {
-	Prn    float64 `json:"prn"`
-	Az     float64 `json:"az"`
-	El     float64 `json:"el"`
-	Ss     float64 `json:"ss"`
-	Gnssid uint8   `json:"gnssid"`
-	Used   bool    `json:"used"`
+	Prn    float64 `json:"prn" proto:"1"`
+	Az     float64 `json:"az" proto:"2"`
+	El     float64 `json:"el" proto:"3"`
+	Ss     float64 `json:"ss" proto:"4"`
+	Gnssid uint8   `json:"gnssid" proto:"5"`
+	Used   bool    `json:"used" proto:"6"`
 }
 
 // Struct to store motion data.
 type MotionJson struct {
-	X     int16 `json:"x"`
-	Y     int16 `json:"y"`
-	Z     int16 `json:"z"`
-	Scale uint8 `json:"scale"`
+	X        int16   `json:"x" proto:"1"`
+	Y        int16   `json:"y" proto:"2"`
+	Z        int16   `json:"z" proto:"3"`
+	Scale    uint8   `json:"scale" proto:"4"`
+	DataRate float64 `json:"dataRate" proto:"5"`
+}
+
+// Struct to store motion orientation data.
+type OrientationJson struct {
+	Pitch float64 `json:"pitch" proto:"1"`
+	Roll  float64 `json:"roll" proto:"2"`
+}
+
+// Struct to store an error.
+type ErrorJson struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Struct to record the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// Record the status code before writing it.
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// Register a handler on the versioned route and its unversioned alias. The handler applies the rate
+// limits and counts the served status codes.
+func handleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	handleFuncWithBodySize(pattern, int64(*global.RestMaxBodySize), handler)
+}
+
+// Register a handler like handleFunc, with a maximum request body size [bytes] for routes that accept larger
+// documents than the configured maximum.
+func handleFuncWithBodySize(pattern string, maxBodySize int64, handler func(http.ResponseWriter, *http.Request)) {
+	wrapped := wrapHandler(maxBodySize, handler)
+
+	http.HandleFunc(apiVersionPrefix+pattern, wrapped)
+	http.HandleFunc(pattern, wrapped)
+}
+
+// Wrap a handler to apply the rate limits and the maximum request body size [bytes], and to count the served status
+// codes.
+func wrapHandler(maxBodySize int64, handler func(http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		if limitRequest(recorder, r) {
+			r.Body = http.MaxBytesReader(recorder, r.Body, maxBodySize)
+			handler(recorder, r)
+		}
+
+		metrics.HttpRequests.Inc(strconv.Itoa(recorder.status))
+	}
+}
+
+// Write an error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	jsonString, _ := json.Marshal(&ErrorJson{
+		Code:    status,
+		Message: message,
+	})
+
+	fmt.Fprint(w, string(jsonString))
 }
 
-// Prepare a request.
-func prepareRequest(w http.ResponseWriter, r *http.Request, lastUpdate time.Time) bool {
+// Write an uncached JSON response that does not depend on sensor samples.
+func writeJson(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	jsonString, _ := json.Marshal(data)
+	fmt.Fprint(w, string(jsonString))
+}
+
+// Set the CORS headers.
+func setCorsHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Headers", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified, Retry-After")
+}
+
+// Authorize a GET request that requires all given API key scopes. Answers CORS preflight requests and rejects other
+// methods. Call before anything that depends on the request, e.g. long-polling or format negotiation, so
+// unauthorized clients are rejected first.
+func authorizeRead(w http.ResponseWriter, r *http.Request, scopes ...string) bool {
+	setCorsHeaders(w)
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusNoContent)
 
 		return false
-	} else if r.Method == "GET" {
-		if *global.Verbose {
-			fmt.Printf("[%v] HTTP request: %s \"%s %s\" \"%s\"\n", time.Now().UTC(), r.RemoteAddr, r.Method, r.URL.Path, r.UserAgent())
-		}
-
-		if lastUpdate.IsZero() {
-			w.WriteHeader(http.StatusNoContent)
+	} else if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 
-			return false
-		}
+		return false
+	}
 
-		if *global.RestApiKey != "" {
-			apiKeyHeader := r.Header.Get("X-API-Key")
+	if *global.Verbose {
+		fmt.Printf("[%v] HTTP request: %s \"%s %s\" \"%s\"\n", time.Now().UTC(), r.RemoteAddr, r.Method, r.URL.Path, r.UserAgent())
+	}
 
-			if apiKeyHeader != *global.RestApiKey {
-				w.WriteHeader(http.StatusUnauthorized)
+	return authorizeRequest(w, r, scopes)
+}
 
-				fmt.Printf("[%v] Unauthorized HTTP request: %s \"%s %s\" \"%s\"\n", time.Now().UTC(), r.RemoteAddr, r.Method, r.URL.Path, r.UserAgent())
-				fmt.Fprintf(w, "Unauthorized: Missing or wrong X-API-Key header")
+// Prepare the response of an authorized GET request. The period is the expected time between samples, used for
+// caching.
+func prepareRequest(w http.ResponseWriter, r *http.Request, lastUpdate time.Time, period time.Duration) bool {
+	if lastUpdate.IsZero() {
+		w.WriteHeader(http.StatusNoContent)
 
-				return false
-			}
-		}
+		return false
+	}
 
-		w.Header().Add("Content-Type", "application/json")
-		w.Header().Set("Last-Modified", lastUpdate.Format(http.TimeFormat))
-		w.WriteHeader(http.StatusOK)
+	etag := createEtag(r, lastUpdate)
+	setCacheHeaders(w, etag, lastUpdate, period)
 
-		return true
-	} else {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	if isNotModified(r, etag, lastUpdate) {
+		w.WriteHeader(http.StatusNotModified)
 
 		return false
 	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	return true
 }
 
 // Handle sensors request.
 func handleSensorsRequest(w http.ResponseWriter, r *http.Request, gpsData *gps.Gps, motion *motion.Motion) {
+	if !authorizeRead(w, r, ScopeGpsRead, ScopeMotionRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time {
+		lastUpdate := lastGpsUpdate(gpsData)
+
+		if lastMotionUpdate := lastMotionUpdate(motion); lastMotionUpdate.After(lastUpdate) {
+			return lastMotionUpdate
+		}
+
+		return lastUpdate
+	})
+
 	lastTpvUpdate, lat, lon, alt, speed, mode, status, epc, epd, eph, eps, ept, epx, epy, epv, sep := gpsData.GetTpv()
 	lastSkyUpdate, qual, xdop, ydop, vdop, tdop, hdop, pdop, gdop, nsat, usat, satellites := gpsData.GetSky()
 	lastMotionUpdate, x, y, z, scale := motion.Get()
@@ -145,7 +272,27 @@ func handleSensorsRequest(w http.ResponseWriter, r *http.Request, gpsData *gps.G
 		lastUpdate = lastMotionUpdate
 	}
 
-	if !prepareRequest(w, r, lastUpdate) {
+	format, ok := negotiateFormat(w, r, SensorsJson{})
+
+	if !ok {
+		return
+	}
+
+	filter, err := parseSatelliteFilter(r.URL.Query())
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	period := gpsPeriod()
+
+	if motionPeriod() < period {
+		period = motionPeriod()
+	}
+
+	if !prepareRequest(w, r, lastUpdate, period) {
 		return
 	}
 
@@ -179,23 +326,29 @@ func handleSensorsRequest(w http.ResponseWriter, r *http.Request, gpsData *gps.G
 				Gdop:       gdop,
 				Nsat:       nsat,
 				Usat:       usat,
-				Satellites: createSatelliteJson(satellites),
+				Satellites: filter.Apply(createSatelliteJson(satellites)),
 			},
 		},
 		Motion: MotionJson{
-			X:     x,
-			Y:     y,
-			Z:     z,
-			Scale: scale,
+			X:        x,
+			Y:        y,
+			Z:        z,
+			Scale:    scale,
+			DataRate: motion.GetDataRate(),
 		},
 	}
 
-	jsonString, _ := json.Marshal(&data)
-	fmt.Fprint(w, string(jsonString))
+	writeFormat(w, r, format, &data)
 }
 
 // Handle sensor/gps request.
 func handleSensorsGpsRequest(w http.ResponseWriter, r *http.Request, data *gps.Gps) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time { return lastGpsUpdate(data) })
+
 	lastTpvUpdate, lat, lon, alt, speed, mode, status, epc, epd, eph, eps, ept, epx, epy, epv, sep := data.GetTpv()
 	lastSkyUpdate, qual, xdop, ydop, vdop, tdop, hdop, pdop, gdop, nsat, usat, satellites := data.GetSky()
 
@@ -205,7 +358,21 @@ func handleSensorsGpsRequest(w http.ResponseWriter, r *http.Request, data *gps.G
 		lastUpdate = lastSkyUpdate
 	}
 
-	if !prepareRequest(w, r, lastUpdate) {
+	format, ok := negotiateFormat(w, r, GpsJson{})
+
+	if !ok {
+		return
+	}
+
+	filter, err := parseSatelliteFilter(r.URL.Query())
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, gpsPeriod()) {
 		return
 	}
 
@@ -238,19 +405,30 @@ func handleSensorsGpsRequest(w http.ResponseWriter, r *http.Request, data *gps.G
 			Gdop:       gdop,
 			Nsat:       nsat,
 			Usat:       usat,
-			Satellites: createSatelliteJson(satellites),
+			Satellites: filter.Apply(createSatelliteJson(satellites)),
 		},
 	}
 
-	jsonString, _ := json.Marshal(&jsonData)
-	fmt.Fprint(w, string(jsonString))
+	writeFormat(w, r, format, &jsonData)
 }
 
 // Handle sensor/gps/tpv request.
 func handleSensorsGpsTpvRequest(w http.ResponseWriter, r *http.Request, data *gps.Gps) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time { return lastTpvUpdate(data) })
+
 	lastUpdate, lat, lon, alt, speed, mode, status, epc, epd, eph, eps, ept, epx, epy, epv, sep := data.GetTpv()
 
-	if !prepareRequest(w, r, lastUpdate) {
+	format, ok := negotiateFormat(w, r, TpvJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, gpsPeriod()) {
 		return
 	}
 
@@ -272,15 +450,34 @@ func handleSensorsGpsTpvRequest(w http.ResponseWriter, r *http.Request, data *gp
 		Sep:    sep,
 	}
 
-	jsonString, _ := json.Marshal(&jsonData)
-	fmt.Fprint(w, string(jsonString))
+	writeFormat(w, r, format, &jsonData)
 }
 
 // Handle sensor/gps/sky request.
 func handleSensorsGpsSkyRequest(w http.ResponseWriter, r *http.Request, data *gps.Gps) {
+	if !authorizeRead(w, r, ScopeGpsRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time { return lastSkyUpdate(data) })
+
 	lastUpdate, qual, xdop, ydop, vdop, tdop, hdop, pdop, gdop, nsat, usat, satellites := data.GetSky()
 
-	if !prepareRequest(w, r, lastUpdate) {
+	format, ok := negotiateFormat(w, r, SkyJson{})
+
+	if !ok {
+		return
+	}
+
+	filter, err := parseSatelliteFilter(r.URL.Query())
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, gpsPeriod()) {
 		return
 	}
 
@@ -295,30 +492,73 @@ func handleSensorsGpsSkyRequest(w http.ResponseWriter, r *http.Request, data *gp
 		Gdop:       gdop,
 		Nsat:       nsat,
 		Usat:       usat,
-		Satellites: createSatelliteJson(satellites),
+		Satellites: filter.Apply(createSatelliteJson(satellites)),
 	}
 
-	jsonString, _ := json.Marshal(&jsonData)
-	fmt.Fprint(w, string(jsonString))
+	writeFormat(w, r, format, &jsonData)
 }
 
 // Handle sensor/motion request.
 func handleSensorsMotionRequest(w http.ResponseWriter, r *http.Request, data *motion.Motion) {
+	if !authorizeRead(w, r, ScopeMotionRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time { return lastMotionUpdate(data) })
+
 	lastUpdate, x, y, z, scale := data.Get()
 
-	if !prepareRequest(w, r, lastUpdate) {
+	format, ok := negotiateFormat(w, r, MotionJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, motionPeriod()) {
 		return
 	}
 
 	jsonData := MotionJson{
-		X:     x,
-		Y:     y,
-		Z:     z,
-		Scale: scale,
+		X:        x,
+		Y:        y,
+		Z:        z,
+		Scale:    scale,
+		DataRate: data.GetDataRate(),
 	}
 
-	jsonString, _ := json.Marshal(&jsonData)
-	fmt.Fprint(w, string(jsonString))
+	writeFormat(w, r, format, &jsonData)
+}
+
+// Handle sensor/motion/orientation request.
+func handleSensorsMotionOrientationRequest(w http.ResponseWriter, r *http.Request, data *motion.Motion) {
+	if !authorizeRead(w, r, ScopeMotionRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time { return lastMotionUpdate(data) })
+
+	lastUpdate, pitch, roll := data.GetOrientation()
+	format, ok := negotiateFormat(w, r, OrientationJson{})
+
+	if !ok {
+		return
+	}
+
+	if !prepareRequest(w, r, lastUpdate, motionPeriod()) {
+		return
+	}
+
+	jsonData := OrientationJson{
+		Pitch: pitch,
+		Roll:  roll,
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
+
+// Create the CSV records of the satellites, as the SKY report itself is not flat.
+func (data SkyJson) CsvRecords() [][]string {
+	return createCsvRecords(data.Satellites)
 }
 
 // Create the satellite JSON struct.
@@ -340,29 +580,133 @@ func createSatelliteJson(satellites []gpsd.Satellite) []SatelliteJson {
 }
 
 // Start the HTTP REST server and listen for incoming requests.
-func (data *Rest) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan struct{}) error {
+func (data *Rest) Start(gpsData *gps.Gps, motionData *motion.Motion, canData *can.Can, done chan struct{}) error {
 	fmt.Printf("Starting HTTP server... ")
 
-	http.HandleFunc("/sensors", func(w http.ResponseWriter, r *http.Request) {
+	data.StartTime = time.Now()
+
+	err := loadApiKeys()
+
+	if err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Cannot load API keys: %s\n", err)
+
+		close(done)
+
+		return err
+	}
+
+	handleFunc("/sensors", func(w http.ResponseWriter, r *http.Request) {
 		handleSensorsRequest(w, r, gpsData, motionData)
 	})
 
-	http.HandleFunc("/sensors/gps", func(w http.ResponseWriter, r *http.Request) {
+	handleFunc("/sensors/gps", func(w http.ResponseWriter, r *http.Request) {
 		handleSensorsGpsRequest(w, r, gpsData)
 	})
 
-	http.HandleFunc("/sensors/gps/tpv", func(w http.ResponseWriter, r *http.Request) {
+	handleFunc("/sensors/gps/tpv", func(w http.ResponseWriter, r *http.Request) {
 		handleSensorsGpsTpvRequest(w, r, gpsData)
 	})
 
-	http.HandleFunc("/sensors/gps/sky", func(w http.ResponseWriter, r *http.Request) {
+	handleFunc("/sensors/gps/sky", func(w http.ResponseWriter, r *http.Request) {
 		handleSensorsGpsSkyRequest(w, r, gpsData)
 	})
 
-	http.HandleFunc("/sensors/motion", func(w http.ResponseWriter, r *http.Request) {
+	if data.LeverArm != nil {
+		handleFunc("/sensors/gps/antenna", func(w http.ResponseWriter, r *http.Request) {
+			handleSensorsGpsAntennaRequest(w, r, gpsData, data.LeverArm)
+		})
+	}
+
+	handleFunc("/sensors/gps/coordinates", func(w http.ResponseWriter, r *http.Request) {
+		handleSensorsGpsCoordinatesRequest(w, r, gpsData, data.Origin)
+	})
+
+	handleFunc("/sensors/motion", func(w http.ResponseWriter, r *http.Request) {
 		handleSensorsMotionRequest(w, r, motionData)
 	})
 
+	handleFunc("/sensors/motion/orientation", func(w http.ResponseWriter, r *http.Request) {
+		handleSensorsMotionOrientationRequest(w, r, motionData)
+	})
+
+	handleFunc("/sensors/motion/calibration", func(w http.ResponseWriter, r *http.Request) {
+		handleSensorsMotionCalibrationRequest(w, r, motionData)
+	})
+
+	if data.Vibration != nil {
+		handleFunc("/sensors/motion/vibration", func(w http.ResponseWriter, r *http.Request) {
+			handleSensorsMotionVibrationRequest(w, r, data.Vibration)
+		})
+	}
+
+	if data.Events != nil {
+		handleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+			handleEventsRequest(w, r, data.Events)
+		})
+	}
+
+	if data.Filter != nil {
+		handleFunc("/sensors/gps/filtered", func(w http.ResponseWriter, r *http.Request) {
+			handleSensorsGpsFilteredRequest(w, r, data.Filter)
+		})
+	}
+
+	if data.Geofences != nil {
+		handleFuncWithBodySize("/geofences", geoJsonBodySize(), func(w http.ResponseWriter, r *http.Request) {
+			handleGeofencesRequest(w, r, data.Geofences)
+		})
+
+		handleFunc("/geofences/events", func(w http.ResponseWriter, r *http.Request) {
+			handleGeofenceEventsRequest(w, r, data.Geofences)
+		})
+	}
+
+	if data.Interference != nil {
+		handleFunc("/interference", func(w http.ResponseWriter, r *http.Request) {
+			handleInterferenceRequest(w, r, data.Interference)
+		})
+	}
+
+	if data.Overspeed != nil {
+		handleFunc("/overspeed", func(w http.ResponseWriter, r *http.Request) {
+			handleOverspeedRequest(w, r, data.Overspeed)
+		})
+	}
+
+	if data.Odometer != nil {
+		handleFunc("/odometer", func(w http.ResponseWriter, r *http.Request) {
+			handleOdometerRequest(w, r, data.Odometer)
+		})
+	}
+
+	if data.Movement != nil {
+		handleFunc("/movement", func(w http.ResponseWriter, r *http.Request) {
+			handleMovementRequest(w, r, data.Movement, gpsData)
+		})
+	}
+
+	handleFunc("/config", handleConfigRequest)
+	handleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		handleMetricsRequest(w, r, gpsData, motionData)
+	})
+
+	handleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		handleHealthRequest(w, r, data, gpsData, motionData, canData)
+	})
+
+	handleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		handleOpenApiRequest(w, r, data.Version)
+	})
+
+	handleFunc("/sensor.proto", func(w http.ResponseWriter, r *http.Request) {
+		handleProtobufSchemaRequest(w, r)
+	})
+
+	handleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Not found")
+	})
+
 	listen, err := net.Listen("tcp", ":"+strconv.FormatUint(*global.RestPort, 10))
 
 	if err != nil {
@@ -374,16 +718,35 @@ func (data *Rest) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan s
 		return err
 	}
 
+	tlsListen, err := createTlsListener(createLimitListener(listen), done)
+
+	if err != nil {
+		fmt.Printf("Fail\n")
+		fmt.Fprintf(os.Stderr, "Cannot configure TLS: %s\n", err)
+
+		listen.Close()
+		close(done)
+
+		return err
+	}
+
 	fmt.Printf("OK\n")
 
 	serve := make(chan struct{})
 
 	global.Wg.Add(2)
 
+	server := &http.Server{
+		ReadTimeout:    time.Duration(*global.RestReadTimeout * float64(time.Second)),
+		WriteTimeout:   time.Duration(*global.RestWriteTimeout * float64(time.Second)),
+		IdleTimeout:    time.Duration(*global.RestIdleTimeout * float64(time.Second)),
+		MaxHeaderBytes: int(*global.RestMaxHeaderSize),
+	}
+
 	go func() {
-		err := http.Serve(listen, nil)
+		err := server.Serve(tlsListen)
 
-		if err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			fmt.Fprintf(os.Stderr, "Error serving content: %s\n", err)
 			close(serve)
 		}
@@ -395,9 +758,9 @@ func (data *Rest) Start(gpsData *gps.Gps, motionData *motion.Motion, done chan s
 		select {
 		case <-serve:
 			close(done)
-			listen.Close()
+			server.Close()
 		case <-done:
-			listen.Close()
+			server.Close()
 		}
 
 		global.Wg.Done()
@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/gps"
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+func TestAuthorizeBeforeLongPoll(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	keyFile := `{"keys": [
+		{"name": "reader", "key": "r", "scopes": ["motion:read"]},
+		{"name": "expired", "key": "e", "scopes": ["motion:read"], "expires": "` + expired + `"},
+		{"name": "gps", "key": "g", "scopes": ["gps:read"]}
+	]}`
+
+	tests := []struct {
+		name   string
+		key    string
+		status int
+		waits  bool
+	}{
+		{"missing key", "", http.StatusUnauthorized, false},
+		{"expired key", "e", http.StatusUnauthorized, false},
+		{"missing scope", "g", http.StatusForbidden, false},
+		{"authorized", "r", http.StatusOK, true},
+	}
+
+	if err := setApiKeys(t, "", keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	data := &motion.Motion{LastUpdate: time.Now()}
+	wait := 200 * time.Millisecond
+	// An ETag of a future sample, so the request waits for the full duration.
+	etag := "\"" + strconv.FormatInt(time.Now().Add(time.Hour).UnixNano(), 36) + "-0\""
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/sensors/motion?wait="+wait.String(), nil)
+			r.Header.Set("If-None-Match", etag)
+			r.Header.Set("X-API-Key", test.key)
+			w := httptest.NewRecorder()
+
+			start := time.Now()
+			handleSensorsMotionRequest(w, r, data)
+			elapsed := time.Since(start)
+
+			if w.Code != test.status {
+				t.Errorf("status = %d, want %d", w.Code, test.status)
+			}
+
+			if waited := elapsed >= wait; waited != test.waits {
+				t.Errorf("request took %v, want waiting %v", elapsed, test.waits)
+			}
+		})
+	}
+}
+
+func TestAuthorizeBeforeFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		query  string
+		accept string
+		status int
+	}{
+		{"unknown field without key", "", "?fields=unknown", "", http.StatusUnauthorized},
+		{"unsupported format without key", "", "?format=xml", "", http.StatusUnauthorized},
+		{"unsupported media type without key", "", "", "text/html", http.StatusUnauthorized},
+		{"unknown field", "r", "?fields=unknown", "", http.StatusBadRequest},
+		{"unsupported format", "r", "?format=xml", "", http.StatusNotAcceptable},
+		{"unsupported media type", "r", "", "text/html", http.StatusNotAcceptable},
+		{"supported format", "r", "?format=csv&fields=x", "", http.StatusOK},
+	}
+
+	if err := setApiKeys(t, "", `{"keys": [{"name": "reader", "key": "r", "scopes": ["motion:read"]}]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	data := &motion.Motion{LastUpdate: time.Now()}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/sensors/motion"+test.query, nil)
+			r.Header.Set("X-API-Key", test.key)
+			r.Header.Set("Accept", test.accept)
+			w := httptest.NewRecorder()
+
+			handleSensorsMotionRequest(w, r, data)
+
+			if w.Code != test.status {
+				t.Errorf("status = %d, want %d", w.Code, test.status)
+			}
+		})
+	}
+}
+
+func TestOdometerRequest(t *testing.T) {
+	if err := setApiKeys(t, "", `{"keys": [{"name": "reader", "key": "r", "scopes": ["gps:read"]}]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	odometer := &gps.Odometer{LastUpdate: time.Now(), Counters: gps.Counters{Total: 1500, Trip: 500, CurrentTrip: 250}}
+
+	r := httptest.NewRequest(http.MethodGet, "/odometer", nil)
+	r.Header.Set("X-API-Key", "r")
+	w := httptest.NewRecorder()
+	handleOdometerRequest(w, r, odometer)
+
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Fatalf("status = %d, ETag = %q, want 200 with an ETag", w.Code, w.Header().Get("ETag"))
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, `"total":1.5`) || !strings.Contains(body, `"currentTrip":0.25`) {
+		t.Errorf("body = %s, want the counters in km", body)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/odometer", nil)
+	r.Header.Set("X-API-Key", "r")
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	handleOdometerRequest(w, r, odometer)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("status = %d, want %d for a known ETag", w.Code, http.StatusNotModified)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/odometer", nil)
+	w = httptest.NewRecorder()
+	handleOdometerRequest(w, r, odometer)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d without an API key", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/global"
+)
+
+// Struct to store the TLS certificate and client CA pool, which can be reloaded at runtime.
+type certificateStore struct {
+	Mutex       sync.RWMutex
+	Certificate *tls.Certificate
+	ClientCas   *x509.CertPool
+}
+
+// Load the certificate, key, and client CA files with mutex lock.
+func (store *certificateStore) Load() error {
+	certificate, err := tls.LoadX509KeyPair(*global.RestTlsCert, *global.RestTlsKey)
+
+	if err != nil {
+		return err
+	}
+
+	var clientCas *x509.CertPool
+
+	if *global.RestTlsClientCa != "" {
+		caPem, readErr := os.ReadFile(*global.RestTlsClientCa)
+
+		if readErr != nil {
+			return readErr
+		}
+
+		clientCas = x509.NewCertPool()
+
+		if !clientCas.AppendCertsFromPEM(caPem) {
+			return fmt.Errorf("no valid certificates found in %s", *global.RestTlsClientCa)
+		}
+	}
+
+	store.Mutex.Lock()
+	defer store.Mutex.Unlock()
+
+	store.Certificate = &certificate
+	store.ClientCas = clientCas
+
+	return nil
+}
+
+// Get the TLS configuration for an incoming connection with mutex lock.
+func (store *certificateStore) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	store.Mutex.RLock()
+	defer store.Mutex.RUnlock()
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*store.Certificate},
+	}
+
+	if store.ClientCas != nil {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = store.ClientCas
+	}
+
+	return config, nil
+}
+
+// Reload the certificates on SIGHUP until done.
+func (store *certificateStore) WatchReload(done chan struct{}) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	global.Wg.Add(1)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				signal.Stop(sighup)
+				global.Wg.Done()
+				return
+			case <-sighup:
+				err := store.Load()
+
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Cannot reload TLS certificate: %s\n", err)
+				} else {
+					fmt.Printf("[%v] Reloaded TLS certificate\n", time.Now().UTC())
+				}
+			}
+		}
+	}()
+}
+
+// Check if file exists.
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+
+	if os.IsNotExist(err) {
+		return false
+	}
+
+	return err == nil && !info.IsDir()
+}
+
+// Generate a self-signed certificate and key if neither exists yet.
+func generateSelfSignedCertificate(certPath string, keyPath string) error {
+	if fileExists(certPath) || fileExists(keyPath) {
+		return nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+
+	if hostname == "" {
+		hostname = "sensor"
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{hostname, "localhost"},
+	}
+
+	addresses, _ := net.InterfaceAddrs()
+
+	for _, address := range addresses {
+		if ipNet, ok := address.(*net.IPNet); ok {
+			template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+		}
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+
+	if err != nil {
+		return err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{certPath, keyPath} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated self-signed TLS certificate %s... ", certPath)
+
+	return nil
+}
+
+// Create a TLS listener if TLS is enabled, or return the plain listener otherwise.
+func createTlsListener(listen net.Listener, done chan struct{}) (net.Listener, error) {
+	if !*global.RestTls {
+		return listen, nil
+	}
+
+	if *global.RestTlsCert == "" || *global.RestTlsKey == "" {
+		return nil, errors.New("TLS certificate and key files must be set")
+	}
+
+	if err := generateSelfSignedCertificate(*global.RestTlsCert, *global.RestTlsKey); err != nil {
+		return nil, err
+	}
+
+	store := &certificateStore{}
+
+	if err := store.Load(); err != nil {
+		return nil, err
+	}
+
+	store.WatchReload(done)
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: store.GetConfigForClient,
+	}
+
+	return tls.NewListener(listen, config), nil
+}
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/vuhuy/tcg4-sensor/internal/motion"
+)
+
+// Struct to store the vibration analysis of the last window of motion samples.
+type VibrationJson struct {
+	SampleRate float64           `json:"sampleRate" proto:"1"`
+	Window     int               `json:"window" proto:"2"`
+	Resolution float64           `json:"resolution" proto:"3"`
+	X          AxisVibrationJson `json:"x" proto:"4"`
+	Y          AxisVibrationJson `json:"y" proto:"5"`
+	Z          AxisVibrationJson `json:"z" proto:"6"`
+}
+
+// Struct to store the vibration analysis of a single axis.
+type AxisVibrationJson struct {
+	Rms               float64   `json:"rms" proto:"1"`
+	PeakToPeak        float64   `json:"peakToPeak" proto:"2"`
+	CrestFactor       float64   `json:"crestFactor" proto:"3"`
+	DominantFrequency float64   `json:"dominantFrequency" proto:"4"`
+	Spectrum          []float64 `json:"spectrum" proto:"5"`
+}
+
+// Create the vibration JSON struct of a single axis.
+func createAxisVibrationJson(vibration motion.AxisVibration) AxisVibrationJson {
+	return AxisVibrationJson{
+		Rms:               vibration.Rms,
+		PeakToPeak:        vibration.PeakToPeak,
+		CrestFactor:       vibration.CrestFactor,
+		DominantFrequency: vibration.DominantFrequency,
+		Spectrum:          vibration.Spectrum,
+	}
+}
+
+// Handle sensors/motion/vibration request.
+func handleSensorsMotionVibrationRequest(w http.ResponseWriter, r *http.Request, data *motion.Vibration) {
+	if !authorizeRead(w, r, ScopeMotionRead) {
+		return
+	}
+
+	waitForUpdate(r, func() time.Time {
+		lastUpdate, _, _, _, _, _ := data.Get()
+
+		return lastUpdate
+	})
+
+	lastUpdate, sampleRate, window, x, y, z := data.Get()
+	format, ok := negotiateFormat(w, r, VibrationJson{})
+
+	if !ok {
+		return
+	}
+
+	period := time.Duration(float64(window) / sampleRate * float64(time.Second))
+
+	if !prepareRequest(w, r, lastUpdate, period) {
+		return
+	}
+
+	jsonData := VibrationJson{
+		SampleRate: sampleRate,
+		Window:     window,
+		Resolution: sampleRate / float64(window),
+		X:          createAxisVibrationJson(x),
+		Y:          createAxisVibrationJson(y),
+		Z:          createAxisVibrationJson(z),
+	}
+
+	writeFormat(w, r, format, &jsonData)
+}
@@ -16,11 +16,19 @@ const DefaultAddress = "localhost:2947"
 // Filter is a gpsd entry filter function
 type Filter func(interface{})
 
+// ReportFilter is a function called with the class of every gpsd report
+type ReportFilter func(string)
+
+// ErrorFilter is a function called on every gpsd report parsing error
+type ErrorFilter func(error)
+
 // Session represents a connection to gpsd
 type Session struct {
-	socket  net.Conn
-	reader  *bufio.Reader
-	filters map[string][]Filter
+	socket        net.Conn
+	reader        *bufio.Reader
+	filters       map[string][]Filter
+	reportFilters []ReportFilter
+	errorFilters  []ErrorFilter
 }
 
 // Mode describes status of a TPV report
@@ -43,27 +51,30 @@ type gpsdReport struct {
 
 // TPVReport is a Time-Position-Velocity report
 type TPVReport struct {
-	Class  string    `json:"class"`
-	Tag    string    `json:"tag"`
-	Device string    `json:"device"`
-	Mode   Mode      `json:"mode"`
-	Time   time.Time `json:"time"`
-	Ept    float64   `json:"ept"`
-	Lat    float64   `json:"lat"`
-	Lon    float64   `json:"lon"`
-	Alt    float64   `json:"alt"`
-	Epx    float64   `json:"epx"`
-	Epy    float64   `json:"epy"`
-	Epv    float64   `json:"epv"`
-	Track  float64   `json:"track"`
-	Speed  float64   `json:"speed"`
-	Climb  float64   `json:"climb"`
-	Epd    float64   `json:"epd"`
-	Eps    float64   `json:"eps"`
-	Epc    float64   `json:"epc"`
-	Eph    float64   `json:"eph"`
-	Status uint8     `json:"status"`
-	Sep    float64   `json:"sep"`
+	Class    string    `json:"class"`
+	Tag      string    `json:"tag"`
+	Device   string    `json:"device"`
+	Mode     Mode      `json:"mode"`
+	Time     time.Time `json:"time"`
+	Ept      float64   `json:"ept"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Alt      float64   `json:"alt"`
+	AltHAE   float64   `json:"altHAE"`
+	AltMSL   float64   `json:"altMSL"`
+	Epx      float64   `json:"epx"`
+	Epy      float64   `json:"epy"`
+	Epv      float64   `json:"epv"`
+	Track    float64   `json:"track"`
+	Speed    float64   `json:"speed"`
+	Climb    float64   `json:"climb"`
+	Epd      float64   `json:"epd"`
+	Eps      float64   `json:"eps"`
+	Epc      float64   `json:"epc"`
+	Eph      float64   `json:"eph"`
+	Status   uint8     `json:"status"`
+	Sep      float64   `json:"sep"`
+	GeoidSep float64   `json:"geoidSep"`
 }
 
 // SKYReport reports sky view of GPS satellites
@@ -259,6 +270,24 @@ func (s *Session) AddFilter(class string, f Filter) {
 	s.filters[class] = append(s.filters[class], f)
 }
 
+// AddReportFilter attaches a function which will be called with the class
+// of every GPSD report received, regardless of any class filters.
+func (s *Session) AddReportFilter(f ReportFilter) {
+	s.reportFilters = append(s.reportFilters, f)
+}
+
+// AddErrorFilter attaches a function which will be called for every GPSD
+// report that cannot be parsed.
+func (s *Session) AddErrorFilter(f ErrorFilter) {
+	s.errorFilters = append(s.errorFilters, f)
+}
+
+func (s *Session) deliverError(err error) {
+	for _, f := range s.errorFilters {
+		f(err)
+	}
+}
+
 func (s *Session) deliverReport(class string, report interface{}) {
 	for _, f := range s.filters[class] {
 		f(report)
@@ -287,6 +316,10 @@ func watch(done chan bool, s *Session) {
 			var reportPeek gpsdReport
 			lineBytes := []byte(line)
 			if err = json.Unmarshal(lineBytes, &reportPeek); err == nil {
+				for _, f := range s.reportFilters {
+					f(reportPeek.Class)
+				}
+
 				if len(s.filters[reportPeek.Class]) == 0 {
 					continue
 				}
@@ -294,10 +327,12 @@ func watch(done chan bool, s *Session) {
 				if report, err2 := unmarshalReport(reportPeek.Class, lineBytes); err2 == nil {
 					s.deliverReport(reportPeek.Class, report)
 				} else {
-					fmt.Println("JSON parsing error 2:", err)
+					fmt.Println("JSON parsing error 2:", err2)
+					s.deliverError(err2)
 				}
 			} else {
 				fmt.Println("JSON parsing error:", err)
+				s.deliverError(err)
 			}
 		} else {
 			if !errors.Is(err, net.ErrClosed) {